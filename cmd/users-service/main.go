@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	log "github.com/sirupsen/logrus"
 	_ "github.com/sosshik/users-service/docs"
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/cursor"
 	"github.com/sosshik/users-service/internal/handlers"
+	"github.com/sosshik/users-service/internal/health"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/password"
 	"github.com/sosshik/users-service/internal/repository"
 	"github.com/sosshik/users-service/internal/service"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// notifyTimeout bounds how long delivering a single notification may take
+const notifyTimeout = 30 * time.Second
+
 // @title Users Service API
 // @version 1.0
 // @description This is a sample service for managing users.
@@ -19,17 +36,143 @@ import (
 // @host localhost:8090
 // @BasePath /
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access token from /auth/login, sent as "Bearer <token>"
+
 func main() {
-	log.SetFormatter(&log.JSONFormatter{})
-	log.SetOutput(os.Stdout)
-	log.SetLevel(log.InfoLevel)
+	configPath := flag.String("config", os.Getenv("USERS_SERVICE_CONFIG_FILE"), "path to a YAML or JSON configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Unable to load configuration: %s", err)
+	}
+
+	setupLogging(cfg.Log)
+
+	// Readiness stays unavailable until startup completes and again once draining begins
+	checker := health.NewChecker(cfg.Health.CheckTimeout)
+	startup := health.NewGate("startup has not finished")
+	serving := health.NewGate("service is shutting down")
+	checker.Register("startup", startup.Check)
+	checker.Register("draining", serving.Check)
+
+	repos, err := repository.NewRepository(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Unable to initialize repository: %s", err)
+	}
+
+	checker.Register("storage", repos.Ping)
+
+	tokens, err := auth.NewTokenManager(cfg.Auth)
+	if err != nil {
+		log.Fatalf("Unable to initialize token manager: %s", err)
+	}
+
+	cursors, err := cursor.NewSigner(cfg.Pagination.CursorSecret)
+	if err != nil {
+		log.Fatalf("Unable to initialize cursor signer: %s", err)
+	}
+
+	policy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		log.Fatalf("Unable to initialize password policy: %s", err)
+	}
+
+	hasher, err := password.NewHasher(cfg.Password)
+	if err != nil {
+		log.Fatalf("Unable to initialize password hasher: %s", err)
+	}
+
+	notifier, err := notify.New(cfg.Notifier)
+	if err != nil {
+		log.Fatalf("Unable to initialize notifier: %s", err)
+	}
+	// Deliver in the background so response times do not reveal whether a message was sent
+	outbox := notify.NewAsync(notifier, notifyTimeout)
+
+	services := service.NewService(repos, tokens, cursors, policy, hasher, outbox, audit.NewLogLogger(), cfg)
 
-	repos := repository.NewRepository()
+	if err := services.BootstrapAdmin(cfg.Auth.BootstrapAdmin); err != nil {
+		log.Fatalf("Unable to bootstrap admin: %s", err)
+	}
 
-	services := service.NewService(repos)
+	// Permanently remove deleted users once their retention period has passed
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		service.RunPurge(purgeCtx, services, cfg.Retention.PurgeInterval)
+	}()
 
-	handler := handlers.NewHandler(services)
+	handler := handlers.NewHandler(services, checker, tokens, cfg)
 
 	srv := handler.InitRoutes()
-	log.Fatal(srv.Start(":8090"))
+
+	// Stop on SIGINT (Ctrl+C) and SIGTERM (sent by Kubernetes and Docker)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.Start(cfg.HTTP.Address)
+	}()
+
+	// Storage is connected and migrated by now, so the instance may receive traffic
+	serving.Open()
+	startup.Open()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		log.Errorf("Server stopped unexpectedly: %s", err)
+		exitCode = 1
+	case <-ctx.Done():
+		// A second signal terminates immediately instead of waiting for the drain
+		stop()
+		log.Info("Shutdown signal received, draining connections")
+
+		serving.Close()
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("Unable to drain connections: %s", err)
+			exitCode = 1
+		}
+		cancel()
+
+		if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Server stopped with error: %s", err)
+			exitCode = 1
+		}
+	}
+
+	// Let pending notifications go out and a running purge finish before exiting
+	outbox.Wait()
+	stopPurge()
+	<-purgeDone
+
+	if err := repos.Close(); err != nil {
+		log.Errorf("Unable to close repository: %s", err)
+		exitCode = 1
+	}
+
+	log.Info("Shutdown complete")
+	os.Exit(exitCode)
+}
+
+// setupLogging configures the global logger from the validated log settings
+func setupLogging(cfg config.LogConfig) {
+	if cfg.Format == config.LogFormatText {
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	} else {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	log.SetOutput(os.Stdout)
+
+	level, _ := log.ParseLevel(cfg.Level)
+	log.SetLevel(level)
 }
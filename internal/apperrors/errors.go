@@ -0,0 +1,75 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when no user matches the requested ID
+	ErrUserNotFound = errors.New("user not found")
+	// ErrVersionMismatch is returned when a user changed since the version the caller based its change on
+	ErrVersionMismatch = errors.New("user was modified concurrently")
+	// ErrUserNotDeleted is returned when restoring a user that is not deleted
+	ErrUserNotDeleted = errors.New("user is not deleted")
+	// ErrNicknameTaken is returned when another user already uses the nickname
+	ErrNicknameTaken = errors.New("user with this nickname already exists")
+	// ErrEmailTaken is returned when another user already uses the email
+	ErrEmailTaken = errors.New("user with this email already exists")
+	// ErrInvalidID is returned when a user ID is not a valid UUID
+	ErrInvalidID = errors.New("invalid user id")
+	// ErrInvalidPagination is returned when page or page size cannot be parsed
+	ErrInvalidPagination = errors.New("invalid pagination parameters")
+	// ErrInvalidPayload is returned when a request body cannot be decoded
+	ErrInvalidPayload = errors.New("invalid request payload")
+	// ErrUnsupportedMediaType is returned when a patch document is neither a merge patch nor a JSON Patch
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	// ErrPatchConflict is returned when a patch does not fit the current user, e.g. a failed JSON Patch test
+	ErrPatchConflict = errors.New("patch cannot be applied to the user")
+	// ErrValidation is returned when a decoded request fails validation
+	ErrValidation = errors.New("validation failed")
+	// ErrInvalidCredentials is returned when a login or password does not match any user
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	// ErrUnauthorized is returned when a request lacks a valid access token
+	ErrUnauthorized = errors.New("authentication required")
+	// ErrWeakPassword is returned when a new password breaks the password policy
+	ErrWeakPassword = errors.New("password does not meet the policy")
+	// ErrIncorrectPassword is returned when the current password given to confirm a change is wrong
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrInvalidToken is returned when a one-time token is unknown, expired or already used
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrEmailNotVerified is returned when a login requires a verified email
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrInvalidFilter is returned when a list filter has an unusable value
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrInvalidSort is returned when a list is sorted by an unknown or repeated field
+	ErrInvalidSort = errors.New("invalid sort")
+	// ErrInvalidCursor is returned when a page cursor is forged or used with another filter or sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrTooManyAttempts is returned when logins are refused after repeated failures
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	// ErrForbidden is returned when the authenticated caller may not perform the operation
+	ErrForbidden = errors.New("operation not permitted")
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired, revoked or reused
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// ThrottledError is returned when an operation is refused until RetryAfter has passed
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry in %d seconds", ErrTooManyAttempts, e.RetryAfterSeconds())
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, as used by the Retry-After header
+func (e *ThrottledError) RetryAfterSeconds() int {
+	return int(math.Max(1, math.Ceil(e.RetryAfter.Seconds())))
+}
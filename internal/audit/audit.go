@@ -0,0 +1,55 @@
+// Package audit records security relevant events, such as account lockouts, separately from diagnostic logs
+package audit
+
+import (
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// EventLoginLocked is recorded when repeated failed logins lock out an account or client address
+	EventLoginLocked = "login_locked"
+	// EventLoginUnlocked is recorded when an administrator lifts the lockout of an account
+	EventLoginUnlocked = "login_unlocked"
+)
+
+// Event describes something that happened to an account
+type Event struct {
+	Type string
+	// Subject is what the event is about, such as a user ID or a client address
+	Subject string
+	// Actor is the user who caused the event, empty when the service acted on its own
+	Actor string
+	// ClientIP is the address of the request that caused the event
+	ClientIP string
+	Detail   string
+	Time     time.Time
+}
+
+// Logger stores audit events
+type Logger interface {
+	Record(event Event)
+}
+
+// LogLogger writes audit events to the service log, marked with an audit field so they can be routed separately
+type LogLogger struct{}
+
+// NewLogLogger creates a new instance of LogLogger
+func NewLogLogger() *LogLogger {
+	return &LogLogger{}
+}
+
+// Record writes the event to the service log
+func (l *LogLogger) Record(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	log.WithFields(log.Fields{
+		"audit":     event.Type,
+		"subject":   event.Subject,
+		"actor":     event.Actor,
+		"client_ip": event.ClientIP,
+		"at":        event.Time.Format(time.RFC3339Nano),
+	}).Warnf("[Audit] %s: %s", event.Type, event.Detail)
+}
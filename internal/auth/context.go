@@ -0,0 +1,33 @@
+// Package auth issues and verifies the access tokens that identify API callers.
+package auth
+
+import (
+	"context"
+	"github.com/google/uuid"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+// principalKey is the context key under which the authenticated caller is stored
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the authenticated caller
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the authenticated user ID stored in ctx, if any
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	principal, ok := PrincipalFromContext(ctx)
+	return principal.UserID, ok
+}
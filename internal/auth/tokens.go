@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/config"
+	"os"
+	"time"
+)
+
+// Claims are the JWT claims carried by access tokens; the subject is the user ID
+type Claims struct {
+	jwt.RegisteredClaims
+	// Role is the user's role when the token was issued
+	Role string `json:"role"`
+	// UserID is the parsed subject, filled in by TokenManager.Parse
+	UserID uuid.UUID `json:"-"`
+}
+
+// Principal returns the caller identified by the claims
+func (c *Claims) Principal() Principal {
+	return Principal{UserID: c.UserID, Role: c.Role}
+}
+
+// TokenManager issues and verifies signed access tokens
+type TokenManager struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	issuer    string
+	ttl       time.Duration
+}
+
+// NewTokenManager creates a TokenManager for the configured signing algorithm
+func NewTokenManager(cfg config.AuthConfig) (*TokenManager, error) {
+	m := &TokenManager{issuer: cfg.Issuer, ttl: cfg.AccessTokenTTL}
+
+	switch cfg.Algorithm {
+	case config.AlgorithmHS256:
+		secret := []byte(cfg.Secret)
+		if len(secret) == 0 {
+			log.Warn("[NewTokenManager] No auth secret configured, generating a random one; tokens will not survive restarts")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("unable to generate auth secret: %w", err)
+			}
+		}
+		m.method, m.signKey, m.verifyKey = jwt.SigningMethodHS256, secret, secret
+	case config.AlgorithmRS256:
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read auth private key: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse auth private key: %w", err)
+		}
+		m.method, m.signKey, m.verifyKey = jwt.SigningMethodRS256, key, &key.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+
+	return m, nil
+}
+
+// TTL returns how long issued access tokens stay valid
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue creates a signed access token for the given user and returns it with its expiry time
+func (m *TokenManager) Issue(userID uuid.UUID, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    m.issuer,
+		Subject:   userID.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}, Role: role}
+
+	token, err := jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Parse verifies the token signature, algorithm, issuer and expiry and returns its claims
+func (m *TokenManager) Parse(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return m.verifyKey, nil
+	},
+		// Pinning the algorithm prevents downgrades such as "none" or HS256 signed with the RSA public key
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrUnauthorized, err)
+	}
+
+	claims.UserID, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid token subject", apperrors.ErrUnauthorized)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// hs256Config returns a valid HS256 configuration
+func hs256Config() config.AuthConfig {
+	cfg := config.Default().Auth
+	cfg.Secret = testSecret
+	return cfg
+}
+
+// rs256Config writes a freshly generated RSA key and returns a configuration using it
+func rs256Config(t *testing.T) config.AuthConfig {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	cfg := config.Default().Auth
+	cfg.Algorithm = config.AlgorithmRS256
+	cfg.PrivateKeyFile = path
+	return cfg
+}
+
+func TestTokenManagerRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.AuthConfig
+	}{
+		{name: "HS256", cfg: hs256Config()},
+		{name: "HS256 with generated secret", cfg: config.Default().Auth},
+		{name: "RS256", cfg: rs256Config(t)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewTokenManager(tt.cfg)
+			require.NoError(t, err)
+
+			userID := uuid.New()
+			token, expiresAt, err := m.Issue(userID, "admin")
+			require.NoError(t, err)
+			assert.WithinDuration(t, time.Now().Add(tt.cfg.AccessTokenTTL), expiresAt, time.Second)
+
+			claims, err := m.Parse(token)
+			require.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+			assert.Equal(t, Principal{UserID: userID, Role: "admin"}, claims.Principal())
+			assert.Equal(t, tt.cfg.Issuer, claims.Issuer)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.cfg.Algorithm, parsed.Method.Alg())
+		})
+	}
+}
+
+func TestTokenManagerRejects(t *testing.T) {
+	m, err := NewTokenManager(hs256Config())
+	require.NoError(t, err)
+	valid, _, err := m.Issue(uuid.New(), "user")
+	require.NoError(t, err)
+
+	// Tokens signed by other parties or with other settings
+	otherSecret := hs256Config()
+	otherSecret.Secret = strings.Repeat("x", 32)
+	otherIssuer := hs256Config()
+	otherIssuer.Issuer = "someone-else"
+	expired, err := NewTokenManager(hs256Config())
+	require.NoError(t, err)
+	expired.ttl = -time.Minute
+
+	sign := func(cfg config.AuthConfig, m *TokenManager) string {
+		if m == nil {
+			m, err = NewTokenManager(cfg)
+			require.NoError(t, err)
+		}
+		token, _, err := m.Issue(uuid.New(), "user")
+		require.NoError(t, err)
+		return token
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	badSubject, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   "admin",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "Malformed", token: "not-a-token"},
+		{name: "Tampered signature", token: valid[:len(valid)-2] + "xx"},
+		{name: "Other secret", token: sign(otherSecret, nil)},
+		{name: "Other issuer", token: sign(otherIssuer, nil)},
+		{name: "Expired", token: sign(config.AuthConfig{}, expired)},
+		{name: "RS256 token", token: sign(rs256Config(t), nil)},
+		{name: "Unsigned", token: unsigned},
+		{name: "Subject is not a user ID", token: badSubject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Parse(tt.token)
+			assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+		})
+	}
+}
+
+func TestNewTokenManagerInvalidKey(t *testing.T) {
+	cfg := rs256Config(t)
+	require.NoError(t, os.WriteFile(cfg.PrivateKeyFile, []byte("not a key"), 0o600))
+
+	_, err := NewTokenManager(cfg)
+	assert.ErrorContains(t, err, "unable to parse auth private key")
+
+	cfg.PrivateKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = NewTokenManager(cfg)
+	assert.ErrorContains(t, err, "unable to read auth private key")
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	principal := Principal{UserID: uuid.New(), Role: "user"}
+	ctx := ContextWithPrincipal(context.Background(), principal)
+	got, ok := PrincipalFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, principal, got)
+
+	id, ok := UserIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, principal.UserID, id)
+}
@@ -0,0 +1,469 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// StorageMemory keeps users in process memory; data is lost on restart
+	StorageMemory = "memory"
+	// StoragePostgres stores users in a PostgreSQL database
+	StoragePostgres = "postgres"
+	// StorageSQLite stores users in an embedded SQLite database file
+	StorageSQLite = "sqlite"
+
+	// LogFormatJSON writes one JSON object per log entry
+	LogFormatJSON = "json"
+	// LogFormatText writes human-readable log lines
+	LogFormatText = "text"
+
+	// AlgorithmHS256 signs access tokens with a shared secret
+	AlgorithmHS256 = "HS256"
+	// AlgorithmRS256 signs access tokens with an RSA private key so other services can verify them with the public key
+	AlgorithmRS256 = "RS256"
+
+	// minSecretLength is the shortest HS256 secret accepted, matching the SHA-256 output size
+	minSecretLength = 32
+
+	// NotifierLog writes outgoing messages to the service log
+	NotifierLog = "log"
+	// NotifierFile appends outgoing messages as JSON lines to a file
+	NotifierFile = "file"
+
+	// TokenPlaceholder is replaced with the token in the links sent to users
+	TokenPlaceholder = "{token}"
+
+	// HashBcrypt hashes new passwords with bcrypt
+	HashBcrypt = "bcrypt"
+	// HashArgon2id hashes new passwords with argon2id
+	HashArgon2id = "argon2id"
+
+	// maxBcryptPasswordBytes is the longest input bcrypt hashes; later bytes would be silently ignored
+	maxBcryptPasswordBytes = 72
+	// maxPasswordBytes caps the input of the other algorithms so hashing stays cheap
+	maxPasswordBytes = 1024
+)
+
+type Config struct {
+	HTTP       HTTPConfig       `yaml:"http"`
+	Log        LogConfig        `yaml:"log"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Password   PasswordConfig   `yaml:"password"`
+	Pagination PaginationConfig `yaml:"pagination"`
+	Health     HealthConfig     `yaml:"health"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Notifier   NotifierConfig   `yaml:"notifier"`
+	// LoginThrottle slows down and locks out repeated failed logins
+	LoginThrottle LoginThrottleConfig `yaml:"login_throttle"`
+	// Retention controls how long deleted users can still be restored
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+type HTTPConfig struct {
+	Address           string        `yaml:"address"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownDelay keeps serving after a termination signal while the instance reports
+	// unhealthy, giving load balancers time to stop routing new requests to it
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// ShutdownTimeout bounds how long in-flight requests may take to drain
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// TrustProxyHeaders takes the client address from X-Forwarded-For; enable only behind a proxy that sets it
+	TrustProxyHeaders bool `yaml:"trust_proxy_headers"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	DSN     string `yaml:"dsn"`
+	// SearchIndexes keeps secondary indexes in the memory backend so filters on indexed fields avoid full scans
+	SearchIndexes bool `yaml:"search_indexes"`
+}
+
+type PasswordConfig struct {
+	// Algorithm hashes new passwords; hashes of the other algorithms are still accepted and upgraded on login
+	Algorithm  string `yaml:"algorithm"`
+	BcryptCost int    `yaml:"bcrypt_cost"`
+	// Argon2Memory is in KiB
+	Argon2Memory      int `yaml:"argon2_memory"`
+	Argon2Iterations  int `yaml:"argon2_iterations"`
+	Argon2Parallelism int `yaml:"argon2_parallelism"`
+	// MinLength is counted in characters, MaxLength in bytes
+	MinLength        int  `yaml:"min_length"`
+	MaxLength        int  `yaml:"max_length"`
+	RequireUppercase bool `yaml:"require_uppercase"`
+	RequireLowercase bool `yaml:"require_lowercase"`
+	RequireDigit     bool `yaml:"require_digit"`
+	RequireSymbol    bool `yaml:"require_symbol"`
+	// RejectUserInfo rejects passwords containing the user's nickname or email
+	RejectUserInfo bool `yaml:"reject_user_info"`
+	// BreachedListFile is a local file with one known breached password per line
+	BreachedListFile string `yaml:"breached_list_file"`
+}
+
+type LoginThrottleConfig struct {
+	// Account limits failures per user, IP per client address
+	Account ThrottleLimits `yaml:"account"`
+	IP      ThrottleLimits `yaml:"ip"`
+	// BaseDelay is the delay once the free attempts are used up; it doubles with every further failure up to MaxDelay
+	BaseDelay time.Duration `yaml:"base_delay"`
+	MaxDelay  time.Duration `yaml:"max_delay"`
+	// LockoutDuration is how long a lockout lasts; failures are also forgotten after this long without one
+	LockoutDuration time.Duration `yaml:"lockout_duration"`
+}
+
+type ThrottleLimits struct {
+	// FreeAttempts is how many failures are allowed before logins are delayed
+	FreeAttempts int `yaml:"free_attempts"`
+	// Lockout is the number of failures that locks logins out for the lockout duration
+	Lockout int `yaml:"lockout"`
+}
+
+type RetentionConfig struct {
+	// DeletedUsers is how long soft-deleted users are kept before they are purged for good
+	DeletedUsers time.Duration `yaml:"deleted_users"`
+	// PurgeInterval is how often the purge job looks for users past their retention
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout"`
+}
+
+type AuthConfig struct {
+	Algorithm string `yaml:"algorithm"`
+	// Secret signs HS256 tokens; when empty a random secret is generated at startup,
+	// so tokens do not survive restarts and cannot be verified by other instances
+	Secret string `yaml:"secret"`
+	// PrivateKeyFile is the PEM encoded RSA key used to sign RS256 tokens
+	PrivateKeyFile string        `yaml:"private_key_file"`
+	Issuer         string        `yaml:"issuer"`
+	AccessTokenTTL time.Duration `yaml:"access_token_ttl"`
+	// RefreshTokenTTL bounds how long a session may go without being refreshed
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	// PasswordResetTTL bounds how long a password reset link stays usable
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl"`
+	// PasswordResetURL is the link sent to users; TokenPlaceholder is replaced with the token
+	PasswordResetURL string `yaml:"password_reset_url"`
+	// EmailVerificationTTL bounds how long an email verification link stays usable
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl"`
+	// EmailVerificationURL is the link sent to new emails; TokenPlaceholder is replaced with the token
+	EmailVerificationURL string `yaml:"email_verification_url"`
+	// RequireVerifiedEmail refuses to log in users who have not verified their email
+	RequireVerifiedEmail bool                 `yaml:"require_verified_email"`
+	BootstrapAdmin       BootstrapAdminConfig `yaml:"bootstrap_admin"`
+}
+
+// BootstrapAdminConfig describes an administrator created on startup when it does not exist yet,
+// so a fresh installation can be managed
+type BootstrapAdminConfig struct {
+	Nickname string `yaml:"nickname"`
+	Email    string `yaml:"email"`
+	Password string `yaml:"password"`
+}
+
+type NotifierConfig struct {
+	Backend string `yaml:"backend"`
+	// File receives the messages of the file backend
+	File string `yaml:"file"`
+}
+
+type PaginationConfig struct {
+	DefaultPageSize int `yaml:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size"`
+	// CursorSecret signs page cursors; when empty a random secret is generated at startup,
+	// so cursors do not survive restarts and cannot be used with other instances
+	CursorSecret string `yaml:"cursor_secret"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Address:           ":8090",
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  log.InfoLevel.String(),
+			Format: LogFormatJSON,
+		},
+		Storage: StorageConfig{
+			Backend: StorageMemory,
+		},
+		Password: PasswordConfig{
+			Algorithm:         HashBcrypt,
+			BcryptCost:        bcrypt.DefaultCost,
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+			MinLength:         8,
+			MaxLength:         maxBcryptPasswordBytes,
+			RejectUserInfo:    true,
+		},
+		Pagination: PaginationConfig{
+			DefaultPageSize: 10,
+			MaxPageSize:     100,
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+		},
+		Auth: AuthConfig{
+			Algorithm:            AlgorithmHS256,
+			Issuer:               "users-service",
+			AccessTokenTTL:       15 * time.Minute,
+			RefreshTokenTTL:      30 * 24 * time.Hour,
+			PasswordResetTTL:     time.Hour,
+			PasswordResetURL:     "http://localhost:8090/reset-password?token=" + TokenPlaceholder,
+			EmailVerificationTTL: 48 * time.Hour,
+			EmailVerificationURL: "http://localhost:8090/verify-email?token=" + TokenPlaceholder,
+		},
+		Notifier: NotifierConfig{
+			Backend: NotifierLog,
+		},
+		LoginThrottle: LoginThrottleConfig{
+			Account:         ThrottleLimits{FreeAttempts: 3, Lockout: 10},
+			IP:              ThrottleLimits{FreeAttempts: 20, Lockout: 100},
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutDuration: 15 * time.Minute,
+		},
+		Retention: RetentionConfig{
+			DeletedUsers:  30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the optional YAML or JSON file at path
+// and USERS_SERVICE_* environment variables, in increasing order of precedence
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides the configuration with the values present in the file.
+// JSON is a subset of YAML, so both formats share the same decoder.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.HTTP.Address == "" {
+		errs = append(errs, errors.New("http.address must not be empty"))
+	}
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.read_header_timeout", c.HTTP.ReadHeaderTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_delay", c.HTTP.ShutdownDelay},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+	} {
+		if timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", timeout.name))
+		}
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatText {
+		errs = append(errs, fmt.Errorf("log.format must be %q or %q, got %q", LogFormatJSON, LogFormatText, c.Log.Format))
+	}
+
+	switch c.Storage.Backend {
+	case StorageMemory:
+	case StoragePostgres, StorageSQLite:
+		if c.Storage.DSN == "" {
+			errs = append(errs, fmt.Errorf("storage.dsn is required for the %s backend", c.Storage.Backend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend must be one of %q, %q or %q, got %q",
+			StorageMemory, StorageSQLite, StoragePostgres, c.Storage.Backend))
+	}
+
+	maxLength := maxPasswordBytes
+	switch c.Password.Algorithm {
+	case HashBcrypt:
+		maxLength = maxBcryptPasswordBytes
+	case HashArgon2id:
+	default:
+		errs = append(errs, fmt.Errorf("password.algorithm must be %q or %q, got %q",
+			HashBcrypt, HashArgon2id, c.Password.Algorithm))
+	}
+
+	if c.Password.BcryptCost < bcrypt.MinCost || c.Password.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("password.bcrypt_cost must be between %d and %d, got %d",
+			bcrypt.MinCost, bcrypt.MaxCost, c.Password.BcryptCost))
+	}
+
+	if c.Password.Argon2Iterations < 1 {
+		errs = append(errs, fmt.Errorf("password.argon2_iterations must be positive, got %d", c.Password.Argon2Iterations))
+	}
+	if c.Password.Argon2Parallelism < 1 || c.Password.Argon2Parallelism > 255 {
+		errs = append(errs, fmt.Errorf("password.argon2_parallelism must be between 1 and 255, got %d", c.Password.Argon2Parallelism))
+	}
+	if c.Password.Argon2Memory < 8*c.Password.Argon2Parallelism {
+		errs = append(errs, fmt.Errorf("password.argon2_memory must be at least 8 KiB per thread (%d), got %d",
+			8*c.Password.Argon2Parallelism, c.Password.Argon2Memory))
+	}
+
+	if c.Password.MinLength < 1 {
+		errs = append(errs, fmt.Errorf("password.min_length must be positive, got %d", c.Password.MinLength))
+	}
+	if c.Password.MaxLength < c.Password.MinLength || c.Password.MaxLength > maxLength {
+		errs = append(errs, fmt.Errorf("password.max_length must be between min_length (%d) and %d, got %d",
+			c.Password.MinLength, maxLength, c.Password.MaxLength))
+	}
+
+	if c.Pagination.DefaultPageSize < 1 {
+		errs = append(errs, fmt.Errorf("pagination.default_page_size must be positive, got %d", c.Pagination.DefaultPageSize))
+	}
+	if c.Pagination.MaxPageSize < c.Pagination.DefaultPageSize {
+		errs = append(errs, fmt.Errorf("pagination.max_page_size must be at least default_page_size (%d), got %d",
+			c.Pagination.DefaultPageSize, c.Pagination.MaxPageSize))
+	}
+	if c.Pagination.CursorSecret != "" && len(c.Pagination.CursorSecret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("pagination.cursor_secret must be at least %d bytes long", minSecretLength))
+	}
+
+	if c.Health.CheckTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("health.check_timeout must be positive, got %s", c.Health.CheckTimeout))
+	}
+
+	switch c.Auth.Algorithm {
+	case AlgorithmHS256:
+		if c.Auth.Secret != "" && len(c.Auth.Secret) < minSecretLength {
+			errs = append(errs, fmt.Errorf("auth.secret must be at least %d bytes long", minSecretLength))
+		}
+	case AlgorithmRS256:
+		if c.Auth.PrivateKeyFile == "" {
+			errs = append(errs, fmt.Errorf("auth.private_key_file is required for the %s algorithm", AlgorithmRS256))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.algorithm must be %q or %q, got %q", AlgorithmHS256, AlgorithmRS256, c.Auth.Algorithm))
+	}
+	if c.Auth.Issuer == "" {
+		errs = append(errs, errors.New("auth.issuer must not be empty"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.access_token_ttl must be positive, got %s", c.Auth.AccessTokenTTL))
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		errs = append(errs, fmt.Errorf("auth.refresh_token_ttl must be at least access_token_ttl (%s), got %s",
+			c.Auth.AccessTokenTTL, c.Auth.RefreshTokenTTL))
+	}
+
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.password_reset_ttl must be positive, got %s", c.Auth.PasswordResetTTL))
+	}
+	if !strings.Contains(c.Auth.PasswordResetURL, TokenPlaceholder) {
+		errs = append(errs, fmt.Errorf("auth.password_reset_url must contain %s", TokenPlaceholder))
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.email_verification_ttl must be positive, got %s", c.Auth.EmailVerificationTTL))
+	}
+	if !strings.Contains(c.Auth.EmailVerificationURL, TokenPlaceholder) {
+		errs = append(errs, fmt.Errorf("auth.email_verification_url must contain %s", TokenPlaceholder))
+	}
+
+	if admin := c.Auth.BootstrapAdmin; admin != (BootstrapAdminConfig{}) &&
+		(admin.Nickname == "" || admin.Email == "" || admin.Password == "") {
+		errs = append(errs, errors.New("auth.bootstrap_admin requires nickname, email and password together"))
+	}
+
+	switch c.Notifier.Backend {
+	case NotifierLog:
+	case NotifierFile:
+		if c.Notifier.File == "" {
+			errs = append(errs, fmt.Errorf("notifier.file is required for the %s backend", NotifierFile))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("notifier.backend must be %q or %q, got %q", NotifierLog, NotifierFile, c.Notifier.Backend))
+	}
+
+	throttle := c.LoginThrottle
+	for _, scope := range []struct {
+		name   string
+		limits ThrottleLimits
+	}{{"account", throttle.Account}, {"ip", throttle.IP}} {
+		if scope.limits.FreeAttempts < 0 || scope.limits.Lockout <= scope.limits.FreeAttempts {
+			errs = append(errs, fmt.Errorf("login_throttle.%s needs free_attempts of at least 0 and a greater lockout, got %d and %d",
+				scope.name, scope.limits.FreeAttempts, scope.limits.Lockout))
+		}
+	}
+	if throttle.BaseDelay <= 0 {
+		errs = append(errs, fmt.Errorf("login_throttle.base_delay must be positive, got %s", throttle.BaseDelay))
+	}
+	if throttle.MaxDelay < throttle.BaseDelay {
+		errs = append(errs, fmt.Errorf("login_throttle.max_delay must be at least base_delay (%s), got %s",
+			throttle.BaseDelay, throttle.MaxDelay))
+	}
+	if throttle.LockoutDuration <= 0 {
+		errs = append(errs, fmt.Errorf("login_throttle.lockout_duration must be positive, got %s", throttle.LockoutDuration))
+	}
+
+	if c.Retention.DeletedUsers <= 0 {
+		errs = append(errs, fmt.Errorf("retention.deleted_users must be positive, got %s", c.Retention.DeletedUsers))
+	}
+	if c.Retention.PurgeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("retention.purge_interval must be positive, got %s", c.Retention.PurgeInterval))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
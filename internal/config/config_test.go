@@ -0,0 +1,300 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile stores content in a temporary file with the given name and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		env       map[string]string
+		expectErr []string
+		check     func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "Defaults",
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, Default(), cfg)
+			},
+		},
+		{
+			name: "YAML file",
+			file: "config.yaml",
+			content: `
+http:
+  address: ":9000"
+  read_timeout: 3s
+log:
+  level: debug
+  format: text
+storage:
+  backend: sqlite
+  dsn: /tmp/users.db
+`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ":9000", cfg.HTTP.Address)
+				assert.Equal(t, 3*time.Second, cfg.HTTP.ReadTimeout)
+				assert.Equal(t, Default().HTTP.WriteTimeout, cfg.HTTP.WriteTimeout)
+				assert.Equal(t, "debug", cfg.Log.Level)
+				assert.Equal(t, LogFormatText, cfg.Log.Format)
+				assert.Equal(t, StorageSQLite, cfg.Storage.Backend)
+				assert.Equal(t, "/tmp/users.db", cfg.Storage.DSN)
+			},
+		},
+		{
+			name:    "JSON file",
+			file:    "config.json",
+			content: `{"pagination": {"default_page_size": 20, "max_page_size": 50}, "password": {"bcrypt_cost": 12}}`,
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 20, cfg.Pagination.DefaultPageSize)
+				assert.Equal(t, 50, cfg.Pagination.MaxPageSize)
+				assert.Equal(t, 12, cfg.Password.BcryptCost)
+			},
+		},
+		{
+			name:    "Environment overrides file",
+			file:    "config.yaml",
+			content: "http:\n  address: \":9000\"\n",
+			env: map[string]string{
+				"USERS_SERVICE_HTTP_ADDRESS":      ":9100",
+				"USERS_SERVICE_HTTP_IDLE_TIMEOUT": "2m",
+				"USERS_SERVICE_STORAGE_BACKEND":   "postgres",
+				"USERS_SERVICE_STORAGE_DSN":       "postgres://localhost/users",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ":9100", cfg.HTTP.Address)
+				assert.Equal(t, 2*time.Minute, cfg.HTTP.IdleTimeout)
+				assert.Equal(t, StoragePostgres, cfg.Storage.Backend)
+				assert.Equal(t, "postgres://localhost/users", cfg.Storage.DSN)
+			},
+		},
+		{
+			name:      "Unknown file key",
+			file:      "config.yaml",
+			content:   "http:\n  adress: \":9000\"\n",
+			expectErr: []string{"field adress not found"},
+		},
+		{
+			name:      "Malformed environment value",
+			env:       map[string]string{"USERS_SERVICE_PASSWORD_BCRYPT_COST": "high"},
+			expectErr: []string{"invalid USERS_SERVICE_PASSWORD_BCRYPT_COST"},
+		},
+		{
+			name:      "Missing DSN",
+			env:       map[string]string{"USERS_SERVICE_STORAGE_BACKEND": "sqlite"},
+			expectErr: []string{"storage.dsn is required for the sqlite backend"},
+		},
+		{
+			name:      "Short HS256 secret",
+			env:       map[string]string{"USERS_SERVICE_AUTH_SECRET": "too-short"},
+			expectErr: []string{"auth.secret must be at least 32 bytes long"},
+		},
+		{
+			name:      "Short cursor secret",
+			env:       map[string]string{"USERS_SERVICE_PAGINATION_CURSOR_SECRET": "too-short"},
+			expectErr: []string{"pagination.cursor_secret must be at least 32 bytes long"},
+		},
+		{
+			name: "Password policy from environment",
+			env: map[string]string{
+				"USERS_SERVICE_PASSWORD_MIN_LENGTH":       "12",
+				"USERS_SERVICE_PASSWORD_REQUIRE_DIGIT":    "true",
+				"USERS_SERVICE_PASSWORD_REJECT_USER_INFO": "false",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 12, cfg.Password.MinLength)
+				assert.True(t, cfg.Password.RequireDigit)
+				assert.False(t, cfg.Password.RejectUserInfo)
+			},
+		},
+		{
+			name:      "Password longer than bcrypt allows",
+			env:       map[string]string{"USERS_SERVICE_PASSWORD_MAX_LENGTH": "100"},
+			expectErr: []string{"password.max_length must be between min_length (8) and 72, got 100"},
+		},
+		{
+			name: "Argon2id hashing from environment",
+			env: map[string]string{
+				"USERS_SERVICE_PASSWORD_ALGORITHM":          "argon2id",
+				"USERS_SERVICE_PASSWORD_ARGON2_MEMORY":      "19456",
+				"USERS_SERVICE_PASSWORD_ARGON2_ITERATIONS":  "2",
+				"USERS_SERVICE_PASSWORD_ARGON2_PARALLELISM": "1",
+				"USERS_SERVICE_PASSWORD_MAX_LENGTH":         "256",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, HashArgon2id, cfg.Password.Algorithm)
+				assert.Equal(t, 19456, cfg.Password.Argon2Memory)
+				assert.Equal(t, 2, cfg.Password.Argon2Iterations)
+				assert.Equal(t, 1, cfg.Password.Argon2Parallelism)
+				assert.Equal(t, 256, cfg.Password.MaxLength)
+			},
+		},
+		{
+			name:      "Unknown hashing algorithm",
+			env:       map[string]string{"USERS_SERVICE_PASSWORD_ALGORITHM": "md5"},
+			expectErr: []string{`password.algorithm must be "bcrypt" or "argon2id", got "md5"`},
+		},
+		{
+			name: "Too little argon2id memory",
+			env: map[string]string{
+				"USERS_SERVICE_PASSWORD_ALGORITHM":          "argon2id",
+				"USERS_SERVICE_PASSWORD_ARGON2_MEMORY":      "16",
+				"USERS_SERVICE_PASSWORD_ARGON2_PARALLELISM": "4",
+			},
+			expectErr: []string{"password.argon2_memory must be at least 8 KiB per thread (32), got 16"},
+		},
+		{
+			name: "Login throttle from environment",
+			env: map[string]string{
+				"USERS_SERVICE_LOGIN_THROTTLE_ACCOUNT_FREE_ATTEMPTS": "5",
+				"USERS_SERVICE_LOGIN_THROTTLE_IP_LOCKOUT":            "500",
+				"USERS_SERVICE_LOGIN_THROTTLE_MAX_DELAY":             "5m",
+				"USERS_SERVICE_HTTP_TRUST_PROXY_HEADERS":             "true",
+				"USERS_SERVICE_STORAGE_SEARCH_INDEXES":               "true",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, ThrottleLimits{FreeAttempts: 5, Lockout: 10}, cfg.LoginThrottle.Account)
+				assert.Equal(t, ThrottleLimits{FreeAttempts: 20, Lockout: 500}, cfg.LoginThrottle.IP)
+				assert.Equal(t, 5*time.Minute, cfg.LoginThrottle.MaxDelay)
+				assert.True(t, cfg.HTTP.TrustProxyHeaders)
+				assert.True(t, cfg.Storage.SearchIndexes)
+			},
+		},
+		{
+			name: "Lockout within the free attempts",
+			env: map[string]string{
+				"USERS_SERVICE_LOGIN_THROTTLE_IP_FREE_ATTEMPTS": "10",
+				"USERS_SERVICE_LOGIN_THROTTLE_IP_LOCKOUT":       "10",
+				"USERS_SERVICE_LOGIN_THROTTLE_MAX_DELAY":        "100ms",
+			},
+			expectErr: []string{
+				"login_throttle.ip needs free_attempts of at least 0 and a greater lockout, got 10 and 10",
+				"login_throttle.max_delay must be at least base_delay (1s), got 100ms",
+			},
+		},
+		{
+			name: "Retention from environment",
+			env: map[string]string{
+				"USERS_SERVICE_RETENTION_DELETED_USERS":  "168h",
+				"USERS_SERVICE_RETENTION_PURGE_INTERVAL": "10m",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, 7*24*time.Hour, cfg.Retention.DeletedUsers)
+				assert.Equal(t, 10*time.Minute, cfg.Retention.PurgeInterval)
+			},
+		},
+		{
+			name:      "Non-positive retention",
+			env:       map[string]string{"USERS_SERVICE_RETENTION_DELETED_USERS": "0s"},
+			expectErr: []string{"retention.deleted_users must be positive, got 0s"},
+		},
+		{
+			name:      "Malformed boolean",
+			env:       map[string]string{"USERS_SERVICE_PASSWORD_REQUIRE_SYMBOL": "maybe"},
+			expectErr: []string{"invalid USERS_SERVICE_PASSWORD_REQUIRE_SYMBOL", "not a boolean"},
+		},
+		{
+			name:      "Incomplete bootstrap admin",
+			env:       map[string]string{"USERS_SERVICE_AUTH_BOOTSTRAP_ADMIN_NICKNAME": "root"},
+			expectErr: []string{"auth.bootstrap_admin requires nickname, email and password together"},
+		},
+		{
+			name: "Notifier from environment",
+			env: map[string]string{
+				"USERS_SERVICE_NOTIFIER_BACKEND":        "file",
+				"USERS_SERVICE_NOTIFIER_FILE":           "/tmp/outbox.jsonl",
+				"USERS_SERVICE_AUTH_PASSWORD_RESET_TTL": "30m",
+				"USERS_SERVICE_AUTH_PASSWORD_RESET_URL": "https://example.com/reset/{token}",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.Equal(t, NotifierFile, cfg.Notifier.Backend)
+				assert.Equal(t, "/tmp/outbox.jsonl", cfg.Notifier.File)
+				assert.Equal(t, 30*time.Minute, cfg.Auth.PasswordResetTTL)
+				assert.Equal(t, "https://example.com/reset/{token}", cfg.Auth.PasswordResetURL)
+			},
+		},
+		{
+			name:      "File notifier without file",
+			env:       map[string]string{"USERS_SERVICE_NOTIFIER_BACKEND": "file"},
+			expectErr: []string{"notifier.file is required for the file backend"},
+		},
+		{
+			name: "Email verification from environment",
+			env: map[string]string{
+				"USERS_SERVICE_AUTH_REQUIRE_VERIFIED_EMAIL": "true",
+				"USERS_SERVICE_AUTH_EMAIL_VERIFICATION_TTL": "24h",
+			},
+			check: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.Auth.RequireVerifiedEmail)
+				assert.Equal(t, 24*time.Hour, cfg.Auth.EmailVerificationTTL)
+			},
+		},
+		{
+			name:      "Verification URL without token",
+			env:       map[string]string{"USERS_SERVICE_AUTH_EMAIL_VERIFICATION_URL": "https://example.com/verify"},
+			expectErr: []string{"auth.email_verification_url must contain {token}"},
+		},
+		{
+			name:      "Reset URL without token",
+			env:       map[string]string{"USERS_SERVICE_AUTH_PASSWORD_RESET_URL": "https://example.com/reset"},
+			expectErr: []string{"auth.password_reset_url must contain {token}"},
+		},
+		{
+			name:      "Missing RS256 key",
+			env:       map[string]string{"USERS_SERVICE_AUTH_ALGORITHM": "RS256"},
+			expectErr: []string{"auth.private_key_file is required for the RS256 algorithm"},
+		},
+		{
+			name: "All validation errors are reported",
+			env: map[string]string{
+				"USERS_SERVICE_LOG_LEVEL":                "loud",
+				"USERS_SERVICE_STORAGE_BACKEND":          "mongo",
+				"USERS_SERVICE_PAGINATION_MAX_PAGE_SIZE": "5",
+				"USERS_SERVICE_PASSWORD_BCRYPT_COST":     "2",
+				"USERS_SERVICE_HTTP_WRITE_TIMEOUT":       "-1s",
+			},
+			expectErr: []string{"log.level", "storage.backend", "pagination.max_page_size", "password.bcrypt_cost", "http.write_timeout"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var path string
+			if tt.file != "" {
+				path = writeFile(t, tt.file, tt.content)
+			}
+
+			cfg, err := Load(path)
+			if len(tt.expectErr) > 0 {
+				require.Error(t, err)
+				for _, part := range tt.expectErr {
+					assert.Contains(t, err.Error(), part)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// envPrefix is prepended to every environment variable read by the service
+const envPrefix = "USERS_SERVICE_"
+
+// envVar binds an environment variable to the setting it overrides
+type envVar struct {
+	name string
+	set  func(value string) error
+}
+
+// envVars lists every setting that can be overridden from the environment
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		{"HTTP_ADDRESS", stringVar(&c.HTTP.Address)},
+		{"HTTP_READ_TIMEOUT", durationVar(&c.HTTP.ReadTimeout)},
+		{"HTTP_READ_HEADER_TIMEOUT", durationVar(&c.HTTP.ReadHeaderTimeout)},
+		{"HTTP_WRITE_TIMEOUT", durationVar(&c.HTTP.WriteTimeout)},
+		{"HTTP_IDLE_TIMEOUT", durationVar(&c.HTTP.IdleTimeout)},
+		{"HTTP_SHUTDOWN_DELAY", durationVar(&c.HTTP.ShutdownDelay)},
+		{"HTTP_SHUTDOWN_TIMEOUT", durationVar(&c.HTTP.ShutdownTimeout)},
+		{"HTTP_TRUST_PROXY_HEADERS", boolVar(&c.HTTP.TrustProxyHeaders)},
+		{"LOG_LEVEL", stringVar(&c.Log.Level)},
+		{"LOG_FORMAT", stringVar(&c.Log.Format)},
+		{"STORAGE_BACKEND", stringVar(&c.Storage.Backend)},
+		{"STORAGE_DSN", stringVar(&c.Storage.DSN)},
+		{"STORAGE_SEARCH_INDEXES", boolVar(&c.Storage.SearchIndexes)},
+		{"PASSWORD_ALGORITHM", stringVar(&c.Password.Algorithm)},
+		{"PASSWORD_BCRYPT_COST", intVar(&c.Password.BcryptCost)},
+		{"PASSWORD_ARGON2_MEMORY", intVar(&c.Password.Argon2Memory)},
+		{"PASSWORD_ARGON2_ITERATIONS", intVar(&c.Password.Argon2Iterations)},
+		{"PASSWORD_ARGON2_PARALLELISM", intVar(&c.Password.Argon2Parallelism)},
+		{"PASSWORD_MIN_LENGTH", intVar(&c.Password.MinLength)},
+		{"PASSWORD_MAX_LENGTH", intVar(&c.Password.MaxLength)},
+		{"PASSWORD_REQUIRE_UPPERCASE", boolVar(&c.Password.RequireUppercase)},
+		{"PASSWORD_REQUIRE_LOWERCASE", boolVar(&c.Password.RequireLowercase)},
+		{"PASSWORD_REQUIRE_DIGIT", boolVar(&c.Password.RequireDigit)},
+		{"PASSWORD_REQUIRE_SYMBOL", boolVar(&c.Password.RequireSymbol)},
+		{"PASSWORD_REJECT_USER_INFO", boolVar(&c.Password.RejectUserInfo)},
+		{"PASSWORD_BREACHED_LIST_FILE", stringVar(&c.Password.BreachedListFile)},
+		{"PAGINATION_DEFAULT_PAGE_SIZE", intVar(&c.Pagination.DefaultPageSize)},
+		{"PAGINATION_MAX_PAGE_SIZE", intVar(&c.Pagination.MaxPageSize)},
+		{"PAGINATION_CURSOR_SECRET", stringVar(&c.Pagination.CursorSecret)},
+		{"HEALTH_CHECK_TIMEOUT", durationVar(&c.Health.CheckTimeout)},
+		{"AUTH_ALGORITHM", stringVar(&c.Auth.Algorithm)},
+		{"AUTH_SECRET", stringVar(&c.Auth.Secret)},
+		{"AUTH_PRIVATE_KEY_FILE", stringVar(&c.Auth.PrivateKeyFile)},
+		{"AUTH_ISSUER", stringVar(&c.Auth.Issuer)},
+		{"AUTH_ACCESS_TOKEN_TTL", durationVar(&c.Auth.AccessTokenTTL)},
+		{"AUTH_REFRESH_TOKEN_TTL", durationVar(&c.Auth.RefreshTokenTTL)},
+		{"AUTH_PASSWORD_RESET_TTL", durationVar(&c.Auth.PasswordResetTTL)},
+		{"AUTH_PASSWORD_RESET_URL", stringVar(&c.Auth.PasswordResetURL)},
+		{"AUTH_EMAIL_VERIFICATION_TTL", durationVar(&c.Auth.EmailVerificationTTL)},
+		{"AUTH_EMAIL_VERIFICATION_URL", stringVar(&c.Auth.EmailVerificationURL)},
+		{"AUTH_REQUIRE_VERIFIED_EMAIL", boolVar(&c.Auth.RequireVerifiedEmail)},
+		{"AUTH_BOOTSTRAP_ADMIN_NICKNAME", stringVar(&c.Auth.BootstrapAdmin.Nickname)},
+		{"AUTH_BOOTSTRAP_ADMIN_EMAIL", stringVar(&c.Auth.BootstrapAdmin.Email)},
+		{"AUTH_BOOTSTRAP_ADMIN_PASSWORD", stringVar(&c.Auth.BootstrapAdmin.Password)},
+		{"NOTIFIER_BACKEND", stringVar(&c.Notifier.Backend)},
+		{"NOTIFIER_FILE", stringVar(&c.Notifier.File)},
+		{"LOGIN_THROTTLE_ACCOUNT_FREE_ATTEMPTS", intVar(&c.LoginThrottle.Account.FreeAttempts)},
+		{"LOGIN_THROTTLE_ACCOUNT_LOCKOUT", intVar(&c.LoginThrottle.Account.Lockout)},
+		{"LOGIN_THROTTLE_IP_FREE_ATTEMPTS", intVar(&c.LoginThrottle.IP.FreeAttempts)},
+		{"LOGIN_THROTTLE_IP_LOCKOUT", intVar(&c.LoginThrottle.IP.Lockout)},
+		{"LOGIN_THROTTLE_BASE_DELAY", durationVar(&c.LoginThrottle.BaseDelay)},
+		{"LOGIN_THROTTLE_MAX_DELAY", durationVar(&c.LoginThrottle.MaxDelay)},
+		{"LOGIN_THROTTLE_LOCKOUT_DURATION", durationVar(&c.LoginThrottle.LockoutDuration)},
+		{"RETENTION_DELETED_USERS", durationVar(&c.Retention.DeletedUsers)},
+		{"RETENTION_PURGE_INTERVAL", durationVar(&c.Retention.PurgeInterval)},
+	}
+}
+
+// applyEnv overrides settings with the environment variables returned by lookup
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, v := range c.envVars() {
+		value, ok := lookup(envPrefix + v.name)
+		if !ok {
+			continue
+		}
+		if err := v.set(value); err != nil {
+			return fmt.Errorf("invalid %s%s: %w", envPrefix, v.name, err)
+		}
+	}
+
+	return nil
+}
+
+func stringVar(target *string) func(string) error {
+	return func(value string) error {
+		*target = value
+		return nil
+	}
+}
+
+func intVar(target *int) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+func boolVar(target *bool) func(string) error {
+	return func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean such as true or false", value)
+		}
+		*target = parsed
+		return nil
+	}
+}
+
+func durationVar(target *time.Duration) func(string) error {
+	return func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 5s or 1m30s", value)
+		}
+		*target = parsed
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package cursor
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer, err := NewSigner(strings.Repeat("s", 32))
+	require.NoError(t, err)
+
+	c := Cursor{Query: Fingerprint("country eq DE", "-created_at"), Values: []string{"2024-01-02T03:04:05Z"}, ID: uuid.New(), Backward: true}
+	token, err := signer.Encode(c)
+	require.NoError(t, err)
+
+	decoded, err := signer.Decode(token)
+	require.NoError(t, err)
+	assert.Equal(t, c, decoded)
+}
+
+func TestSignerRejectsInvalidTokens(t *testing.T) {
+	signer, err := NewSigner(strings.Repeat("s", 32))
+	require.NoError(t, err)
+	other, err := NewSigner("")
+	require.NoError(t, err)
+
+	token, err := signer.Encode(Cursor{ID: uuid.New()})
+	require.NoError(t, err)
+	foreign, err := other.Encode(Cursor{ID: uuid.New()})
+	require.NoError(t, err)
+	payload, signature, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Empty", ""},
+		{"No signature", payload},
+		{"Not base64", "!!!." + signature},
+		{"Changed payload", payload[:len(payload)-2] + "xx." + signature},
+		{"Signed with another secret", foreign},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Decode(tt.token)
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint("a", "b"), Fingerprint("a", "b"))
+	assert.NotEqual(t, Fingerprint("ab", "c"), Fingerprint("a", "bc"))
+	assert.NotEqual(t, Fingerprint("a", "b"), Fingerprint("a", "b", "true"))
+}
@@ -0,0 +1,91 @@
+// Package filter parses the filter language of the user list into an expression tree that
+// every storage backend can evaluate or translate into its own query language.
+//
+// A filter combines conditions with AND and OR, where AND binds tighter and parentheses group:
+//
+//	country in ("DE", "AT") AND (nickname prefix adm OR created_at gt 2024-01-01)
+//
+// A condition is a field, an operator and a value. Values are bare words or double-quoted strings,
+// in which \" and \\ stand for a quote and a backslash. The short forms field=value and field:value
+// are kept for older clients; they mean contains for text fields and eq for yes/no fields.
+package filter
+
+import "time"
+
+// Operator compares a field with the value of a condition
+type Operator string
+
+const (
+	// Eq matches fields equal to the value
+	Eq Operator = "eq"
+	// Neq matches fields different from the value
+	Neq Operator = "neq"
+	// Contains matches text fields containing the value
+	Contains Operator = "contains"
+	// Prefix matches text fields starting with the value
+	Prefix Operator = "prefix"
+	// In matches text fields equal to one of a list of values
+	In Operator = "in"
+	// Gt matches timestamps after the value
+	Gt Operator = "gt"
+	// Lt matches timestamps before the value
+	Lt Operator = "lt"
+)
+
+// Kind tells how the values of a field are compared
+type Kind int
+
+const (
+	// Text fields are compared ignoring case
+	Text Kind = iota
+	// Bool fields hold yes/no values
+	Bool
+	// Time fields hold timestamps
+	Time
+)
+
+// fields lists the filterable user fields by their JSON name
+var fields = map[string]Kind{
+	"nickname":       Text,
+	"email":          Text,
+	"first_name":     Text,
+	"last_name":      Text,
+	"country":        Text,
+	"role":           Text,
+	"email_verified": Bool,
+	"created_at":     Time,
+	"updated_at":     Time,
+}
+
+// operators lists the operators each kind of field supports
+var operators = map[Kind][]Operator{
+	Text: {Eq, Neq, Contains, Prefix, In},
+	Bool: {Eq, Neq},
+	Time: {Gt, Lt},
+}
+
+// Expr is a node of a parsed filter: And, Or or *Condition
+type Expr interface {
+	expr()
+}
+
+// And matches users that match every expression
+type And []Expr
+
+// Or matches users that match at least one expression
+type Or []Expr
+
+// Condition compares a single field; only the value matching the kind of the field is set
+type Condition struct {
+	Field string
+	Kind  Kind
+	Op    Operator
+	// Text holds the value of text fields, or every listed value for In
+	Text []string
+	Bool bool
+	Time time.Time
+}
+
+func (And) expr()        {}
+func (Or) expr()         {}
+func (*Condition) expr() {}
@@ -0,0 +1,357 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxDepth bounds how deeply parentheses may be nested
+const maxDepth = 16
+
+// SyntaxError reports why a filter could not be parsed and where
+type SyntaxError struct {
+	// Pos is the 1-based position, in characters, of the offending part of the filter
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Parse parses a filter; a blank filter returns a nil expression, which matches every user
+func Parse(filter string) (Expr, error) {
+	p := &parser{input: []rune(filter)}
+	if p.skipSpace(); p.done() {
+		return nil, nil
+	}
+
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything has to be consumed, e.g. a stray closing parenthesis is an error
+	if p.skipSpace(); !p.done() {
+		return nil, p.errorAt(p.pos, "unexpected %q", p.input[p.pos])
+	}
+
+	return expr, nil
+}
+
+// parser is a recursive descent parser over the characters of a filter
+type parser struct {
+	input []rune
+	pos   int
+}
+
+// parseOr parses conditions joined by OR
+func (p *parser) parseOr(depth int) (Expr, error) {
+	var terms Or
+	for {
+		term, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+
+		if !p.keyword("or") {
+			break
+		}
+	}
+
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseAnd parses conditions joined by AND
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	var terms And
+	for {
+		term, err := p.parsePrimary(depth)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+
+		if !p.keyword("and") {
+			break
+		}
+	}
+
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parsePrimary parses a parenthesized group or a single condition
+func (p *parser) parsePrimary(depth int) (Expr, error) {
+	if p.skipSpace(); p.done() {
+		return nil, p.errorAt(p.pos, "expected a condition")
+	}
+
+	if p.input[p.pos] != '(' {
+		return p.parseCondition()
+	}
+
+	if depth == maxDepth {
+		return nil, p.errorAt(p.pos, "parentheses are nested deeper than %d levels", maxDepth)
+	}
+	open := p.pos
+	p.pos++
+
+	expr, err := p.parseOr(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpace(); p.done() || p.input[p.pos] != ')' {
+		return nil, p.errorAt(open, "unclosed parenthesis")
+	}
+	p.pos++
+
+	return expr, nil
+}
+
+// parseCondition parses a field, an operator and its value or list of values
+func (p *parser) parseCondition() (Expr, error) {
+	start := p.pos
+	name := p.word()
+	if name == "" {
+		return nil, p.errorAt(start, "expected a field name")
+	}
+	kind, ok := fields[name]
+	if !ok {
+		return nil, p.errorAt(start, "unknown field %q", name)
+	}
+	cond := &Condition{Field: name, Kind: kind}
+
+	// The short forms field=value and field:value predate the operators
+	if p.skipSpace(); !p.done() && (p.input[p.pos] == '=' || p.input[p.pos] == ':') {
+		switch kind {
+		case Text:
+			cond.Op = Contains
+		case Bool:
+			cond.Op = Eq
+		default:
+			return nil, p.errorAt(p.pos, "%s can only be compared with gt or lt", name)
+		}
+		p.pos++
+
+		// A filter that starts with a short form not joined to another condition by and or or takes the
+		// rest of the input as its value, so values with spaces, commas or those words work as they always did
+		if rest := strings.TrimSpace(string(p.input[p.pos:])); strings.TrimSpace(string(p.input[:start])) == "" &&
+			!strings.HasPrefix(rest, `"`) && !p.conjunctionAhead() {
+			return cond, p.shortFormValue(cond, rest)
+		}
+		return cond, p.parseValue(cond)
+	}
+
+	opStart := p.pos
+	op := Operator(strings.ToLower(p.word()))
+	if op == "" {
+		return nil, p.errorAt(opStart, "expected an operator after %s", name)
+	}
+	if !slices.Contains(operators[kind], op) {
+		return nil, p.errorAt(opStart, "operator %q is not supported for %s", op, name)
+	}
+	cond.Op = op
+
+	if op != In {
+		return cond, p.parseValue(cond)
+	}
+
+	// in takes a parenthesized, comma separated list
+	if p.skipSpace(); p.done() || p.input[p.pos] != '(' {
+		return nil, p.errorAt(p.pos, "expected ( after in")
+	}
+	p.pos++
+	for {
+		if err := p.parseValue(cond); err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.done() {
+			return nil, p.errorAt(p.pos, "expected , or )")
+		}
+		if p.input[p.pos] == ')' {
+			p.pos++
+			return cond, nil
+		}
+		if p.input[p.pos] != ',' {
+			return nil, p.errorAt(p.pos, "expected , or )")
+		}
+		p.pos++
+	}
+}
+
+// parseValue reads the next value and stores it in cond according to the kind of its field
+func (p *parser) parseValue(cond *Condition) error {
+	p.skipSpace()
+	start := p.pos
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	switch cond.Kind {
+	case Bool:
+		cond.Bool, err = strconv.ParseBool(value)
+		if err != nil {
+			return p.errorAt(start, "%s must be true or false", cond.Field)
+		}
+	case Time:
+		cond.Time, err = parseTime(value)
+		if err != nil {
+			return p.errorAt(start, "%s must be an RFC 3339 timestamp or a date", cond.Field)
+		}
+	default:
+		cond.Text = append(cond.Text, value)
+	}
+
+	return nil
+}
+
+// shortFormValue stores the verbatim value of a legacy short form in cond and consumes the rest of the input
+func (p *parser) shortFormValue(cond *Condition, value string) error {
+	p.skipSpace()
+	start := p.pos
+	p.pos = len(p.input)
+
+	if cond.Kind == Bool {
+		var err error
+		cond.Bool, err = strconv.ParseBool(value)
+		if err != nil {
+			return p.errorAt(start, "%s must be true or false", cond.Field)
+		}
+		return nil
+	}
+	cond.Text = append(cond.Text, value)
+	return nil
+}
+
+// conjunctionAhead reports whether the rest of the input holds and or or as a separate word followed by
+// another condition; otherwise the words belong to the value, as in nickname=tom and jerry
+func (p *parser) conjunctionAhead() bool {
+	start := p.pos
+	defer func() { p.pos = start }()
+
+	for !p.done() {
+		if word := p.word(); strings.EqualFold(word, "and") || strings.EqualFold(word, "or") {
+			if p.conditionAhead() {
+				return true
+			}
+		} else if word == "" {
+			p.pos++
+		}
+	}
+	return false
+}
+
+// conditionAhead reports whether a parenthesis or a known field followed by an operator, = or : comes next,
+// leaving the position where it was
+func (p *parser) conditionAhead() bool {
+	start := p.pos
+	defer func() { p.pos = start }()
+
+	if p.skipSpace(); !p.done() && p.input[p.pos] == '(' {
+		return true
+	}
+	kind, ok := fields[p.word()]
+	if !ok {
+		return false
+	}
+	if p.skipSpace(); !p.done() && (p.input[p.pos] == '=' || p.input[p.pos] == ':') {
+		return true
+	}
+	return slices.Contains(operators[kind], Operator(strings.ToLower(p.word())))
+}
+
+// value reads a quoted string or a bare word
+func (p *parser) value() (string, error) {
+	start := p.pos
+	if p.done() {
+		return "", p.errorAt(start, "expected a value")
+	}
+
+	if p.input[p.pos] != '"' {
+		// Bare words end at whitespace, parentheses, commas and quotes
+		for !p.done() && !unicode.IsSpace(p.input[p.pos]) && !strings.ContainsRune(`(),"`, p.input[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			return "", p.errorAt(start, "expected a value")
+		}
+		return string(p.input[start:p.pos]), nil
+	}
+
+	var value strings.Builder
+	for p.pos++; !p.done(); p.pos++ {
+		switch c := p.input[p.pos]; c {
+		case '"':
+			p.pos++
+			return value.String(), nil
+		case '\\':
+			p.pos++
+			if p.done() || (p.input[p.pos] != '"' && p.input[p.pos] != '\\') {
+				return "", p.errorAt(p.pos, `only \" and \\ may be escaped`)
+			}
+			value.WriteRune(p.input[p.pos])
+		default:
+			value.WriteRune(c)
+		}
+	}
+
+	return "", p.errorAt(start, "unterminated string")
+}
+
+// word reads a field name, an operator or a keyword
+func (p *parser) word() string {
+	start := p.pos
+	for !p.done() && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '_') {
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
+
+// keyword consumes the given keyword, ignoring case, if it comes next
+func (p *parser) keyword(keyword string) bool {
+	p.skipSpace()
+	start := p.pos
+	if strings.EqualFold(p.word(), keyword) {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+// skipSpace advances past whitespace
+func (p *parser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// done reports whether the whole filter has been read
+func (p *parser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+// errorAt builds a SyntaxError for the character at index pos
+func (p *parser) errorAt(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates, which mean midnight UTC
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
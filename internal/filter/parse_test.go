@@ -0,0 +1,192 @@
+package filter
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		expected Expr
+	}{
+		{
+			name:     "Blank filter",
+			filter:   "  ",
+			expected: nil,
+		},
+		{
+			name:     "Short form with equals",
+			filter:   "nickname=alice",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"alice"}},
+		},
+		{
+			name:     "Short form with colon",
+			filter:   "nickname:testuser",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"testuser"}},
+		},
+		{
+			name:     "Short form on a yes/no field",
+			filter:   "email_verified=1",
+			expected: &Condition{Field: "email_verified", Kind: Bool, Op: Eq, Bool: true},
+		},
+		{
+			name:     "Short form takes the rest of the filter",
+			filter:   "first_name=John Doe ",
+			expected: &Condition{Field: "first_name", Kind: Text, Op: Contains, Text: []string{"John Doe"}},
+		},
+		{
+			name:     "Short form with a comma",
+			filter:   "nickname=a,b",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"a,b"}},
+		},
+		{
+			name:     "Short form without a value",
+			filter:   "nickname=",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{""}},
+		},
+		{
+			name:     "Short form with a word containing a keyword",
+			filter:   "last_name=Anderson Lord",
+			expected: &Condition{Field: "last_name", Kind: Text, Op: Contains, Text: []string{"Anderson Lord"}},
+		},
+		{
+			name:     "Short form with and in the value",
+			filter:   "nickname=tom and jerry",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"tom and jerry"}},
+		},
+		{
+			name:     "Short form with or in the value",
+			filter:   "last_name:Smith or Jones",
+			expected: &Condition{Field: "last_name", Kind: Text, Op: Contains, Text: []string{"Smith or Jones"}},
+		},
+		{
+			name:     "Short form with a keyword before a field name without operator",
+			filter:   "first_name=Ann and country",
+			expected: &Condition{Field: "first_name", Kind: Text, Op: Contains, Text: []string{"Ann and country"}},
+		},
+		{
+			name:   "Short form joined to an operator condition",
+			filter: "nickname=tom or country eq DE",
+			expected: Or{
+				&Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"tom"}},
+				&Condition{Field: "country", Kind: Text, Op: Eq, Text: []string{"DE"}},
+			},
+		},
+		{
+			name:   "Short forms joined by a keyword",
+			filter: "nickname=a AND country:b",
+			expected: And{
+				&Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"a"}},
+				&Condition{Field: "country", Kind: Text, Op: Contains, Text: []string{"b"}},
+			},
+		},
+		{
+			name:     "Short form in parentheses",
+			filter:   "(nickname=a)",
+			expected: &Condition{Field: "nickname", Kind: Text, Op: Contains, Text: []string{"a"}},
+		},
+		{
+			name:     "Operator ignores case",
+			filter:   "role NEQ admin",
+			expected: &Condition{Field: "role", Kind: Text, Op: Neq, Text: []string{"admin"}},
+		},
+		{
+			name:     "Quoted value with escapes",
+			filter:   `last_name eq "O\"Brien \\ Sons"`,
+			expected: &Condition{Field: "last_name", Kind: Text, Op: Eq, Text: []string{`O"Brien \ Sons`}},
+		},
+		{
+			name:     "Empty quoted value",
+			filter:   `country=""`,
+			expected: &Condition{Field: "country", Kind: Text, Op: Contains, Text: []string{""}},
+		},
+		{
+			name:     "In list",
+			filter:   `country in ( DE ,"New Zealand",at)`,
+			expected: &Condition{Field: "country", Kind: Text, Op: In, Text: []string{"DE", "New Zealand", "at"}},
+		},
+		{
+			name:   "Timestamps and dates",
+			filter: "created_at gt 2024-01-02T03:04:05Z and updated_at lt 2024-06-01",
+			expected: And{
+				&Condition{Field: "created_at", Kind: Time, Op: Gt, Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+				&Condition{Field: "updated_at", Kind: Time, Op: Lt, Time: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:   "And binds tighter than or",
+			filter: "nickname eq a OR nickname eq b AND country eq c",
+			expected: Or{
+				&Condition{Field: "nickname", Kind: Text, Op: Eq, Text: []string{"a"}},
+				And{
+					&Condition{Field: "nickname", Kind: Text, Op: Eq, Text: []string{"b"}},
+					&Condition{Field: "country", Kind: Text, Op: Eq, Text: []string{"c"}},
+				},
+			},
+		},
+		{
+			name:   "Parentheses group",
+			filter: "(nickname prefix a OR email contains b) AND email_verified neq false",
+			expected: And{
+				Or{
+					&Condition{Field: "nickname", Kind: Text, Op: Prefix, Text: []string{"a"}},
+					&Condition{Field: "email", Kind: Text, Op: Contains, Text: []string{"b"}},
+				},
+				&Condition{Field: "email_verified", Kind: Bool, Op: Neq, Bool: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, expr)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      string
+		expectedPos int
+		expectedMsg string
+	}{
+		{"Unknown field", "password=secret", 1, `unknown field "password"`},
+		{"Missing field", "= alice", 1, "expected a field name"},
+		{"Missing operator", "nickname", 9, "expected an operator after nickname"},
+		{"Unknown operator", "nickname like alice", 10, `operator "like" is not supported for nickname`},
+		{"Operator of another kind", "created_at eq 2024-01-01", 12, `operator "eq" is not supported for created_at`},
+		{"Short form on a timestamp", "created_at=2024-01-01", 11, "created_at can only be compared with gt or lt"},
+		{"Missing value", "nickname eq ", 13, "expected a value"},
+		{"Bad boolean", "email_verified=yes", 16, "email_verified must be true or false"},
+		{"Bad timestamp", "created_at gt yesterday", 15, "created_at must be an RFC 3339 timestamp or a date"},
+		{"Unterminated string", `nickname eq "alice`, 13, "unterminated string"},
+		{"Bad escape", `nickname eq "a\nb"`, 16, `only \" and \\ may be escaped`},
+		{"In without list", "country in DE", 12, "expected ( after in"},
+		{"Unclosed in list", "country in (DE AT)", 16, "expected , or )"},
+		{"Dangling and", "nickname eq a AND", 18, "expected a condition"},
+		{"Unclosed parenthesis", "(nickname=a OR nickname=b", 1, "unclosed parenthesis"},
+		{"Stray parenthesis", "nickname eq a)", 14, `unexpected ')'`},
+		{"Missing conjunction", "nickname eq a country eq b", 15, `unexpected 'c'`},
+		{"Positions count characters", "first_name eq Дмитрий )", 23, `unexpected ')'`},
+		{"Too deeply nested", "((((((((((((((((((nickname=a))))))))))))))))))", 17, "parentheses are nested deeper than 16 levels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.filter)
+
+			var syntaxErr *SyntaxError
+			require.True(t, errors.As(err, &syntaxErr), "expected a SyntaxError, got %v", err)
+			assert.Equal(t, tt.expectedPos, syntaxErr.Pos)
+			assert.Equal(t, tt.expectedMsg, syntaxErr.Msg)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"net/http"
+)
+
+// HandleLogin handles login requests
+// @Summary Log in
+// @Description Verify a nickname or email and password and issue a signed access token and a refresh token.
+// @Description Repeated failures for an account or from a client address delay further attempts and eventually lock them out; refused attempts carry a Retry-After header.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param credentials body dtos.LoginRequest true "Login credentials"
+// @Success 200 {object} dtos.LoginResponse
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload"
+// @Failure 401 {object} dtos.ErrorResponse "Invalid login or password"
+// @Failure 403 {object} dtos.ErrorResponse "Email not verified while verification is required"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 429 {object} dtos.ErrorResponse "Too many failed attempts, retry after the Retry-After header"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to log in"
+// @Router /auth/login [post]
+func (h *Handler) HandleLogin(c echo.Context) error {
+	// Bind the incoming JSON request to LoginRequest struct
+	var loginReq dtos.LoginRequest
+	if err := c.Bind(&loginReq); err != nil {
+		log.Warnf("[HandleLogin] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&loginReq); err != nil {
+		log.Warnf("[HandleLogin] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Verify the credentials and issue a token via the service layer
+	loginResp, err := h.services.Login(loginReq, c.RealIP())
+	if err != nil {
+		log.Warnf("[HandleLogin] Unable to log in %s: %s", loginReq.Login, err)
+		return err
+	}
+
+	log.Infof("[HandleLogin] Successfully logged in %s", loginReq.Login)
+	return c.JSON(http.StatusOK, loginResp)
+}
+
+// HandleRefresh handles token refresh requests
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param token body dtos.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dtos.LoginResponse
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload"
+// @Failure 401 {object} dtos.ErrorResponse "Invalid, expired, revoked or reused refresh token"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to refresh tokens"
+// @Router /auth/refresh [post]
+func (h *Handler) HandleRefresh(c echo.Context) error {
+	// Bind the incoming JSON request to RefreshTokenRequest struct
+	var refreshReq dtos.RefreshTokenRequest
+	if err := c.Bind(&refreshReq); err != nil {
+		log.Warnf("[HandleRefresh] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&refreshReq); err != nil {
+		log.Warnf("[HandleRefresh] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Rotate the tokens via the service layer
+	refreshResp, err := h.services.Refresh(refreshReq)
+	if err != nil {
+		log.Warnf("[HandleRefresh] Unable to refresh tokens: %s", err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, refreshResp)
+}
+
+// HandleLogout handles requests to end a single session
+// @Summary Log out
+// @Description Revoke the session the refresh token belongs to. Access tokens already issued stay valid until they expire.
+// @Tags auth
+// @Accept  json
+// @Param token body dtos.RefreshTokenRequest true "Refresh token"
+// @Success 204 "Session ended"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to log out"
+// @Router /auth/logout [post]
+func (h *Handler) HandleLogout(c echo.Context) error {
+	// Bind the incoming JSON request to RefreshTokenRequest struct
+	var logoutReq dtos.RefreshTokenRequest
+	if err := c.Bind(&logoutReq); err != nil {
+		log.Warnf("[HandleLogout] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&logoutReq); err != nil {
+		log.Warnf("[HandleLogout] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Revoke the session via the service layer
+	if err := h.services.Logout(logoutReq); err != nil {
+		log.Warnf("[HandleLogout] Unable to log out: %s", err)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandleLogoutAll handles requests to end every session of the authenticated user
+// @Summary Log out everywhere
+// @Description Revoke every refresh token of the user identified by the access token. Access tokens already issued stay valid until they expire.
+// @Tags auth
+// @Security BearerAuth
+// @Success 204 "All sessions ended"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to log out"
+// @Router /auth/logout-all [post]
+func (h *Handler) HandleLogoutAll(c echo.Context) error {
+	// The Authenticate middleware guarantees the caller's ID is present
+	id, _ := auth.UserIDFromContext(c.Request().Context())
+
+	// Revoke every session of the caller via the service layer
+	if err := h.services.LogoutAll(id.String()); err != nil {
+		log.Warnf("[HandleLogoutAll] Unable to log out user %s: %s", id, err)
+		return err
+	}
+
+	log.Infof("[HandleLogoutAll] Ended all sessions of user %s", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandleMe handles requests for the authenticated user's own profile
+// @Summary Get the current user
+// @Description Retrieve the user identified by the access token
+// @Tags auth
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} dtos.GetUserDTO
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to get user"
+// @Router /auth/me [get]
+func (h *Handler) HandleMe(c echo.Context) error {
+	// The Authenticate middleware guarantees the caller's ID is present
+	id, _ := auth.UserIDFromContext(c.Request().Context())
+
+	userResp, err := h.services.GetUser(id.String(), false)
+	if err != nil {
+		log.Warnf("[HandleMe] Unable to get user with id %s: %s", id, err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, userResp)
+}
+
+// HandleRequestPasswordReset handles requests to send a password reset link
+// @Summary Request a password reset
+// @Description Send a single-use password reset link to the email. The response is the same whether or not the email is registered.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param email body dtos.PasswordResetRequest true "Account email"
+// @Success 202 {object} map[string]string "Reset link sent if the email is registered"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to request a password reset"
+// @Router /auth/password-reset/request [post]
+func (h *Handler) HandleRequestPasswordReset(c echo.Context) error {
+	// Bind the incoming JSON request to PasswordResetRequest struct
+	var resetReq dtos.PasswordResetRequest
+	if err := c.Bind(&resetReq); err != nil {
+		log.Warnf("[HandleRequestPasswordReset] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&resetReq); err != nil {
+		log.Warnf("[HandleRequestPasswordReset] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Send the reset link via the service layer
+	if err := h.services.RequestPasswordReset(resetReq); err != nil {
+		log.Errorf("[HandleRequestPasswordReset] Unable to request a password reset: %s", err)
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// HandleConfirmPasswordReset handles requests to set a new password with a reset token
+// @Summary Confirm a password reset
+// @Description Set a new password using the token from a reset link. The token works once and every session of the user is ended.
+// @Tags auth
+// @Accept  json
+// @Param reset body dtos.PasswordResetConfirmRequest true "Reset token and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload, or invalid or expired token"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed or new password breaks the policy"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to reset password"
+// @Router /auth/password-reset/confirm [post]
+func (h *Handler) HandleConfirmPasswordReset(c echo.Context) error {
+	// Bind the incoming JSON request to PasswordResetConfirmRequest struct
+	var confirmReq dtos.PasswordResetConfirmRequest
+	if err := c.Bind(&confirmReq); err != nil {
+		log.Warnf("[HandleConfirmPasswordReset] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&confirmReq); err != nil {
+		log.Warnf("[HandleConfirmPasswordReset] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Set the new password via the service layer
+	if err := h.services.ConfirmPasswordReset(confirmReq); err != nil {
+		log.Warnf("[HandleConfirmPasswordReset] Unable to reset password: %s", err)
+		return err
+	}
+
+	log.Info("[HandleConfirmPasswordReset] Password was reset")
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errorMapping describes how a domain error is presented to API clients
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings lists the domain errors with a dedicated HTTP status and stable error code
+var errorMappings = []errorMapping{
+	{apperrors.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{apperrors.ErrUserNotDeleted, http.StatusConflict, "user_not_deleted"},
+	{apperrors.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+	{apperrors.ErrNicknameTaken, http.StatusConflict, "nickname_taken"},
+	{apperrors.ErrEmailTaken, http.StatusConflict, "email_taken"},
+	{apperrors.ErrInvalidID, http.StatusBadRequest, "invalid_id"},
+	{apperrors.ErrInvalidPagination, http.StatusBadRequest, "invalid_pagination"},
+	{apperrors.ErrInvalidPayload, http.StatusBadRequest, "invalid_payload"},
+	{apperrors.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{apperrors.ErrPatchConflict, http.StatusConflict, "patch_conflict"},
+	{apperrors.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	{apperrors.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{apperrors.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{apperrors.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+	{apperrors.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{apperrors.ErrInvalidToken, http.StatusBadRequest, "invalid_token"},
+	{apperrors.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter"},
+	{apperrors.ErrInvalidSort, http.StatusBadRequest, "invalid_sort"},
+	{apperrors.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{apperrors.ErrEmailNotVerified, http.StatusForbidden, "email_not_verified"},
+	{apperrors.ErrTooManyAttempts, http.StatusTooManyRequests, "too_many_attempts"},
+	{apperrors.ErrWeakPassword, http.StatusUnprocessableEntity, "weak_password"},
+	{apperrors.ErrIncorrectPassword, http.StatusForbidden, "incorrect_password"},
+}
+
+// validatable is implemented by request DTOs that check their own fields
+type validatable interface {
+	Validate() error
+}
+
+// validateRequest validates req; invalid fields are reported as dtos.FieldErrors wrapped in apperrors.ErrValidation
+func validateRequest(req validatable) error {
+	if err := req.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", apperrors.ErrValidation, err)
+	}
+	return nil
+}
+
+// HTTPErrorHandler converts errors returned by handlers into a consistent JSON error response
+func (h *Handler) HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, resp := errorResponse(err)
+
+	// Tell throttled clients when to try again
+	var throttled *apperrors.ThrottledError
+	if errors.As(err, &throttled) {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+	}
+	if status >= http.StatusInternalServerError {
+		log.Errorf("[HTTPErrorHandler] %s %s failed: %s", c.Request().Method, c.Path(), err)
+	}
+
+	// HEAD requests must not carry a body
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, resp)
+	}
+	if err != nil {
+		log.Errorf("[HTTPErrorHandler] Unable to write error response: %s", err)
+	}
+}
+
+// errorResponse resolves the HTTP status and response body for the given error
+func errorResponse(err error) (int, dtos.ErrorResponse) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			resp := dtos.ErrorResponse{Error: err.Error(), Code: m.code}
+			// Validation failures also list every invalid field
+			var fieldErrors dtos.FieldErrors
+			if errors.As(err, &fieldErrors) {
+				resp.Errors = fieldErrors
+			}
+			// Filter syntax errors also point at the offending character
+			var syntaxErr *filter.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				resp.Position = syntaxErr.Pos
+			}
+			return m.status, resp
+		}
+	}
+
+	// Errors raised by Echo itself, e.g. unknown routes or bad methods
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code, dtos.ErrorResponse{
+			Error: fmt.Sprint(httpErr.Message),
+			Code:  strings.ReplaceAll(strings.ToLower(http.StatusText(httpErr.Code)), " ", "_"),
+		}
+	}
+
+	// Internal details are logged but never exposed to clients
+	return http.StatusInternalServerError, dtos.ErrorResponse{Error: "internal server error", Code: "internal_error"}
+}
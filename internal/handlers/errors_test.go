@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedErrors dtos.FieldErrors
+		expectedPos    int
+	}{
+		{
+			name:           "Not found",
+			err:            apperrors.ErrUserNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   "user_not_found",
+		},
+		{
+			name:           "Wrapped invalid id",
+			err:            fmt.Errorf("%w: invalid UUID length: 3", apperrors.ErrInvalidID),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_id",
+		},
+		{
+			name:           "Restoring a user that is not deleted",
+			err:            apperrors.ErrUserNotDeleted,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "user_not_deleted",
+		},
+		{
+			name:           "Stale version",
+			err:            fmt.Errorf("%w: If-Match \"3\" does not match the current version", apperrors.ErrVersionMismatch),
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedCode:   "version_mismatch",
+		},
+		{
+			name:           "Invalid fields",
+			err:            fmt.Errorf("%w: %w", apperrors.ErrValidation, dtos.FieldErrors{"email": {Code: "validation_is_email", Message: "must be a valid email address"}}),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "validation_failed",
+			expectedErrors: dtos.FieldErrors{"email": {Code: "validation_is_email", Message: "must be a valid email address"}},
+		},
+		{
+			name:           "Filter syntax error",
+			err:            fmt.Errorf("%w: %w", apperrors.ErrInvalidFilter, &filter.SyntaxError{Pos: 7, Msg: "expected a value"}),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_filter",
+			expectedPos:    7,
+		},
+		{
+			name:           "Unknown sort field",
+			err:            fmt.Errorf("%w: users cannot be sorted by \"password\"", apperrors.ErrInvalidSort),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_sort",
+		},
+		{
+			name:           "Forged cursor",
+			err:            fmt.Errorf("%w: malformed or tampered cursor", apperrors.ErrInvalidCursor),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_cursor",
+		},
+		{
+			name:           "Unsupported patch format",
+			err:            fmt.Errorf("%w: text/plain", apperrors.ErrUnsupportedMediaType),
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedCode:   "unsupported_media_type",
+		},
+		{
+			name:           "Failed patch test",
+			err:            fmt.Errorf("%w: test of /nickname failed", apperrors.ErrPatchConflict),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "patch_conflict",
+		},
+		{
+			name:           "Nickname conflict",
+			err:            apperrors.ErrNicknameTaken,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "nickname_taken",
+		},
+		{
+			name:           "Email conflict",
+			err:            apperrors.ErrEmailTaken,
+			expectedStatus: http.StatusConflict,
+			expectedCode:   "email_taken",
+		},
+		{
+			name:           "Invalid credentials",
+			err:            apperrors.ErrInvalidCredentials,
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "invalid_credentials",
+		},
+		{
+			name:           "Wrapped unauthorized",
+			err:            fmt.Errorf("%w: token is expired", apperrors.ErrUnauthorized),
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   "unauthorized",
+		},
+		{
+			name:           "Wrapped invalid token",
+			err:            fmt.Errorf("%w: user no longer exists", apperrors.ErrInvalidToken),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_token",
+		},
+		{
+			name:           "Email not verified",
+			err:            apperrors.ErrEmailNotVerified,
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   "email_not_verified",
+		},
+		{
+			name:           "Throttled login",
+			err:            &apperrors.ThrottledError{RetryAfter: 1500 * time.Millisecond},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedCode:   "too_many_attempts",
+		},
+		{
+			name:           "Echo error",
+			err:            echo.ErrMethodNotAllowed,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedCode:   "method_not_allowed",
+		},
+		{
+			name:           "Unknown error",
+			err:            errors.New("connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   "internal_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := errorResponse(tt.err)
+
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			assert.Equal(t, tt.expectedErrors, resp.Errors)
+			assert.Equal(t, tt.expectedPos, resp.Position)
+			assert.NotEmpty(t, resp.Error)
+		})
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            validatable
+		expectedErrors dtos.FieldErrors
+	}{
+		{
+			name: "Valid create request",
+			req: &dtos.CreateUserRequest{FirstName: "Alice", LastName: "Liddell", Nickname: "alice",
+				Password: "password123", Email: "alice@example.com", Country: "England"},
+		},
+		{
+			name: "Every invalid field of a create request",
+			req:  &dtos.CreateUserRequest{Nickname: "alice", Password: "password123", Email: "alice", Country: "England"},
+			expectedErrors: dtos.FieldErrors{
+				"first_name": {Code: "validation_required", Message: "cannot be blank"},
+				"last_name":  {Code: "validation_required", Message: "cannot be blank"},
+				"email":      {Code: "validation_is_email", Message: "must be a valid email address"},
+			},
+		},
+		{
+			name: "Update request with empty strings",
+			req:  &dtos.UpdateUserRequest{FirstName: "Alice", Nickname: "", Email: ""},
+			expectedErrors: dtos.FieldErrors{
+				"nickname": {Code: "validation_required", Message: "cannot be blank"},
+				"email":    {Code: "validation_required", Message: "cannot be blank"},
+			},
+		},
+		{
+			name:           "Update request with an invalid email",
+			req:            &dtos.UpdateUserRequest{Nickname: "alice", Email: "not-an-email"},
+			expectedErrors: dtos.FieldErrors{"email": {Code: "validation_is_email", Message: "must be a valid email address"}},
+		},
+		{
+			name:           "Role with a bad format",
+			req:            &dtos.SetRoleRequest{Role: "Admin!"},
+			expectedErrors: dtos.FieldErrors{"role": {Code: "validation_match_invalid", Message: "must be in a valid format"}},
+		},
+		{
+			name:           "Login without password",
+			req:            &dtos.LoginRequest{Login: "alice"},
+			expectedErrors: dtos.FieldErrors{"password": {Code: "validation_required", Message: "cannot be blank"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRequest(tt.req)
+			if tt.expectedErrors == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, apperrors.ErrValidation)
+			status, resp := errorResponse(err)
+			assert.Equal(t, http.StatusUnprocessableEntity, status)
+			assert.Equal(t, tt.expectedErrors, resp.Errors)
+		})
+	}
+}
+
+func TestHTTPErrorHandlerFieldErrors(t *testing.T) {
+	h := &Handler{}
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPut, "/users/1", nil), rec)
+	h.HTTPErrorHandler(validateRequest(&dtos.UpdateUserRequest{Nickname: "alice", Email: "alice"}), c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.JSONEq(t, `{
+		"error": "validation failed: email: must be a valid email address.",
+		"code": "validation_failed",
+		"errors": {"email": {"code": "validation_is_email", "message": "must be a valid email address"}}
+	}`, rec.Body.String())
+}
+
+func TestHTTPErrorHandlerRetryAfter(t *testing.T) {
+	h := &Handler{}
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/auth/login", nil), rec)
+	h.HTTPErrorHandler(&apperrors.ThrottledError{RetryAfter: 1500 * time.Millisecond}, c)
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "retry in 2 seconds")
+}
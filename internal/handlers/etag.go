@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// setETag sends the user's version as a strong entity tag
+func setETag(c echo.Context, version int64) {
+	c.Response().Header().Set(headerETag, `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatchVersion reads the version the If-Match header expects; 0 means the request is unconditional.
+// Only a single tag previously returned by this service can match, weak tags never do.
+func ifMatchVersion(c echo.Context) (int64, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if len(header) > 2 && strings.HasPrefix(header, `"`) && strings.HasSuffix(header, `"`) {
+		if version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: If-Match %s does not match the current version", apperrors.ErrVersionMismatch, header)
+}
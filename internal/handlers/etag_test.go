@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		expected    int64
+		expectedErr error
+	}{
+		{name: "Absent", ifMatch: "", expected: 0},
+		{name: "Any version", ifMatch: "*", expected: 0},
+		{name: "Strong tag", ifMatch: `"7"`, expected: 7},
+		{name: "Surrounding spaces", ifMatch: ` "7" `, expected: 7},
+		{name: "Weak tag never matches", ifMatch: `W/"7"`, expectedErr: apperrors.ErrVersionMismatch},
+		{name: "Unquoted", ifMatch: "7", expectedErr: apperrors.ErrVersionMismatch},
+		{name: "Several tags", ifMatch: `"6", "7"`, expectedErr: apperrors.ErrVersionMismatch},
+		{name: "Foreign tag", ifMatch: `"abc"`, expectedErr: apperrors.ErrVersionMismatch},
+		{name: "Zero", ifMatch: `"0"`, expectedErr: apperrors.ErrVersionMismatch},
+		{name: "Empty tag", ifMatch: `""`, expectedErr: apperrors.ErrVersionMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/users/1", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set(headerIfMatch, tt.ifMatch)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			version, err := ifMatchVersion(c)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, version)
+		})
+	}
+}
+
+func TestSetETag(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/users/1", nil), rec)
+
+	setETag(c, 42)
+
+	assert.Equal(t, `"42"`, rec.Header().Get(headerETag))
+}
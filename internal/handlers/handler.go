@@ -3,36 +3,78 @@ package handlers
 import (
 	"github.com/labstack/echo/v4"
 	_ "github.com/sosshik/users-service/docs"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/health"
+	"github.com/sosshik/users-service/internal/models"
 	"github.com/sosshik/users-service/internal/service"
 	echoSwagger "github.com/swaggo/echo-swagger"
-	"net/http"
 )
 
 type Handler struct {
 	services *service.Service
+	checker  *health.Checker
+	tokens   *auth.TokenManager
+	cfg      config.Config
 }
 
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+func NewHandler(services *service.Service, checker *health.Checker, tokens *auth.TokenManager, cfg config.Config) *Handler {
+	return &Handler{services: services, checker: checker, tokens: tokens, cfg: cfg}
 }
 
 func (h *Handler) InitRoutes() *echo.Echo {
 	e := echo.New()
+	e.HTTPErrorHandler = h.HTTPErrorHandler
 
-	e.GET("/health", func(c echo.Context) error {
-		return c.String(http.StatusOK, "Service is healthy:)")
-	})
+	// Apply server timeouts so slow clients cannot hold connections forever
+	e.Server.ReadTimeout = h.cfg.HTTP.ReadTimeout
+	e.Server.ReadHeaderTimeout = h.cfg.HTTP.ReadHeaderTimeout
+	e.Server.WriteTimeout = h.cfg.HTTP.WriteTimeout
+	e.Server.IdleTimeout = h.cfg.HTTP.IdleTimeout
+
+	// Failed logins are counted per client address, so it must not be spoofable with headers
+	e.IPExtractor = echo.ExtractIPDirect()
+	if h.cfg.HTTP.TrustProxyHeaders {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	}
+
+	e.GET("/livez", h.HandleLivez)
+	e.GET("/readyz", h.HandleReadyz)
+	e.GET("/health", h.HandleHealth)
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	a := e.Group("/auth")
+
+	{
+		a.POST("/login", h.HandleLogin)
+		a.POST("/refresh", h.HandleRefresh)
+		a.POST("/logout", h.HandleLogout)
+		a.POST("/logout-all", h.HandleLogoutAll, h.Authenticate)
+		a.GET("/me", h.HandleMe, h.Authenticate)
+		a.POST("/password-reset/request", h.HandleRequestPasswordReset)
+		a.POST("/password-reset/confirm", h.HandleConfirmPasswordReset)
+	}
+
 	g := e.Group("/users")
+	adminOnly := h.RequireRole(models.RoleAdmin)
 
 	{
+		// Registration and email verification links stay open; everything else needs an access token
 		g.POST("", h.HandleCreateUser)
-		g.PUT("/:id", h.HandleUpdateUser)
-		g.DELETE("/:id", h.HandleDeleteUser)
-		g.GET("", h.HandleGetUsers)
-		g.GET("/:id", func(c echo.Context) error { return nil })
+		g.POST("/verify-email", h.HandleVerifyEmail)
+		g.POST("/:id/verification-email", h.HandleResendVerificationEmail, h.Authenticate, h.RequireSelfOrAdmin)
+		g.PUT("/:id", h.HandleUpdateUser, h.Authenticate, h.RequireSelfOrAdmin)
+		g.PATCH("/:id", h.HandlePatchUser, h.Authenticate, h.RequireSelfOrAdmin)
+		g.PUT("/:id/role", h.HandleSetUserRole, h.Authenticate, adminOnly)
+		g.POST("/:id/unlock", h.HandleUnlockUser, h.Authenticate, adminOnly)
+		g.POST("/:id/logout-all", h.HandleLogoutUser, h.Authenticate, h.RequireSelfOrAdmin)
+		g.POST("/:id/password", h.HandleChangePassword, h.Authenticate, h.RequireSelfOrAdmin)
+		g.DELETE("/:id", h.HandleDeleteUser, h.Authenticate, h.RequireSelfOrAdmin)
+		g.POST("/:id/restore", h.HandleRestoreUser, h.Authenticate, adminOnly)
+		g.DELETE("/:id/permanent", h.HandleHardDeleteUser, h.Authenticate, adminOnly)
+		g.GET("", h.HandleGetUsers, h.Authenticate, adminOnly)
+		g.GET("/:id", h.HandleGetUser, h.Authenticate, h.RequireSelfOrAdmin)
 	}
 
 	return e
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/health"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"net/http"
+)
+
+// HandleLivez reports whether the process is alive
+// @Summary Liveness probe
+// @Description Reports that the process is running and able to answer requests. It never checks dependencies, so a failing database does not cause restarts.
+// @Tags health
+// @Produce  json
+// @Success 200 {object} dtos.LivenessResponse
+// @Router /livez [get]
+func (h *Handler) HandleLivez(c echo.Context) error {
+	return c.JSON(http.StatusOK, dtos.LivenessResponse{Status: health.StatusOK})
+}
+
+// HandleReadyz reports whether the service should receive traffic
+// @Summary Readiness probe
+// @Description Runs every registered readiness check (storage, startup, draining) and reports per-check status and latency
+// @Tags health
+// @Produce  json
+// @Success 200 {object} dtos.ReadinessResponse
+// @Failure 503 {object} dtos.ReadinessResponse "At least one check failed"
+// @Router /readyz [get]
+func (h *Handler) HandleReadyz(c echo.Context) error {
+	report := h.checker.Run(c.Request().Context())
+	if report.Status != health.StatusOK {
+		log.Warnf("[HandleReadyz] Service is not ready: %+v", report.Checks)
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// HandleHealth reports readiness as plain text
+// @Summary Health check
+// @Description Deprecated: use /readyz. Returns 200 when all readiness checks pass and 503 otherwise.
+// @Tags health
+// @Produce  plain
+// @Success 200 {string} string "Service is healthy:)"
+// @Failure 503 {string} string "Service is unavailable"
+// @Deprecated
+// @Router /health [get]
+func (h *Handler) HandleHealth(c echo.Context) error {
+	if report := h.checker.Run(c.Request().Context()); report.Status != health.StatusOK {
+		return c.String(http.StatusServiceUnavailable, "Service is unavailable")
+	}
+
+	return c.String(http.StatusOK, "Service is healthy:)")
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+)
+
+// bearerPrefix is the Authorization scheme carrying access tokens; it is matched case-insensitively
+const bearerPrefix = "Bearer "
+
+// Authenticate rejects requests without a valid access token and stores the caller
+// in the request context, where auth.PrincipalFromContext can read it
+func (h *Handler) Authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return fmt.Errorf("%w: missing bearer token", apperrors.ErrUnauthorized)
+		}
+
+		claims, err := h.tokens.Parse(header[len(bearerPrefix):])
+		if err != nil {
+			log.Warnf("[Authenticate] Rejected access token: %s", err)
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return err
+		}
+
+		ctx := auth.ContextWithPrincipal(c.Request().Context(), claims.Principal())
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
+
+// refreshPrincipal replaces the role from the access token with the caller's current role, so demotions and
+// deletions take effect before the token expires. Callers that were deleted are rejected as unauthenticated.
+func (h *Handler) refreshPrincipal(c echo.Context) (auth.Principal, error) {
+	principal, _ := auth.PrincipalFromContext(c.Request().Context())
+
+	user, err := h.services.GetUser(principal.UserID.String(), false)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		log.Warnf("[refreshPrincipal] Rejected access token of deleted user %s", principal.UserID)
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return auth.Principal{}, fmt.Errorf("%w: the account no longer exists", apperrors.ErrUnauthorized)
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	// Later handlers read the role from the context too
+	principal.Role = user.Role
+	c.SetRequest(c.Request().WithContext(auth.ContextWithPrincipal(c.Request().Context(), principal)))
+
+	return principal, nil
+}
+
+// RequireRole allows only callers that currently have one of the given roles; it must run after Authenticate
+func (h *Handler) RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := h.refreshPrincipal(c)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(roles, principal.Role) {
+				log.Warnf("[RequireRole] User %s with role %q denied %s %s", principal.UserID, principal.Role, c.Request().Method, c.Path())
+				return fmt.Errorf("%w: requires role %s", apperrors.ErrForbidden, strings.Join(roles, " or "))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireSelfOrAdmin allows current admins and the user whose ID is the :id path parameter, unless
+// they were deleted; it must run after Authenticate
+func (h *Handler) RequireSelfOrAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, err := h.refreshPrincipal(c)
+		if err != nil {
+			return err
+		}
+		if principal.Role == models.RoleAdmin {
+			return next(c)
+		}
+
+		if id, err := uuid.Parse(c.Param("id")); err == nil && id == principal.UserID {
+			return next(c)
+		}
+
+		log.Warnf("[RequireSelfOrAdmin] User %s denied %s %s for user %s", principal.UserID, c.Request().Method, c.Path(), c.Param("id"))
+		return fmt.Errorf("%w: users may only access their own account", apperrors.ErrForbidden)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/service"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokens, err := auth.NewTokenManager(config.Default().Auth)
+	require.NoError(t, err)
+	h := &Handler{tokens: tokens}
+
+	userID := uuid.New()
+	token, _, err := tokens.Issue(userID, models.RoleUser)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedErr   error
+		expectedAuth  string
+	}{
+		{name: "Valid token", authorization: "Bearer " + token},
+		{name: "Lowercase scheme", authorization: "bearer " + token},
+		{name: "Missing header", expectedErr: apperrors.ErrUnauthorized, expectedAuth: "Bearer"},
+		{name: "Other scheme", authorization: "Basic dXNlcjpwYXNz", expectedErr: apperrors.ErrUnauthorized, expectedAuth: "Bearer"},
+		{name: "Empty token", authorization: "Bearer ", expectedErr: apperrors.ErrUnauthorized, expectedAuth: "Bearer"},
+		{name: "Invalid token", authorization: "Bearer " + token + "x", expectedErr: apperrors.ErrUnauthorized, expectedAuth: `Bearer error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+
+			var gotID uuid.UUID
+			err := h.Authenticate(func(c echo.Context) error {
+				gotID, _ = auth.UserIDFromContext(c.Request().Context())
+				return nil
+			})(c)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, tt.expectedAuth, rec.Header().Get(echo.HeaderWWWAuthenticate))
+				assert.Equal(t, uuid.Nil, gotID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, userID, gotID)
+		})
+	}
+}
+
+// stubUsers serves the current roles of the stored users; users missing from roles do not exist
+type stubUsers struct {
+	service.Users
+	roles map[uuid.UUID]string
+}
+
+func (u *stubUsers) GetUser(idStr string, _ bool) (dtos.GetUserDTO, error) {
+	id := uuid.MustParse(idStr)
+	role, found := u.roles[id]
+	if !found {
+		return dtos.GetUserDTO{}, apperrors.ErrUserNotFound
+	}
+	return dtos.GetUserDTO{ID: id, Role: role}, nil
+}
+
+// handlerWithUsers creates a Handler whose users currently have the given roles
+func handlerWithUsers(roles map[uuid.UUID]string) *Handler {
+	return &Handler{services: &service.Service{Users: &stubUsers{roles: roles}}}
+}
+
+// runAuthorized runs middleware for a request made by principal to a route with the given :id parameter
+// and reports whether the wrapped handler was reached
+func runAuthorized(middleware echo.MiddlewareFunc, principal auth.Principal, id string) (bool, error) {
+	req := httptest.NewRequest(http.MethodPut, "/users/"+id, nil)
+	req = req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+
+	reached := false
+	err := middleware(func(c echo.Context) error {
+		reached = true
+		return nil
+	})(c)
+
+	return reached, err
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		tokenRole   string
+		currentRole string
+		deleted     bool
+		expectedErr error
+	}{
+		{name: "Admin", tokenRole: models.RoleAdmin, currentRole: models.RoleAdmin},
+		{name: "Custom role", tokenRole: "support", currentRole: "support"},
+		{name: "User", tokenRole: models.RoleUser, currentRole: models.RoleUser, expectedErr: apperrors.ErrForbidden},
+		{name: "No role", tokenRole: "", currentRole: "", expectedErr: apperrors.ErrForbidden},
+		{name: "Promoted since the token was issued", tokenRole: models.RoleUser, currentRole: models.RoleAdmin},
+		{name: "Demoted since the token was issued", tokenRole: models.RoleAdmin, currentRole: models.RoleUser, expectedErr: apperrors.ErrForbidden},
+		{name: "Deleted since the token was issued", tokenRole: models.RoleAdmin, deleted: true, expectedErr: apperrors.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := uuid.New()
+			roles := map[uuid.UUID]string{}
+			if !tt.deleted {
+				roles[caller] = tt.currentRole
+			}
+			middleware := handlerWithUsers(roles).RequireRole(models.RoleAdmin, "support")
+
+			reached, err := runAuthorized(middleware, auth.Principal{UserID: caller, Role: tt.tokenRole}, "")
+
+			assert.Equal(t, tt.expectedErr == nil, reached)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestRequireSelfOrAdmin(t *testing.T) {
+	self := uuid.New()
+
+	tests := []struct {
+		name        string
+		tokenRole   string
+		currentRole string
+		deleted     bool
+		id          string
+		expectedErr error
+	}{
+		{name: "Own account", tokenRole: models.RoleUser, currentRole: models.RoleUser, id: self.String()},
+		{name: "Own account in upper case", tokenRole: models.RoleUser, currentRole: models.RoleUser, id: strings.ToUpper(self.String())},
+		{name: "Other account", tokenRole: models.RoleUser, currentRole: models.RoleUser, id: uuid.NewString(), expectedErr: apperrors.ErrForbidden},
+		{name: "Invalid ID", tokenRole: models.RoleUser, currentRole: models.RoleUser, id: "not-a-uuid", expectedErr: apperrors.ErrForbidden},
+		{name: "Custom role on other account", tokenRole: "support", currentRole: "support", id: uuid.NewString(), expectedErr: apperrors.ErrForbidden},
+		{name: "Admin on other account", tokenRole: models.RoleAdmin, currentRole: models.RoleAdmin, id: uuid.NewString()},
+		{name: "Demoted admin on other account", tokenRole: models.RoleAdmin, currentRole: models.RoleUser, id: uuid.NewString(), expectedErr: apperrors.ErrForbidden},
+		{name: "Deleted user on own account", tokenRole: models.RoleUser, deleted: true, id: self.String(), expectedErr: apperrors.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles := map[uuid.UUID]string{}
+			if !tt.deleted {
+				roles[self] = tt.currentRole
+			}
+			h := handlerWithUsers(roles)
+
+			reached, err := runAuthorized(h.RequireSelfOrAdmin, auth.Principal{UserID: self, Role: tt.tokenRole}, tt.id)
+
+			assert.Equal(t, tt.expectedErr == nil, reached)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
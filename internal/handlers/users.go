@@ -4,8 +4,14 @@ import (
 	"fmt"
 	"github.com/labstack/echo/v4"
 	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/models"
 	"github.com/sosshik/users-service/pkg/dtos"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
 )
 
 // HandleCreateUser handles user creation requests
@@ -16,29 +22,30 @@ import (
 // @Produce  json
 // @Param user body dtos.CreateUserRequest true "User data"
 // @Success 200 {object} dtos.CreateUserResponse
-// @Failure 422 {object} map[string]string "Invalid request payload"
-// @Failure 500 {object} map[string]string "Unable to create user"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload"
+// @Failure 409 {object} dtos.ErrorResponse "Nickname or email already taken"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed or password breaks the policy"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to create user"
 // @Router /users [post]
 func (h *Handler) HandleCreateUser(c echo.Context) error {
 	// Bind the incoming JSON request to CreateUserRequest struct
 	var userReq dtos.CreateUserRequest
 	if err := c.Bind(&userReq); err != nil {
 		log.Warnf("[HandleCreateUser] Unable to decode JSON: %s", err)
-		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Invalid request payload"})
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
 	}
 
 	// Validate the request data
-	err := userReq.Validate()
-	if err != nil {
+	if err := validateRequest(&userReq); err != nil {
 		log.Warnf("[HandleCreateUser] Invalid request payload: %s", err)
-		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Invalid request payload"})
+		return err
 	}
 
 	// Create the user via the service layer
 	userResp, err := h.services.CreateUser(userReq)
 	if err != nil {
 		log.Warnf("[HandleCreateUser] Unable to create user: %s", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Unable to create user: %s", err)})
+		return err
 	}
 
 	// Log success and return the created user response
@@ -47,52 +54,340 @@ func (h *Handler) HandleCreateUser(c echo.Context) error {
 }
 
 // HandleUpdateUser handles user update requests
-// @Summary Update an existing user
-// @Description Update the user with the given ID
+// @Summary Replace an existing user's profile
+// @Description Replace the profile of the user with the given ID; omitted fields are cleared, use PATCH to change single fields. Send the ETag of the last read as If-Match to avoid overwriting someone else's changes.
 // @Tags users
 // @Accept  json
 // @Produce  json
+// @Security BearerAuth
 // @Param id path string true "User ID"
+// @Param If-Match header string false "ETag the update is based on"
 // @Param user body dtos.UpdateUserRequest true "Updated user data"
 // @Success 200 {object} dtos.UpdateUserResponse
-// @Failure 400 {object} map[string]string "Invalid request payload"
-// @Failure 500 {object} map[string]string "Unable to update user"
+// @Header 200 {string} ETag "Version of the updated user"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload or user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 409 {object} dtos.ErrorResponse "Nickname or email already taken"
+// @Failure 412 {object} dtos.ErrorResponse "User changed since the If-Match version"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to update user"
 // @Router /users/{id} [put]
 func (h *Handler) HandleUpdateUser(c echo.Context) error {
 	// Bind the incoming JSON request to UpdateUserRequest struct
 	var userReq dtos.UpdateUserRequest
 	if err := c.Bind(&userReq); err != nil {
 		log.Warnf("[HandleUpdateUser] Unable to decode JSON: %s", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&userReq); err != nil {
+		log.Warnf("[HandleUpdateUser] Invalid request payload: %s", err)
+		return err
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		log.Warnf("[HandleUpdateUser] Unusable If-Match header: %s", err)
+		return err
 	}
 
 	// Update the user by ID via the service layer
-	userResp, err := h.services.UpdateUser(c.Param("id"), userReq)
+	userResp, err := h.services.UpdateUser(c.Param("id"), userReq, version)
 	if err != nil {
 		log.Warnf("[HandleUpdateUser] Unable to update user: %s", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Unable to update user: %s", err)})
+		return err
 	}
 
 	// Log success and return the updated user response
 	log.Infof("[HandleUpdateUser] Successfully updated user with id %s", userResp.ID.String())
+	setETag(c, userResp.Version)
+	return c.JSON(http.StatusOK, userResp)
+}
+
+// HandlePatchUser handles partial user updates
+// @Summary Patch an existing user's profile
+// @Description Change single profile fields with a merge patch (RFC 7396, null clears a field) or a JSON Patch (RFC 6902). The patched profile must still be valid. Send the ETag of the last read as If-Match to avoid overwriting someone else's changes.
+// @Tags users
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param If-Match header string false "ETag the patch is based on"
+// @Param patch body object true "Merge patch object or JSON Patch operations"
+// @Success 200 {object} dtos.UpdateUserResponse
+// @Header 200 {string} ETag "Version of the updated user"
+// @Failure 400 {object} dtos.ErrorResponse "Malformed patch, unknown field or invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 409 {object} dtos.ErrorResponse "Nickname or email already taken, or a JSON Patch test failed"
+// @Failure 412 {object} dtos.ErrorResponse "User changed since the If-Match version"
+// @Failure 415 {object} dtos.ErrorResponse "Unsupported patch format"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to update user"
+// @Router /users/{id} [patch]
+func (h *Handler) HandlePatchUser(c echo.Context) error {
+	// Plain JSON bodies are read as merge patches
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType == echo.MIMEApplicationJSON {
+		mediaType = dtos.MergePatchMediaType
+	}
+
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		log.Warnf("[HandlePatchUser] Unable to read request body: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		log.Warnf("[HandlePatchUser] Unusable If-Match header: %s", err)
+		return err
+	}
+
+	// Patch the user by ID via the service layer
+	userResp, err := h.services.PatchUser(c.Param("id"), patch, mediaType, version)
+	if err != nil {
+		log.Warnf("[HandlePatchUser] Unable to patch user: %s", err)
+		return err
+	}
+
+	// Log success and return the updated user response
+	log.Infof("[HandlePatchUser] Successfully patched user with id %s", userResp.ID.String())
+	setETag(c, userResp.Version)
+	return c.JSON(http.StatusOK, userResp)
+}
+
+// HandleSetUserRole handles requests to change a user's role
+// @Summary Set a user's role
+// @Description Assign the user, admin or a custom role. The change applies to access tokens issued afterwards.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param role body dtos.SetRoleRequest true "New role"
+// @Success 200 {object} dtos.GetUserDTO
+// @Header 200 {string} ETag "Version of the updated user"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload or user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only admins may assign roles"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to set role"
+// @Router /users/{id}/role [put]
+func (h *Handler) HandleSetUserRole(c echo.Context) error {
+	// Bind the incoming JSON request to SetRoleRequest struct
+	var roleReq dtos.SetRoleRequest
+	if err := c.Bind(&roleReq); err != nil {
+		log.Warnf("[HandleSetUserRole] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&roleReq); err != nil {
+		log.Warnf("[HandleSetUserRole] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Assign the role via the service layer
+	userResp, err := h.services.SetRole(c.Param("id"), roleReq)
+	if err != nil {
+		log.Warnf("[HandleSetUserRole] Unable to set role of user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	log.Infof("[HandleSetUserRole] Set role of user %s to %s", userResp.ID, userResp.Role)
+	setETag(c, userResp.Version)
 	return c.JSON(http.StatusOK, userResp)
 }
 
+// HandleChangePassword handles password change requests
+// @Summary Change a user's password
+// @Description Replace the password after verifying the current one. Every session of the user is ended.
+// @Tags users
+// @Accept  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param passwords body dtos.ChangePasswordRequest true "Current and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload or user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Not the user or an admin, or incorrect current password"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed or new password breaks the policy"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to change password"
+// @Router /users/{id}/password [post]
+func (h *Handler) HandleChangePassword(c echo.Context) error {
+	// Bind the incoming JSON request to ChangePasswordRequest struct
+	var passwordReq dtos.ChangePasswordRequest
+	if err := c.Bind(&passwordReq); err != nil {
+		log.Warnf("[HandleChangePassword] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&passwordReq); err != nil {
+		log.Warnf("[HandleChangePassword] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Change the password via the service layer
+	if err := h.services.ChangePassword(c.Param("id"), passwordReq); err != nil {
+		log.Warnf("[HandleChangePassword] Unable to change password of user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	log.Infof("[HandleChangePassword] Changed password of user %s", c.Param("id"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandleVerifyEmail handles requests to verify an email with the token from a verification link
+// @Summary Verify an email
+// @Description Mark the email of the token's owner as verified. Tokens work once and only for the email they were sent to.
+// @Tags users
+// @Accept  json
+// @Produce  json
+// @Param token body dtos.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} dtos.GetUserDTO
+// @Failure 400 {object} dtos.ErrorResponse "Invalid request payload, or invalid or expired token"
+// @Failure 422 {object} dtos.ErrorResponse "Validation failed"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to verify email"
+// @Router /users/verify-email [post]
+func (h *Handler) HandleVerifyEmail(c echo.Context) error {
+	// Bind the incoming JSON request to VerifyEmailRequest struct
+	var verifyReq dtos.VerifyEmailRequest
+	if err := c.Bind(&verifyReq); err != nil {
+		log.Warnf("[HandleVerifyEmail] Unable to decode JSON: %s", err)
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	// Validate the request data
+	if err := validateRequest(&verifyReq); err != nil {
+		log.Warnf("[HandleVerifyEmail] Invalid request payload: %s", err)
+		return err
+	}
+
+	// Verify the email via the service layer
+	userResp, err := h.services.VerifyEmail(verifyReq)
+	if err != nil {
+		log.Warnf("[HandleVerifyEmail] Unable to verify email: %s", err)
+		return err
+	}
+
+	log.Infof("[HandleVerifyEmail] Verified email of user %s", userResp.ID)
+	return c.JSON(http.StatusOK, userResp)
+}
+
+// HandleResendVerificationEmail handles requests to send a new email verification link
+// @Summary Resend the verification email
+// @Description Send a new verification link to the user's email, invalidating the previous one. Nothing is sent when the email is already verified.
+// @Tags users
+// @Produce  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 202 {object} map[string]string "Verification link sent"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to send verification email"
+// @Router /users/{id}/verification-email [post]
+func (h *Handler) HandleResendVerificationEmail(c echo.Context) error {
+	// Send a new link via the service layer
+	if err := h.services.ResendVerificationEmail(c.Param("id")); err != nil {
+		log.Warnf("[HandleResendVerificationEmail] Unable to send verification email to user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "Verification link sent if the email is not verified yet"})
+}
+
+// HandleUnlockUser handles requests to lift the lockout of a user's account
+// @Summary Unlock a user
+// @Description Forget the failed logins of the user's account, lifting any delay or lockout. Lockouts of client addresses are not affected.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "Account unlocked"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only admins may unlock users"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to unlock user"
+// @Router /users/{id}/unlock [post]
+func (h *Handler) HandleUnlockUser(c echo.Context) error {
+	// The Authenticate middleware guarantees the caller's ID is present
+	actorID, _ := auth.UserIDFromContext(c.Request().Context())
+
+	// Clear the failed logins via the service layer
+	if err := h.services.UnlockUser(c.Param("id"), actorID.String()); err != nil {
+		log.Warnf("[HandleUnlockUser] Unable to unlock user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	log.Infof("[HandleUnlockUser] User %s unlocked by %s", c.Param("id"), actorID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandleLogoutUser handles requests to end every session of a user
+// @Summary Log a user out everywhere
+// @Description Revoke every refresh token of the user, for example when their account is compromised. Access tokens already issued stay valid until they expire.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "All sessions ended"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to log out"
+// @Router /users/{id}/logout-all [post]
+func (h *Handler) HandleLogoutUser(c echo.Context) error {
+	// The Authenticate middleware guarantees the caller's ID is present
+	actorID, _ := auth.UserIDFromContext(c.Request().Context())
+
+	// Revoke every session of the user via the service layer
+	if err := h.services.LogoutAll(c.Param("id")); err != nil {
+		log.Warnf("[HandleLogoutUser] Unable to log out user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	log.Infof("[HandleLogoutUser] Ended all sessions of user %s on behalf of %s", c.Param("id"), actorID)
+	return c.NoContent(http.StatusNoContent)
+}
+
 // HandleDeleteUser handles user deletion requests
 // @Summary Delete a user
-// @Description Delete the user with the given ID
+// @Description Delete the user with the given ID. The user can no longer log in and is hidden from lookups, but admins can restore them until the retention period passes; their nickname and email stay reserved until then.
 // @Tags users
 // @Produce  json
+// @Security BearerAuth
 // @Param id path string true "User ID"
+// @Param If-Match header string false "ETag the deletion is based on"
 // @Success 200 {object} map[string]string "Successfully deleted user"
-// @Failure 500 {object} map[string]string "Unable to delete user"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 412 {object} dtos.ErrorResponse "User changed since the If-Match version"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to delete user"
 // @Router /users/{id} [delete]
 func (h *Handler) HandleDeleteUser(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		log.Warnf("[HandleDeleteUser] Unusable If-Match header: %s", err)
+		return err
+	}
+
 	// Delete the user by ID via the service layer
-	err := h.services.DeleteUser(c.Param("id"))
+	err = h.services.DeleteUser(c.Param("id"), version)
 	if err != nil {
 		log.Warnf("[HandleDeleteUser] Unable to delete user: %s", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Unable to delete user: %s", err)})
+		return err
 	}
 
 	// Log success and return a confirmation message
@@ -100,25 +395,152 @@ func (h *Handler) HandleDeleteUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Successfully deleted user with id %s", c.Param("id"))})
 }
 
+// HandleRestoreUser handles requests to undo the deletion of a user
+// @Summary Restore a deleted user
+// @Description Undo the deletion of the user with the given ID, as long as they have not been purged yet
+// @Tags users
+// @Produce  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} dtos.GetUserDTO
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only admins may restore users"
+// @Failure 404 {object} dtos.ErrorResponse "User not found or already purged"
+// @Failure 409 {object} dtos.ErrorResponse "User is not deleted"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to restore user"
+// @Router /users/{id}/restore [post]
+func (h *Handler) HandleRestoreUser(c echo.Context) error {
+	// Restore the user via the service layer
+	userResp, err := h.services.RestoreUser(c.Param("id"))
+	if err != nil {
+		log.Warnf("[HandleRestoreUser] Unable to restore user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, userResp)
+}
+
+// HandleHardDeleteUser handles requests to permanently delete a user
+// @Summary Permanently delete a user
+// @Description Remove the user with the given ID for good, whether deleted before or not. Their nickname and email become available again. This cannot be undone.
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204 "User permanently deleted"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only admins may permanently delete users"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to delete user"
+// @Router /users/{id}/permanent [delete]
+func (h *Handler) HandleHardDeleteUser(c echo.Context) error {
+	// The Authenticate middleware guarantees the caller's ID is present
+	actorID, _ := auth.UserIDFromContext(c.Request().Context())
+
+	// Remove the user via the service layer
+	if err := h.services.HardDeleteUser(c.Param("id")); err != nil {
+		log.Warnf("[HandleHardDeleteUser] Unable to permanently delete user %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	log.Infof("[HandleHardDeleteUser] User %s permanently deleted by %s", c.Param("id"), actorID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// includeDeleted reads the include_deleted query parameter; only admins may see deleted users
+func includeDeleted(c echo.Context) (bool, error) {
+	value := c.QueryParam("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: include_deleted must be true or false", apperrors.ErrInvalidFilter)
+	}
+
+	if principal, _ := auth.PrincipalFromContext(c.Request().Context()); include && principal.Role != models.RoleAdmin {
+		return false, fmt.Errorf("%w: only admins may see deleted users", apperrors.ErrForbidden)
+	}
+
+	return include, nil
+}
+
 // HandleGetUsers handles requests to retrieve users with optional filtering and pagination
 // @Summary Get a list of users
-// @Description Retrieve a list of users with optional filtering and pagination. Filter must look like this and be URL encoded: field=value
+// @Description Retrieve a list of users with optional filtering and pagination. Responses report the number of pages in total_pages and whether another page follows in has_next. The URL encoded filter combines conditions of the form "field operator value" with AND and OR (AND binds tighter) and parentheses, e.g. country in (DE, AT) AND (nickname prefix adm OR created_at gt 2024-01-01). Text fields (nickname, email, first_name, last_name, country, role) support eq, neq, contains, prefix and in and ignore case; email_verified supports eq and neq with true or false; created_at and updated_at support gt and lt with an RFC 3339 timestamp or a date. Values containing spaces or parentheses are double-quoted. The short form field=value means contains for text fields. Syntax errors report the position of the problem. The sort parameter lists fields to sort by, each prefixed with - for descending order, e.g. -created_at,nickname; users with equal values are ordered by ID, and without sort users are listed in the order they were created. Every page links its neighbours with next_cursor and prev_cursor; passing one as cursor, instead of page, together with the same filter and sort continues from the edge of that page even if users were created or deleted meanwhile.
 // @Tags users
 // @Produce  json
-// @Param page query string false "Page number"
-// @Param page_size query string false "Page size"
-// @Param filter query string false "Filter query"
+// @Security BearerAuth
+// @Param page query int false "Page number, starting at 1; not combined with cursor" default(1)
+// @Param page_size query int false "Users per page; the configured default when left out, larger sizes are capped at the configured maximum"
+// @Param filter query string false "Filter expression, e.g. nickname prefix al AND email_verified eq true"
+// @Param sort query string false "Comma separated fields to sort by, - for descending: nickname, email, first_name, last_name, country, role, email_verified, created_at, updated_at"
+// @Param cursor query string false "next_cursor or prev_cursor of an earlier page"
+// @Param include_deleted query bool false "Also list deleted users"
 // @Success 200 {object} dtos.GetUserResponse
-// @Failure 500 {object} map[string]string "Unable to get users"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid pagination parameters, filter, sort or cursor"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only admins may list users"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to get users"
 // @Router /users [get]
 func (h *Handler) HandleGetUsers(c echo.Context) error {
-	// Fetch filtered users based on query parameters for pagination and filtering
-	response, err := h.services.GetFilteredUsers(c.QueryParam("page"), c.QueryParam("page_size"), c.QueryParam("filter"))
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		log.Warnf("[HandleGetUsers] Invalid include_deleted parameter: %s", err)
+		return err
+	}
+
+	// Fetch filtered users based on query parameters for pagination, filtering and sorting
+	response, err := h.services.GetFilteredUsers(dtos.GetUsersRequest{
+		Page:           c.QueryParam("page"),
+		PageSize:       c.QueryParam("page_size"),
+		Filter:         c.QueryParam("filter"),
+		Sort:           c.QueryParam("sort"),
+		Cursor:         c.QueryParam("cursor"),
+		IncludeDeleted: withDeleted,
+	})
 	if err != nil {
 		log.Warnf("[HandleGetUsers] Unable to get users: %s", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Unable to get users: %s", err)})
+		return err
 	}
 
 	// Return the list of users
 	return c.JSON(http.StatusOK, response)
 }
+
+// HandleGetUser handles requests to retrieve a single user by ID
+// @Summary Get a user
+// @Description Retrieve the user with the given ID. Admins may also retrieve deleted users with include_deleted.
+// @Tags users
+// @Produce  json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param include_deleted query bool false "Also find the user if deleted"
+// @Success 200 {object} dtos.GetUserDTO
+// @Header 200 {string} ETag "Version of the user, for If-Match"
+// @Failure 400 {object} dtos.ErrorResponse "Invalid user ID or include_deleted value"
+// @Failure 401 {object} dtos.ErrorResponse "Missing or invalid access token"
+// @Failure 403 {object} dtos.ErrorResponse "Only the user or an admin may do this, and only admins may include deleted users"
+// @Failure 404 {object} dtos.ErrorResponse "User not found"
+// @Failure 500 {object} dtos.ErrorResponse "Unable to get user"
+// @Router /users/{id} [get]
+func (h *Handler) HandleGetUser(c echo.Context) error {
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		log.Warnf("[HandleGetUser] Invalid include_deleted parameter: %s", err)
+		return err
+	}
+
+	// Fetch the user by ID via the service layer
+	userResp, err := h.services.GetUser(c.Param("id"), withDeleted)
+	if err != nil {
+		log.Warnf("[HandleGetUser] Unable to get user with id %s: %s", c.Param("id"), err)
+		return err
+	}
+
+	// Return the requested user with its version
+	setETag(c, userResp.Version)
+	return c.JSON(http.StatusOK, userResp)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIncludeDeleted(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        string
+		query       string
+		expected    bool
+		expectedErr error
+	}{
+		{name: "Absent", role: models.RoleUser, query: "", expected: false},
+		{name: "Admin", role: models.RoleAdmin, query: "?include_deleted=true", expected: true},
+		{name: "Admin opting out", role: models.RoleAdmin, query: "?include_deleted=0", expected: false},
+		{name: "Explicitly false for users", role: models.RoleUser, query: "?include_deleted=false", expected: false},
+		{name: "Users may not see deleted users", role: models.RoleUser, query: "?include_deleted=true", expectedErr: apperrors.ErrForbidden},
+		{name: "Not a boolean", role: models.RoleAdmin, query: "?include_deleted=maybe", expectedErr: apperrors.ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil)
+			principal := auth.Principal{UserID: uuid.New(), Role: tt.role}
+			req = req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			include, err := includeDeleted(c)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, include)
+		})
+	}
+}
+
+// recordingAuth records the users LogoutAll was called for
+type recordingAuth struct {
+	service.Auth
+	loggedOut []string
+}
+
+func (a *recordingAuth) LogoutAll(userIDStr string) error {
+	a.loggedOut = append(a.loggedOut, userIDStr)
+	return nil
+}
+
+func TestHandleLogoutUser(t *testing.T) {
+	tokens, err := auth.NewTokenManager(config.Default().Auth)
+	require.NoError(t, err)
+
+	self := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name           string
+		role           string
+		target         uuid.UUID
+		expectedStatus int
+	}{
+		{name: "Admin logs out another user", role: models.RoleAdmin, target: other, expectedStatus: http.StatusNoContent},
+		{name: "User logs out themselves", role: models.RoleUser, target: self, expectedStatus: http.StatusNoContent},
+		{name: "User may not log out others", role: models.RoleUser, target: other, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &recordingAuth{}
+			users := &stubUsers{roles: map[uuid.UUID]string{self: tt.role}}
+			h := NewHandler(&service.Service{Users: users, Auth: sessions}, nil, tokens, config.Default())
+			token, _, err := tokens.Issue(self, tt.role)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/"+tt.target.String()+"/logout-all", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus == http.StatusNoContent {
+				assert.Equal(t, []string{tt.target.String()}, sessions.loggedOut)
+			} else {
+				assert.Empty(t, sessions.loggedOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// StatusOK is reported for a passing check or a ready service
+	StatusOK = "ok"
+	// StatusUnavailable is reported for a failing check or a service that must not receive traffic
+	StatusUnavailable = "unavailable"
+)
+
+// CheckFunc reports whether a component can serve traffic; a nil error means it can
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the readiness checks registered by the service components
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration
+}
+
+// NewChecker creates a Checker that gives every check at most timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a named check that is evaluated on every readiness probe
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Run evaluates all checks concurrently and reports the service as ready only if every check passes
+func (c *Checker) Run(ctx context.Context) dtos.ReadinessResponse {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	results := make([]dtos.HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	for _, result := range results {
+		if result.Status != StatusOK {
+			status = StatusUnavailable
+		}
+	}
+
+	return dtos.ReadinessResponse{Status: status, Checks: results}
+}
+
+// runCheck evaluates a single check, bounding its duration by the checker timeout
+func (c *Checker) runCheck(ctx context.Context, chk check) dtos.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	// Run the check separately so a check that ignores its context cannot stall the probe
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- chk.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := dtos.HealthCheckResult{
+		Name:      chk.name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Gate is a check that fails with a fixed reason while it is closed
+type Gate struct {
+	open   atomic.Bool
+	reason error
+}
+
+// NewGate creates a closed gate that reports reason until it is opened
+func NewGate(reason string) *Gate {
+	return &Gate{reason: errors.New(reason)}
+}
+
+// Open makes the gate's check pass
+func (g *Gate) Open() {
+	g.open.Store(true)
+}
+
+// Close makes the gate's check fail
+func (g *Gate) Close() {
+	g.open.Store(false)
+}
+
+// IsOpen reports whether the gate's check passes
+func (g *Gate) IsOpen() bool {
+	return g.open.Load()
+}
+
+// Check implements CheckFunc
+func (g *Gate) Check(_ context.Context) error {
+	if !g.open.Load() {
+		return g.reason
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	tests := []struct {
+		name             string
+		checks           map[string]CheckFunc
+		expectedStatus   string
+		expectedFailures map[string]string
+	}{
+		{
+			name:           "No checks",
+			checks:         map[string]CheckFunc{},
+			expectedStatus: StatusOK,
+		},
+		{
+			name: "All checks pass",
+			checks: map[string]CheckFunc{
+				"storage": func(ctx context.Context) error { return nil },
+				"cache":   func(ctx context.Context) error { return nil },
+			},
+			expectedStatus: StatusOK,
+		},
+		{
+			name: "Failing check",
+			checks: map[string]CheckFunc{
+				"storage": func(ctx context.Context) error { return errors.New("connection refused") },
+				"cache":   func(ctx context.Context) error { return nil },
+			},
+			expectedStatus:   StatusUnavailable,
+			expectedFailures: map[string]string{"storage": "connection refused"},
+		},
+		{
+			name: "Check ignoring its context times out",
+			checks: map[string]CheckFunc{
+				"slow": func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			expectedStatus:   StatusUnavailable,
+			expectedFailures: map[string]string{"slow": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(50 * time.Millisecond)
+			for name, fn := range tt.checks {
+				checker.Register(name, fn)
+			}
+
+			start := time.Now()
+			report := checker.Run(context.Background())
+
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+			assert.Equal(t, tt.expectedStatus, report.Status)
+			require.Len(t, report.Checks, len(tt.checks))
+			for _, result := range report.Checks {
+				if reason, failed := tt.expectedFailures[result.Name]; failed {
+					assert.Equal(t, StatusUnavailable, result.Status)
+					assert.Equal(t, reason, result.Error)
+				} else {
+					assert.Equal(t, StatusOK, result.Status)
+					assert.Empty(t, result.Error)
+				}
+				assert.GreaterOrEqual(t, result.LatencyMs, 0.0)
+			}
+		})
+	}
+}
+
+func TestGate(t *testing.T) {
+	gate := NewGate("not started")
+	assert.EqualError(t, gate.Check(context.Background()), "not started")
+	assert.False(t, gate.IsOpen())
+
+	gate.Open()
+	assert.NoError(t, gate.Check(context.Background()))
+	assert.True(t, gate.IsOpen())
+
+	gate.Close()
+	assert.EqualError(t, gate.Check(context.Background()), "not started")
+}
@@ -2,9 +2,17 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/filter"
 	"time"
 )
 
+const (
+	// RoleUser is assigned to every registered user; users may only manage their own account
+	RoleUser = "user"
+	// RoleAdmin may manage every user, list users and assign roles
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	FirstName string    `json:"first_name"`
@@ -13,6 +21,106 @@ type User struct {
 	Password  string    `json:"password"`
 	Email     string    `json:"email"`
 	Country   string    `json:"country"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// Role is RoleUser, RoleAdmin or a custom role understood by other services
+	Role string `json:"role"`
+	// EmailVerified is reset whenever the email changes; EmailVerifiedAt is nil while unverified
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// DeletedAt is set while the user is soft-deleted; their nickname and email stay reserved until purged
+	DeletedAt *time.Time `json:"deleted_at"`
+	// Version starts at 1 and is incremented by every change, so concurrent writers can detect each other
+	Version int64 `json:"version"`
+}
+
+// SortFields lists the fields, by JSON name, that users can be sorted by; text fields are sorted ignoring case
+var SortFields = []string{
+	"nickname",
+	"email",
+	"first_name",
+	"last_name",
+	"country",
+	"role",
+	"email_verified",
+	"created_at",
+	"updated_at",
+}
+
+// SortKey orders users by one of SortFields
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// UserSeek positions a page next to a boundary user rather than at an offset, so pages stay consistent
+// while users are created or deleted in between
+type UserSeek struct {
+	// Boundary holds the ID and the sort field values of the user the page continues from;
+	// it does not have to exist anymore
+	Boundary User
+	// Backward selects the users preceding the boundary instead of those following it;
+	// they are still returned in sort order
+	Backward bool
+}
+
+// UserQuery selects a page of users
+type UserQuery struct {
+	// Filter selects the users; nil matches every user
+	Filter filter.Expr
+	// Sort orders the users by each key in turn, then by ID; without keys users keep the order they were created in,
+	// except when seeking, where they are ordered by ID alone
+	Sort   []SortKey
+	Limit  int
+	Offset int
+	// IncludeDeleted also returns soft-deleted users
+	IncludeDeleted bool
+}
+
+// RefreshToken is a server-side session record; only a hash of the token handed to the client is stored
+type RefreshToken struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// FamilyID is shared by every token obtained by rotating the same login, so a detected reuse
+	// can revoke the whole session
+	FamilyID  uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// UsedAt is set once the token has been exchanged for a new one
+	UsedAt    time.Time
+	RevokedAt time.Time
+}
+
+const (
+	// TokenPurposePasswordReset marks tokens that allow setting a new password without the current one
+	TokenPurposePasswordReset = "password_reset"
+	// TokenPurposeEmailVerification marks tokens that prove the user controls their email
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken is a single-use token sent to a user out of band; only its hash is stored
+type OneTimeToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+// LoginAttempts counts the failed logins recorded under a key, such as an account or a client address
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	// ExpiresAt is when the counter is forgotten unless another failure is recorded
+	ExpiresAt time.Time
+}
+
+// LoginAttempt is a login attempt counted under a key: the counter before and after it was counted
+type LoginAttempt struct {
+	Before LoginAttempts
+	After  LoginAttempts
 }
@@ -0,0 +1,121 @@
+// Package notify delivers messages such as password reset links to users.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/config"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to users, for example by email
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// New creates the notifier selected by the configuration
+func New(cfg config.NotifierConfig) (Notifier, error) {
+	switch cfg.Backend {
+	case config.NotifierLog:
+		return LogNotifier{}, nil
+	case config.NotifierFile:
+		return NewFileNotifier(cfg.File), nil
+	}
+
+	return nil, fmt.Errorf("unknown notifier backend %q", cfg.Backend)
+}
+
+// LogNotifier writes messages to the service log. Messages may contain secrets such as
+// reset links, so it is meant for development only.
+type LogNotifier struct{}
+
+// Notify logs the message
+func (LogNotifier) Notify(_ context.Context, msg Message) error {
+	log.WithFields(log.Fields{"to": msg.To, "subject": msg.Subject}).Infof("[Notify] %s", msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a file, so tests and local tooling can read them back
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier creates a FileNotifier writing to path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// fileEntry is a single line written by FileNotifier
+type fileEntry struct {
+	Message
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Notify appends the message to the file
+func (n *FileNotifier) Notify(_ context.Context, msg Message) error {
+	line, err := json.Marshal(fileEntry{Message: msg, SentAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open notification file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write notification: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Async delivers messages in the background, so the time a request takes does not depend
+// on whether a message was sent. Delivery errors are logged.
+type Async struct {
+	next    Notifier
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// NewAsync wraps next, giving each delivery at most timeout to complete
+func NewAsync(next Notifier, timeout time.Duration) *Async {
+	return &Async{next: next, timeout: timeout}
+}
+
+// Notify schedules the message for delivery and returns immediately
+func (a *Async) Notify(_ context.Context, msg Message) error {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+		defer cancel()
+
+		if err := a.next.Notify(ctx, msg); err != nil {
+			log.Errorf("[Notify] Unable to deliver %q: %s", msg.Subject, err)
+		}
+	}()
+
+	return nil
+}
+
+// Wait blocks until every scheduled message has been handled
+func (a *Async) Wait() {
+	a.wg.Wait()
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readEntries returns the messages written to a FileNotifier file
+func readEntries(t *testing.T, path string) []Message {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry fileEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		assert.False(t, entry.SentAt.IsZero())
+		messages = append(messages, entry.Message)
+	}
+	require.NoError(t, scanner.Err())
+	return messages
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	notifier := NewFileNotifier(path)
+
+	first := Message{To: "alice@example.com", Subject: "Reset", Body: "https://example.com/reset?token=a"}
+	second := Message{To: "bob@example.com", Subject: "Reset", Body: "https://example.com/reset?token=b"}
+	require.NoError(t, notifier.Notify(context.Background(), first))
+	require.NoError(t, notifier.Notify(context.Background(), second))
+
+	assert.Equal(t, []Message{first, second}, readEntries(t, path))
+}
+
+func TestNew(t *testing.T) {
+	notifier, err := New(config.NotifierConfig{Backend: config.NotifierLog})
+	require.NoError(t, err)
+	assert.IsType(t, LogNotifier{}, notifier)
+	assert.NoError(t, notifier.Notify(context.Background(), Message{To: "alice@example.com"}))
+
+	notifier, err = New(config.NotifierConfig{Backend: config.NotifierFile, File: "outbox.jsonl"})
+	require.NoError(t, err)
+	assert.IsType(t, &FileNotifier{}, notifier)
+
+	_, err = New(config.NotifierConfig{Backend: "smtp"})
+	assert.Error(t, err)
+}
+
+// recordingNotifier collects delivered messages and fails when told to
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.messages = append(n.messages, msg)
+	return n.err
+}
+
+func TestAsync(t *testing.T) {
+	next := &recordingNotifier{err: errors.New("smtp unavailable")}
+	async := NewAsync(next, time.Second)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, async.Notify(context.Background(), Message{To: "alice@example.com"}))
+	}
+	async.Wait()
+
+	assert.Len(t, next.messages, 5)
+}
@@ -0,0 +1,210 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/sosshik/users-service/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	// argon2SaltLength and argon2KeyLength follow the sizes recommended by RFC 9106
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// errUnknownFormat is returned for stored hashes no registered algorithm understands
+var errUnknownFormat = errors.New("unknown password hash format")
+
+// Hasher turns passwords into self-describing hashes and checks passwords against them
+type Hasher interface {
+	// Hash returns a new hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash; a mismatch is not an error
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash is weaker than or in a different format from what Hash produces
+	NeedsRehash(hash string) bool
+}
+
+// NewHasher creates a Hasher producing hashes with the configured algorithm that still verifies
+// hashes of every supported algorithm, so stored hashes can be migrated on login
+func NewHasher(cfg config.PasswordConfig) (Hasher, error) {
+	bcryptHasher := &BcryptHasher{Cost: cfg.BcryptCost}
+	argon2Hasher := &Argon2idHasher{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+
+	switch cfg.Algorithm {
+	case config.HashBcrypt:
+		return &MultiHasher{Preferred: bcryptHasher, Legacy: []Hasher{argon2Hasher}}, nil
+	case config.HashArgon2id:
+		return &MultiHasher{Preferred: argon2Hasher, Legacy: []Hasher{bcryptHasher}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown password hashing algorithm %q", cfg.Algorithm)
+}
+
+// formatter is implemented by hashers that can tell whether a stored hash is theirs
+type formatter interface {
+	owns(hash string) bool
+}
+
+// MultiHasher hashes with Preferred and verifies with whichever hasher owns the stored hash.
+// Hashes not produced by Preferred always need a rehash.
+type MultiHasher struct {
+	Preferred Hasher
+	Legacy    []Hasher
+}
+
+// Hash hashes password with the preferred algorithm
+func (m *MultiHasher) Hash(password string) (string, error) {
+	return m.Preferred.Hash(password)
+}
+
+// Verify checks password with the algorithm that produced hash
+func (m *MultiHasher) Verify(hash, password string) (bool, error) {
+	hasher := m.owner(hash)
+	if hasher == nil {
+		return false, errUnknownFormat
+	}
+	return hasher.Verify(hash, password)
+}
+
+// NeedsRehash reports whether hash should be replaced by a hash from the preferred algorithm
+func (m *MultiHasher) NeedsRehash(hash string) bool {
+	if m.owner(hash) != m.Preferred {
+		return true
+	}
+	return m.Preferred.NeedsRehash(hash)
+}
+
+// owner finds the hasher that understands hash
+func (m *MultiHasher) owner(hash string) Hasher {
+	for _, hasher := range append([]Hasher{m.Preferred}, m.Legacy...) {
+		if f, ok := hasher.(formatter); ok && f.owns(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// BcryptHasher produces bcrypt hashes in the modular crypt format, such as $2a$10$...
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns a bcrypt hash of password
+func (b *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+// Verify reports whether password matches the bcrypt hash
+func (b *BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// NeedsRehash reports whether hash uses a lower cost than configured
+func (b *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < b.Cost
+}
+
+func (b *BcryptHasher) owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Argon2idHasher produces argon2id hashes in the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// argon2idParams are the settings encoded in an argon2id hash
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Hash returns an argon2id hash of password with a random salt
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches the argon2id hash, using the parameters stored in it
+func (a *Argon2idHasher) Verify(hash, password string) (bool, error) {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// NeedsRehash reports whether hash was produced with weaker settings than configured
+func (a *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.memory < a.Memory || params.iterations < a.Iterations || params.parallelism < a.Parallelism ||
+		len(params.key) < argon2KeyLength
+}
+
+func (a *Argon2idHasher) owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// parseArgon2id decodes a PHC formatted argon2id hash
+func parseArgon2id(hash string) (argon2idParams, error) {
+	var params argon2idParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, errUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, fmt.Errorf("invalid argon2id parameters %q: %w", parts[3], err)
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return params, errors.New("invalid argon2id key")
+	}
+
+	return params, nil
+}
@@ -0,0 +1,140 @@
+package password
+
+import (
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// testHasherConfig returns cheap hashing settings for algorithm
+func testHasherConfig(algorithm string) config.PasswordConfig {
+	cfg := config.Default().Password
+	cfg.Algorithm = algorithm
+	cfg.BcryptCost = bcrypt.MinCost
+	cfg.Argon2Memory = 64
+	cfg.Argon2Iterations = 1
+	cfg.Argon2Parallelism = 1
+	return cfg
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		prefix    string
+	}{
+		{name: "bcrypt", algorithm: config.HashBcrypt, prefix: "$2a$04$"},
+		{name: "argon2id", algorithm: config.HashArgon2id, prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewHasher(testHasherConfig(tt.algorithm))
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash("correct horse")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.prefix), hash)
+
+			ok, err := hasher.Verify(hash, "correct horse")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify(hash, "wrong horse")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.False(t, hasher.NeedsRehash(hash))
+
+			// Salts are random, so the same password never hashes the same twice
+			again, err := hasher.Hash("correct horse")
+			require.NoError(t, err)
+			assert.NotEqual(t, hash, again)
+		})
+	}
+}
+
+func TestHasherMigration(t *testing.T) {
+	bcryptHasher, err := NewHasher(testHasherConfig(config.HashBcrypt))
+	require.NoError(t, err)
+	argon2Hasher, err := NewHasher(testHasherConfig(config.HashArgon2id))
+	require.NoError(t, err)
+
+	bcryptHash, err := bcryptHasher.Hash("secret")
+	require.NoError(t, err)
+	argon2Hash, err := argon2Hasher.Hash("secret")
+	require.NoError(t, err)
+
+	stronger := testHasherConfig(config.HashBcrypt)
+	stronger.BcryptCost = bcrypt.MinCost + 1
+	strongerBcrypt, err := NewHasher(stronger)
+	require.NoError(t, err)
+
+	strongerArgon2Cfg := testHasherConfig(config.HashArgon2id)
+	strongerArgon2Cfg.Argon2Iterations = 2
+	strongerArgon2, err := NewHasher(strongerArgon2Cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		hasher      Hasher
+		hash        string
+		needsRehash bool
+	}{
+		{name: "bcrypt hash while preferring argon2id", hasher: argon2Hasher, hash: bcryptHash, needsRehash: true},
+		{name: "argon2id hash while preferring bcrypt", hasher: bcryptHasher, hash: argon2Hash, needsRehash: true},
+		{name: "bcrypt hash below the configured cost", hasher: strongerBcrypt, hash: bcryptHash, needsRehash: true},
+		{name: "argon2id hash with fewer iterations", hasher: strongerArgon2, hash: argon2Hash, needsRehash: true},
+		{name: "bcrypt hash above the configured cost", hasher: bcryptHasher, hash: mustBcrypt(t, bcrypt.MinCost+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Both algorithms keep verifying during a migration
+			ok, err := tt.hasher.Verify(tt.hash, "secret")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			assert.Equal(t, tt.needsRehash, tt.hasher.NeedsRehash(tt.hash))
+		})
+	}
+}
+
+func TestHasherRejectsMalformedHashes(t *testing.T) {
+	hasher, err := NewHasher(testHasherConfig(config.HashArgon2id))
+	require.NoError(t, err)
+
+	hashes := []string{
+		"",
+		"plain-text-password",
+		"$md5$abc",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	}
+
+	for _, hash := range hashes {
+		ok, err := hasher.Verify(hash, "secret")
+		assert.Error(t, err, hash)
+		assert.False(t, ok, hash)
+		assert.True(t, hasher.NeedsRehash(hash), hash)
+	}
+}
+
+func TestNewHasherUnknownAlgorithm(t *testing.T) {
+	_, err := NewHasher(testHasherConfig("md5"))
+	assert.EqualError(t, err, `unknown password hashing algorithm "md5"`)
+}
+
+// mustBcrypt hashes "secret" with bcrypt at the given cost
+func mustBcrypt(t *testing.T, cost int) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), cost)
+	require.NoError(t, err)
+	return string(hash)
+}
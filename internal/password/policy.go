@@ -0,0 +1,125 @@
+// Package password enforces the rules new passwords must satisfy and hashes them for storage.
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/config"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minUserInfoLength is the shortest nickname or email part checked for inside passwords;
+// shorter ones would reject too many unrelated passwords
+const minUserInfoLength = 3
+
+// Policy checks passwords against the configured rules
+type Policy struct {
+	cfg      config.PasswordConfig
+	breached map[string]struct{}
+}
+
+// NewPolicy creates a Policy from the configuration, loading the breached password list if one is set
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	p := &Policy{cfg: cfg}
+
+	if cfg.BreachedListFile != "" {
+		breached, err := loadList(cfg.BreachedListFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load breached password list: %w", err)
+		}
+		p.breached = breached
+	}
+
+	return p, nil
+}
+
+// loadList reads one password per line, ignoring blank lines; entries are compared case-insensitively
+func loadList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			list[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return list, scanner.Err()
+}
+
+// Check reports every rule the password breaks. userInfo holds values such as the nickname
+// and email that must not appear in the password.
+func (p *Policy) Check(password string, userInfo ...string) error {
+	var violations []string
+
+	// Length is counted in characters, but bcrypt only uses the first 72 bytes
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength))
+	}
+	if len(password) > p.cfg.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.cfg.MaxLength))
+	}
+
+	for _, class := range []struct {
+		required bool
+		matches  func(rune) bool
+		name     string
+	}{
+		{p.cfg.RequireUppercase, unicode.IsUpper, "an uppercase letter"},
+		{p.cfg.RequireLowercase, unicode.IsLower, "a lowercase letter"},
+		{p.cfg.RequireDigit, unicode.IsDigit, "a digit"},
+		{p.cfg.RequireSymbol, isSymbol, "a symbol"},
+	} {
+		if class.required && strings.IndexFunc(password, class.matches) < 0 {
+			violations = append(violations, "must contain "+class.name)
+		}
+	}
+
+	if p.cfg.RejectUserInfo && containsUserInfo(password, userInfo) {
+		violations = append(violations, "must not contain the nickname or email")
+	}
+
+	if _, found := p.breached[strings.ToLower(password)]; found {
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: password %s", apperrors.ErrWeakPassword, strings.Join(violations, ", "))
+	}
+
+	return nil
+}
+
+// isSymbol reports whether r is neither a letter, a digit nor whitespace
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+// containsUserInfo reports whether the password contains any of the values, or the local part of an email
+func containsUserInfo(password string, userInfo []string) bool {
+	password = strings.ToLower(password)
+	for _, value := range userInfo {
+		candidates := []string{value}
+		if local, _, found := strings.Cut(value, "@"); found {
+			candidates = append(candidates, local)
+		}
+
+		for _, candidate := range candidates {
+			candidate = strings.ToLower(candidate)
+			if utf8.RuneCountInString(candidate) >= minUserInfoLength && strings.Contains(password, candidate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package password
+
+import (
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	breachedFile := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(breachedFile, []byte("password1\n\n  Letmein123  \n"), 0o600))
+
+	strict := config.Default().Password
+	strict.RequireUppercase = true
+	strict.RequireLowercase = true
+	strict.RequireDigit = true
+	strict.RequireSymbol = true
+	strict.BreachedListFile = breachedFile
+
+	tests := []struct {
+		name       string
+		cfg        config.PasswordConfig
+		password   string
+		userInfo   []string
+		violations []string
+	}{
+		{name: "Default policy accepts a long passphrase", cfg: config.Default().Password, password: "correct horse battery"},
+		{name: "Too short", cfg: config.Default().Password, password: "short", violations: []string{"at least 8 characters"}},
+		{name: "Length counts characters", cfg: config.Default().Password, password: "пароль12"},
+		{name: "Longer than bcrypt hashes", cfg: config.Default().Password, password: strings.Repeat("ü", 37), violations: []string{"at most 72 bytes"}},
+		{
+			name:       "Contains nickname in another case",
+			cfg:        config.Default().Password,
+			password:   "my-Alice-password",
+			userInfo:   []string{"alice", "someone@example.com"},
+			violations: []string{"must not contain the nickname or email"},
+		},
+		{
+			name:       "Contains email local part",
+			cfg:        config.Default().Password,
+			password:   "someone2024!",
+			userInfo:   []string{"alice", "someone@example.com"},
+			violations: []string{"must not contain the nickname or email"},
+		},
+		{name: "Ignores very short nicknames", cfg: config.Default().Password, password: "jo-is-my-name", userInfo: []string{"jo"}},
+		{name: "Strict policy accepts a complex password", cfg: strict, password: "Tr0ub4dor&3"},
+		{
+			name:       "Strict policy reports every missing class",
+			cfg:        strict,
+			password:   "lowercaseonly",
+			violations: []string{"an uppercase letter", "a digit", "a symbol"},
+		},
+		{name: "Breached password in another case", cfg: strict, password: "LETMEIN123", violations: []string{"breached passwords"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.cfg)
+			require.NoError(t, err)
+
+			err = policy.Check(tt.password, tt.userInfo...)
+			if len(tt.violations) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, apperrors.ErrWeakPassword)
+			for _, violation := range tt.violations {
+				assert.Contains(t, err.Error(), violation)
+			}
+		})
+	}
+}
+
+func TestNewPolicyMissingList(t *testing.T) {
+	cfg := config.Default().Password
+	cfg.BreachedListFile = filepath.Join(t.TempDir(), "missing.txt")
+
+	_, err := NewPolicy(cfg)
+	assert.ErrorContains(t, err, "unable to load breached password list")
+}
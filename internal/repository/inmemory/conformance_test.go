@@ -0,0 +1,20 @@
+package inmemory_test
+
+import (
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/sosshik/users-service/internal/repository/inmemory"
+	"github.com/sosshik/users-service/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestUsersConformance(t *testing.T) {
+	repositorytest.RunUsersConformance(t, func() repository.Users {
+		return inmemory.NewInMemory()
+	})
+}
+
+func TestUsersConformanceWithSearchIndexes(t *testing.T) {
+	repositorytest.RunUsersConformance(t, func() repository.Users {
+		return inmemory.NewInMemory(inmemory.WithSearchIndexes())
+	})
+}
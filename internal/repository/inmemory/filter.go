@@ -0,0 +1,90 @@
+package inmemory
+
+import (
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// matchesFilter evaluates a parsed filter against user; a nil filter matches every user
+func matchesFilter(expr filter.Expr, user *models.User) bool {
+	switch expr := expr.(type) {
+	case nil:
+		return true
+	case filter.And:
+		for _, term := range expr {
+			if !matchesFilter(term, user) {
+				return false
+			}
+		}
+		return true
+	case filter.Or:
+		for _, term := range expr {
+			if matchesFilter(term, user) {
+				return true
+			}
+		}
+		return false
+	case *filter.Condition:
+		return matchesCondition(expr, user)
+	}
+	return false
+}
+
+// matchesCondition compares a single field of user; text is compared ignoring case
+func matchesCondition(cond *filter.Condition, user *models.User) bool {
+	switch cond.Kind {
+	case filter.Bool:
+		return (user.EmailVerified == cond.Bool) == (cond.Op == filter.Eq)
+	case filter.Time:
+		value := timeField(user, cond.Field)
+		if cond.Op == filter.Gt {
+			return value.After(cond.Time)
+		}
+		return value.Before(cond.Time)
+	}
+
+	value := strings.ToLower(textField(user, cond.Field))
+	switch cond.Op {
+	case filter.Eq:
+		return value == strings.ToLower(cond.Text[0])
+	case filter.Neq:
+		return value != strings.ToLower(cond.Text[0])
+	case filter.Contains:
+		return strings.Contains(value, strings.ToLower(cond.Text[0]))
+	case filter.Prefix:
+		return strings.HasPrefix(value, strings.ToLower(cond.Text[0]))
+	case filter.In:
+		return slices.ContainsFunc(cond.Text, func(text string) bool { return value == strings.ToLower(text) })
+	}
+	return false
+}
+
+// textField returns the text field of user with the given JSON name
+func textField(user *models.User, field string) string {
+	switch field {
+	case "nickname":
+		return user.Nickname
+	case "email":
+		return user.Email
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "country":
+		return user.Country
+	case "role":
+		return user.Role
+	}
+	return ""
+}
+
+// timeField returns the timestamp of user with the given JSON name
+func timeField(user *models.User, field string) time.Time {
+	if field == "updated_at" {
+		return user.UpdatedAt
+	}
+	return user.CreatedAt
+}
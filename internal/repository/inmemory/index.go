@@ -0,0 +1,348 @@
+package inmemory
+
+import (
+	"bytes"
+	"cmp"
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+)
+
+// trigramFields are the text fields indexed for substring search
+var trigramFields = []string{"nickname", "email", "first_name", "last_name"}
+
+// userSet is a set of stored users
+type userSet map[*models.User]struct{}
+
+// searchIndexes holds the secondary indexes used to answer filters without scanning every user.
+// Keys are lowercased because filters ignore case. Soft-deleted users stay indexed so they can be
+// listed on request; the filter itself still checks every candidate.
+// A nil *searchIndexes indexes nothing and leaves every query to a full scan.
+type searchIndexes struct {
+	// sequence remembers the order users were added in, which candidates are returned in
+	sequence map[*models.User]uint64
+	next     uint64
+	// country is an exact-match hash index
+	country map[string]userSet
+	// nickname and email are sorted for prefix lookups
+	nickname sortedIndex
+	email    sortedIndex
+	// trigrams maps each field to the users containing each trigram of its value
+	trigrams map[string]map[string]userSet
+}
+
+// newSearchIndexes creates empty indexes
+func newSearchIndexes() *searchIndexes {
+	idx := &searchIndexes{
+		sequence: make(map[*models.User]uint64),
+		country:  make(map[string]userSet),
+		trigrams: make(map[string]map[string]userSet),
+	}
+	for _, field := range trigramFields {
+		idx.trigrams[field] = make(map[string]userSet)
+	}
+	return idx
+}
+
+// insert adds a newly stored user to the indexes
+func (idx *searchIndexes) insert(user *models.User) {
+	if idx == nil {
+		return
+	}
+	idx.sequence[user] = idx.next
+	idx.next++
+	idx.index(user)
+}
+
+// delete drops a user from the indexes for good
+func (idx *searchIndexes) delete(user *models.User) {
+	if idx == nil {
+		return
+	}
+	idx.unindex(user)
+	delete(idx.sequence, user)
+}
+
+// index adds the current field values of user; together with unindex it brackets in-place updates
+func (idx *searchIndexes) index(user *models.User) {
+	if idx == nil {
+		return
+	}
+
+	country := strings.ToLower(user.Country)
+	if idx.country[country] == nil {
+		idx.country[country] = make(userSet)
+	}
+	idx.country[country][user] = struct{}{}
+
+	idx.nickname.insert(strings.ToLower(user.Nickname), user)
+	idx.email.insert(strings.ToLower(user.Email), user)
+
+	for _, field := range trigramFields {
+		postings := idx.trigrams[field]
+		for _, gram := range trigrams(strings.ToLower(textField(user, field))) {
+			if postings[gram] == nil {
+				postings[gram] = make(userSet)
+			}
+			postings[gram][user] = struct{}{}
+		}
+	}
+}
+
+// unindex removes the current field values of user, which must not have changed since index
+func (idx *searchIndexes) unindex(user *models.User) {
+	if idx == nil {
+		return
+	}
+
+	country := strings.ToLower(user.Country)
+	delete(idx.country[country], user)
+	if len(idx.country[country]) == 0 {
+		delete(idx.country, country)
+	}
+
+	idx.nickname.remove(strings.ToLower(user.Nickname), user)
+	idx.email.remove(strings.ToLower(user.Email), user)
+
+	for _, field := range trigramFields {
+		postings := idx.trigrams[field]
+		for _, gram := range trigrams(strings.ToLower(textField(user, field))) {
+			delete(postings[gram], user)
+			if len(postings[gram]) == 0 {
+				delete(postings, gram)
+			}
+		}
+	}
+}
+
+// candidates returns, in insertion order, a superset of the users matching expr when an index can
+// narrow them down; otherwise it returns false and every user has to be scanned
+func (idx *searchIndexes) candidates(expr filter.Expr) ([]*models.User, bool) {
+	if idx == nil {
+		return nil, false
+	}
+
+	set, ok := idx.plan(expr)
+	if !ok {
+		return nil, false
+	}
+
+	users := make([]*models.User, 0, len(set))
+	for user := range set {
+		users = append(users, user)
+	}
+	slices.SortFunc(users, func(a, b *models.User) int {
+		return cmp.Compare(idx.sequence[a], idx.sequence[b])
+	})
+	return users, true
+}
+
+// plan picks the indexes answering expr and returns the users they select; the returned set must not be modified
+func (idx *searchIndexes) plan(expr filter.Expr) (userSet, bool) {
+	switch expr := expr.(type) {
+	case filter.And:
+		// Any indexed term narrows down the whole conjunction; the smallest selection is the cheapest to check
+		var best userSet
+		found := false
+		for _, term := range expr {
+			if set, ok := idx.plan(term); ok && (!found || len(set) < len(best)) {
+				best, found = set, true
+			}
+		}
+		return best, found
+	case filter.Or:
+		// A single unindexed alternative can match any user
+		union := make(userSet)
+		for _, term := range expr {
+			set, ok := idx.plan(term)
+			if !ok {
+				return nil, false
+			}
+			for user := range set {
+				union[user] = struct{}{}
+			}
+		}
+		return union, true
+	case *filter.Condition:
+		return idx.lookup(expr)
+	}
+	return nil, false
+}
+
+// lookup answers a single condition from an index
+func (idx *searchIndexes) lookup(cond *filter.Condition) (userSet, bool) {
+	if cond.Kind != filter.Text {
+		return nil, false
+	}
+
+	switch cond.Op {
+	case filter.Eq, filter.Prefix, filter.Contains:
+		return idx.lookupText(cond.Field, cond.Op, strings.ToLower(cond.Text[0]))
+	case filter.In:
+		union := make(userSet)
+		for _, text := range cond.Text {
+			set, ok := idx.lookupText(cond.Field, filter.Eq, strings.ToLower(text))
+			if !ok {
+				return nil, false
+			}
+			for user := range set {
+				union[user] = struct{}{}
+			}
+		}
+		return union, true
+	}
+	return nil, false
+}
+
+// lookupText finds the users whose field compares to the lowercased value with op, preferring
+// the hash index, then the sorted indexes and finally the trigram index
+func (idx *searchIndexes) lookupText(field string, op filter.Operator, value string) (userSet, bool) {
+	switch {
+	case field == "country" && op == filter.Eq:
+		return idx.country[value], true
+	case field == "nickname" && op != filter.Contains:
+		return idx.nickname.lookup(op, value), true
+	case field == "email" && op != filter.Contains:
+		return idx.email.lookup(op, value), true
+	}
+
+	postings, indexed := idx.trigrams[field]
+	grams := trigrams(value)
+	if !indexed || len(grams) == 0 {
+		return nil, false
+	}
+
+	// Every trigram of the value occurs in a matching field; start from the rarest one
+	sets := make([]userSet, 0, len(grams))
+	for _, gram := range grams {
+		if len(postings[gram]) == 0 {
+			return nil, true
+		}
+		sets = append(sets, postings[gram])
+	}
+	slices.SortFunc(sets, func(a, b userSet) int { return cmp.Compare(len(a), len(b)) })
+
+	result := make(userSet, len(sets[0]))
+	for user := range sets[0] {
+		if !slices.ContainsFunc(sets[1:], func(set userSet) bool {
+			_, found := set[user]
+			return !found
+		}) {
+			result[user] = struct{}{}
+		}
+	}
+	return result, true
+}
+
+// trigrams returns the distinct runs of three characters in s; strings shorter than that have none
+func trigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) < 3 {
+		return nil
+	}
+
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+3]))
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// sortedBlockSize is the most entries a block of a sortedIndex holds before it is split in two
+const sortedBlockSize = 512
+
+// sortedEntry is a key of a sortedIndex and the user it belongs to
+type sortedEntry struct {
+	key  string
+	user *models.User
+}
+
+// sortedIndex keeps entries ordered by key and then user ID, so equal keys and common prefixes are adjacent.
+// The entries are split into small sorted blocks, so inserts and removals only shift the entries of one block.
+type sortedIndex struct {
+	blocks [][]sortedEntry
+}
+
+// compareEntry orders entries by key, then by user ID
+func compareEntry(e sortedEntry, key string, user *models.User) int {
+	if c := strings.Compare(e.key, key); c != 0 {
+		return c
+	}
+	return bytes.Compare(e.user.ID[:], user.ID[:])
+}
+
+// block returns the first block whose last entry does not compare before the target, or len(s.blocks)
+// when every entry does
+func (s *sortedIndex) block(compare func(sortedEntry) int) int {
+	i, _ := slices.BinarySearchFunc(s.blocks, compare, func(block []sortedEntry, compare func(sortedEntry) int) int {
+		return compare(block[len(block)-1])
+	})
+	return i
+}
+
+// insert adds the key of user
+func (s *sortedIndex) insert(key string, user *models.User) {
+	entry := sortedEntry{key: key, user: user}
+	if len(s.blocks) == 0 {
+		s.blocks = [][]sortedEntry{{entry}}
+		return
+	}
+
+	compare := func(e sortedEntry) int { return compareEntry(e, key, user) }
+	b := min(s.block(compare), len(s.blocks)-1)
+	i, _ := slices.BinarySearchFunc(s.blocks[b], compare, func(e sortedEntry, compare func(sortedEntry) int) int {
+		return compare(e)
+	})
+	block := slices.Insert(s.blocks[b], i, entry)
+
+	// Split full blocks in halves; the second half gets its own backing array
+	if len(block) > sortedBlockSize {
+		half := len(block) / 2
+		s.blocks = slices.Insert(s.blocks, b+1, slices.Clone(block[half:]))
+		block = block[:half]
+	}
+	s.blocks[b] = block
+}
+
+// remove drops the key of user
+func (s *sortedIndex) remove(key string, user *models.User) {
+	compare := func(e sortedEntry) int { return compareEntry(e, key, user) }
+	b := s.block(compare)
+	if b == len(s.blocks) {
+		return
+	}
+
+	i, found := slices.BinarySearchFunc(s.blocks[b], compare, func(e sortedEntry, compare func(sortedEntry) int) int {
+		return compare(e)
+	})
+	if !found {
+		return
+	}
+
+	s.blocks[b] = slices.Delete(s.blocks[b], i, i+1)
+	if len(s.blocks[b]) == 0 {
+		s.blocks = slices.Delete(s.blocks, b, b+1)
+	}
+}
+
+// lookup returns the users whose key equals value, or starts with it for filter.Prefix
+func (s *sortedIndex) lookup(op filter.Operator, value string) userSet {
+	compare := func(e sortedEntry) int { return strings.Compare(e.key, value) }
+
+	result := make(userSet)
+	for b := s.block(compare); b < len(s.blocks); b++ {
+		// Only the first block can hold keys before value
+		i, _ := slices.BinarySearchFunc(s.blocks[b], compare, func(e sortedEntry, compare func(sortedEntry) int) int {
+			return compare(e)
+		})
+		for _, e := range s.blocks[b][i:] {
+			if e.key != value && (op != filter.Prefix || !strings.HasPrefix(e.key, value)) {
+				return result
+			}
+			result[e.user] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,231 @@
+package inmemory
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestSearchIndexesPlan(t *testing.T) {
+	storage := NewInMemory(WithSearchIndexes())
+	for _, user := range []models.User{
+		{Nickname: "alice", Email: "alice@example.com", FirstName: "Alice", LastName: "Smith", Country: "DE"},
+		{Nickname: "alex", Email: "alex@example.org", FirstName: "Alexander", LastName: "Schmidt", Country: "de"},
+		{Nickname: "bob", Email: "bob@example.com", FirstName: "Bob", LastName: "Johnson", Country: "UK"},
+		{Nickname: "dmitry", Email: "dmitry@example.org", FirstName: "Дмитрий", LastName: "Öztürk", Country: "AT"},
+	} {
+		_, err := storage.CreateUser(user)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		filter     string
+		indexed    bool
+		candidates []string
+	}{
+		{"No filter", "", false, nil},
+		{"Country hash index ignores case", "country eq De", true, []string{"alice", "alex"}},
+		{"Country in", "country in (uk, at, fr)", true, []string{"bob", "dmitry"}},
+		{"Country substring is not indexed", "country contains d", false, nil},
+		{"Nickname equality", "nickname eq ALEX", true, []string{"alex"}},
+		{"Nickname prefix", "nickname prefix al", true, []string{"alice", "alex"}},
+		{"Email prefix", "email prefix bob@", true, []string{"bob"}},
+		{"Email substring uses trigrams", "email contains example.org", true, []string{"alex", "dmitry"}},
+		{"Name substring uses trigrams", "first_name contains ДМИТ", true, []string{"dmitry"}},
+		{"Trigrams of a missing substring", "last_name contains xyz", true, nil},
+		{"Substring shorter than a trigram", "last_name contains sc", false, nil},
+		{"Not equal is not indexed", "nickname neq bob", false, nil},
+		{"Role is not indexed", "role eq admin", false, nil},
+		{"And picks the smallest selection", "country eq de AND nickname eq alex", true, []string{"alex"}},
+		{"And with one indexed term", "email_verified eq false AND nickname prefix b", true, []string{"bob"}},
+		{"Or of indexed terms", "nickname eq bob OR country eq at", true, []string{"bob", "dmitry"}},
+		{"Or with an unindexed term", "nickname eq bob OR role eq admin", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := filter.Parse(tt.filter)
+			require.NoError(t, err)
+
+			candidates, indexed := storage.search.candidates(expr)
+			assert.Equal(t, tt.indexed, indexed)
+
+			var nicknames []string
+			for _, user := range candidates {
+				nicknames = append(nicknames, user.Nickname)
+			}
+			assert.Equal(t, tt.candidates, nicknames)
+		})
+	}
+}
+
+func TestSearchIndexesFollowChanges(t *testing.T) {
+	storage := NewInMemory(WithSearchIndexes())
+	alice, err := storage.CreateUser(models.User{Nickname: "alice", Email: "alice@example.com", LastName: "Smith", Country: "DE"})
+	require.NoError(t, err)
+	bob, err := storage.CreateUser(models.User{Nickname: "bob", Email: "bob@example.com", LastName: "Jones", Country: "DE"})
+	require.NoError(t, err)
+
+	alice.Nickname = "zoe"
+	alice.LastName = "Brown"
+	alice.Country = "NO"
+	_, err = storage.UpdateUser(alice, 0)
+	require.NoError(t, err)
+	require.NoError(t, storage.HardDeleteUser(bob.ID))
+
+	// Nothing points at the old values or the removed user any more
+	assert.Equal(t, map[string]userSet{"no": {storage.idIndex[alice.ID]: {}}}, storage.search.country)
+	assert.Equal(t, [][]sortedEntry{{{key: "zoe", user: storage.idIndex[alice.ID]}}}, storage.search.nickname.blocks)
+	assert.Equal(t, [][]sortedEntry{{{key: "alice@example.com", user: storage.idIndex[alice.ID]}}}, storage.search.email.blocks)
+	assert.NotContains(t, storage.search.trigrams["last_name"], "smi")
+	assert.NotContains(t, storage.search.trigrams["last_name"], "jon")
+	assert.Contains(t, storage.search.trigrams["last_name"], "bro")
+	assert.Len(t, storage.search.sequence, 1)
+}
+
+func TestSortedIndex(t *testing.T) {
+	var index sortedIndex
+	users := make([]*models.User, 5*sortedBlockSize)
+	for i := range users {
+		users[i] = &models.User{ID: uuid.New(), Nickname: fmt.Sprintf("user%05d", i)}
+	}
+
+	// Insert in random order so blocks fill and split everywhere
+	random := rand.New(rand.NewSource(1))
+	for _, i := range random.Perm(len(users)) {
+		index.insert(users[i].Nickname, users[i])
+	}
+	for i := range users {
+		if i%3 != 0 {
+			index.remove(users[i].Nickname, users[i])
+		}
+	}
+	index.remove("missing", users[0])
+
+	var keys []string
+	for _, block := range index.blocks {
+		require.NotEmpty(t, block)
+		require.LessOrEqual(t, len(block), sortedBlockSize)
+		for _, e := range block {
+			keys = append(keys, e.key)
+		}
+	}
+	assert.Len(t, keys, (len(users)+2)/3)
+	assert.True(t, slices.IsSorted(keys))
+
+	assert.Len(t, index.lookup(filter.Eq, "user00003"), 1)
+	assert.Empty(t, index.lookup(filter.Eq, "user00001"))
+	assert.Len(t, index.lookup(filter.Prefix, "user010"), 33)
+	assert.Len(t, index.lookup(filter.Prefix, "user"), len(keys))
+	assert.Empty(t, index.lookup(filter.Prefix, "zzz"))
+}
+
+func TestTrigrams(t *testing.T) {
+	assert.Nil(t, trigrams("ab"))
+	assert.Equal(t, []string{"aaa"}, trigrams("aaaa"))
+	// Trigrams are counted in characters, not bytes
+	assert.Equal(t, []string{"bcd", "жзb", "зbc", "ёжз"}, trigrams("ёжзbcd"))
+}
+
+// benchmarkUsers is the number of users the filter benchmarks run against
+const benchmarkUsers = 100_000
+
+// benchmarkStorage fills a storage with users spread over 50 countries and random last names
+func benchmarkStorage(b *testing.B, options ...Option) *InMemoryStorage {
+	b.Helper()
+
+	random := rand.New(rand.NewSource(1))
+	letters := []rune("abcdefghijklmnopqrstuvwxyz")
+	storage := NewInMemory(options...)
+	for i := 0; i < benchmarkUsers; i++ {
+		lastName := make([]rune, 8)
+		for j := range lastName {
+			lastName[j] = letters[random.Intn(len(letters))]
+		}
+		_, err := storage.CreateUser(models.User{
+			Nickname:  fmt.Sprintf("user%06d", i),
+			Email:     fmt.Sprintf("user%06d@example.com", i),
+			FirstName: fmt.Sprintf("First%d", i%1000),
+			LastName:  string(lastName),
+			Country:   fmt.Sprintf("Country%02d", i%50),
+			Role:      models.RoleUser,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return storage
+}
+
+func BenchmarkGetFilteredUsers(b *testing.B) {
+	storages := []struct {
+		name    string
+		storage *InMemoryStorage
+	}{
+		{"Scan", benchmarkStorage(b)},
+		{"Indexed", benchmarkStorage(b, WithSearchIndexes())},
+	}
+	lastName := storages[0].storage.users[benchmarkUsers/2].LastName
+
+	filters := []struct {
+		name   string
+		filter string
+	}{
+		{"CountryEq", "country eq country07"},
+		{"NicknameEq", "nickname eq user042137"},
+		{"NicknamePrefix", "nickname prefix user0421"},
+		{"EmailPrefix", "email prefix user04213"},
+		{"LastNameContains", fmt.Sprintf("last_name contains %s", lastName[2:6])},
+		{"AndWithIndexedTerm", "email_verified eq false AND nickname prefix user0421"},
+		{"Unindexed", "role eq admin"},
+	}
+
+	for _, f := range filters {
+		expr, err := filter.Parse(f.filter)
+		if err != nil {
+			b.Fatal(err)
+		}
+		query := models.UserQuery{Filter: expr, Sort: []models.SortKey{{Field: "created_at"}}, Limit: 10}
+
+		for _, s := range storages {
+			b.Run(f.name+"/"+s.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, _, err := s.storage.GetFilteredUsers(query); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkUpdateUser(b *testing.B) {
+	for _, s := range []struct {
+		name    string
+		options []Option
+	}{
+		{"Scan", nil},
+		{"Indexed", []Option{WithSearchIndexes()}},
+	} {
+		b.Run(s.name, func(b *testing.B) {
+			storage := benchmarkStorage(b, s.options...)
+			user := *storage.users[benchmarkUsers/2]
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				user.Nickname = fmt.Sprintf("renamed%d", i%2)
+				user.Country = fmt.Sprintf("Country%02d", i%50)
+				if _, err := storage.UpdateUser(user, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
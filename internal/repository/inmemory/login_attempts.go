@@ -0,0 +1,116 @@
+package inmemory
+
+import (
+	"github.com/sosshik/users-service/internal/models"
+	"sync"
+	"time"
+)
+
+type LoginAttemptStorage struct {
+	mu         sync.Mutex
+	attempts   map[string]*models.LoginAttempts
+	lastPruned time.Time
+}
+
+// NewLoginAttemptStorage creates a new instance of LoginAttemptStorage
+func NewLoginAttemptStorage() *LoginAttemptStorage {
+	return &LoginAttemptStorage{
+		attempts:   make(map[string]*models.LoginAttempts),
+		lastPruned: time.Now(),
+	}
+}
+
+// BeginLoginAttempt checks the counter under key and, unless blockedUntil is still ahead, counts the attempt
+// as a failure in the same critical section, so concurrent attempts see each other. It returns the counter
+// before and after counting, or the unchanged counter and when the next attempt is allowed if this one has
+// to wait. The counter is forgotten once ttl passes without another attempt.
+func (s *LoginAttemptStorage) BeginLoginAttempt(key string, ttl time.Duration, blockedUntil func(models.LoginAttempts) time.Time) (models.LoginAttempt, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+
+	now := time.Now()
+	attempts, found := s.attempts[key]
+	if !found || now.After(attempts.ExpiresAt) {
+		attempts = &models.LoginAttempts{Key: key}
+	}
+
+	before := *attempts
+	if until := blockedUntil(before); until.After(now) {
+		return models.LoginAttempt{Before: before, After: before}, until, nil
+	}
+
+	s.attempts[key] = attempts
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	attempts.ExpiresAt = now.Add(ttl)
+
+	return models.LoginAttempt{Before: before, After: *attempts}, time.Time{}, nil
+}
+
+// RefundLoginAttempt takes back an attempt counted by BeginLoginAttempt that succeeded or did not take place
+func (s *LoginAttemptStorage) RefundLoginAttempt(attempt models.LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := attempt.After.Key
+	current, found := s.attempts[key]
+	if !found || current.Failures == 0 {
+		return nil
+	}
+
+	// Unless another attempt was counted since, the counter goes back to exactly what it was, so the
+	// refunded attempt neither restarts the delay nor keeps the counter from expiring
+	if current.Failures == attempt.After.Failures && current.LastFailureAt.Equal(attempt.After.LastFailureAt) {
+		if attempt.Before.Failures == 0 {
+			delete(s.attempts, key)
+		} else {
+			*current = attempt.Before
+		}
+		return nil
+	}
+
+	// Later attempts keep their times
+	current.Failures--
+
+	return nil
+}
+
+// GetLoginAttempts returns the counter stored under key, or an empty one if there is none
+func (s *LoginAttemptStorage) GetLoginAttempts(key string) (models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts, found := s.attempts[key]
+	if !found || time.Now().After(attempts.ExpiresAt) {
+		return models.LoginAttempts{Key: key}, nil
+	}
+
+	return *attempts, nil
+}
+
+// ResetLoginAttempts forgets the failures recorded under key
+func (s *LoginAttemptStorage) ResetLoginAttempts(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
+
+// pruneExpired drops expired counters at most once per pruneInterval
+func (s *LoginAttemptStorage) pruneExpired() {
+	now := time.Now()
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	for key, attempts := range s.attempts {
+		if now.After(attempts.ExpiresAt) {
+			delete(s.attempts, key)
+		}
+	}
+}
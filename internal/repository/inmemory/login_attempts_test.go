@@ -0,0 +1,175 @@
+package inmemory
+
+import (
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// neverBlocked lets every login attempt through
+func neverBlocked(models.LoginAttempts) time.Time {
+	return time.Time{}
+}
+
+func TestLoginAttempts(t *testing.T) {
+	storage := NewLoginAttemptStorage()
+
+	// Unknown keys have no failures
+	attempts, err := storage.GetLoginAttempts("user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", attempts.Key)
+	assert.Zero(t, attempts.Failures)
+
+	var attempt models.LoginAttempt
+	for i := 1; i <= 3; i++ {
+		var blockedUntil time.Time
+		attempt, blockedUntil, err = storage.BeginLoginAttempt("user:alice", time.Hour, neverBlocked)
+		require.NoError(t, err)
+		assert.Zero(t, blockedUntil)
+		assert.Equal(t, i-1, attempt.Before.Failures)
+		assert.Equal(t, i, attempt.After.Failures)
+	}
+	assert.WithinDuration(t, time.Now(), attempt.After.LastFailureAt, time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), attempt.After.ExpiresAt, time.Second)
+
+	// Counters are kept per key
+	attempt, _, err = storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.After.Failures)
+
+	attempts, err = storage.GetLoginAttempts("user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+
+	require.NoError(t, storage.ResetLoginAttempts("user:alice"))
+	attempts, err = storage.GetLoginAttempts("user:alice")
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+
+	attempts, err = storage.GetLoginAttempts("ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+}
+
+func TestLoginAttemptsExpire(t *testing.T) {
+	storage := NewLoginAttemptStorage()
+
+	_, _, err := storage.BeginLoginAttempt("user:bob", time.Millisecond, neverBlocked)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	attempts, err := storage.GetLoginAttempts("user:bob")
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+
+	// Counting starts over after the counter expired
+	attempt, _, err := storage.BeginLoginAttempt("user:bob", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.After.Failures)
+}
+
+func TestBeginLoginAttemptBlocked(t *testing.T) {
+	storage := NewLoginAttemptStorage()
+	until := time.Now().Add(time.Hour)
+	blockedAfterTwo := func(attempts models.LoginAttempts) time.Time {
+		if attempts.Failures >= 2 {
+			return until
+		}
+		return time.Time{}
+	}
+
+	var counted models.LoginAttempt
+	for i := 0; i < 2; i++ {
+		var err error
+		counted, _, err = storage.BeginLoginAttempt("user:carol", time.Hour, blockedAfterTwo)
+		require.NoError(t, err)
+	}
+
+	// A blocked attempt is not counted
+	attempt, blockedUntil, err := storage.BeginLoginAttempt("user:carol", time.Hour, blockedAfterTwo)
+	require.NoError(t, err)
+	assert.Equal(t, until, blockedUntil)
+	assert.Equal(t, counted.After, attempt.Before)
+	assert.Equal(t, counted.After, attempt.After)
+
+	// Refunding an attempt lets the next one through
+	require.NoError(t, storage.RefundLoginAttempt(counted))
+	attempt, blockedUntil, err = storage.BeginLoginAttempt("user:carol", time.Hour, blockedAfterTwo)
+	require.NoError(t, err)
+	assert.Zero(t, blockedUntil)
+	assert.Equal(t, 2, attempt.After.Failures)
+
+	// Refunds never go below zero or create counters
+	require.NoError(t, storage.RefundLoginAttempt(models.LoginAttempt{After: models.LoginAttempts{Key: "user:dave", Failures: 1}}))
+	attempts, err := storage.GetLoginAttempts("user:dave")
+	require.NoError(t, err)
+	assert.Zero(t, attempts.Failures)
+}
+
+func TestRefundLoginAttempt(t *testing.T) {
+	storage := NewLoginAttemptStorage()
+
+	first, _, err := storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+
+	// A refund right after counting restores the whole counter, times included
+	second, _, err := storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	require.NoError(t, storage.RefundLoginAttempt(second))
+	attempts, err := storage.GetLoginAttempts("ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, first.After, attempts)
+
+	// Refunding the first attempt forgets the counter altogether
+	require.NoError(t, storage.RefundLoginAttempt(first))
+	assert.NotContains(t, storage.attempts, "ip:192.0.2.1")
+
+	// Once another attempt was counted meanwhile, a refund only takes its failure back
+	first, _, err = storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	second, _, err = storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, neverBlocked)
+	require.NoError(t, err)
+	require.NoError(t, storage.RefundLoginAttempt(first))
+	attempts, err = storage.GetLoginAttempts("ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.Equal(t, second.After.LastFailureAt, attempts.LastFailureAt)
+	assert.Equal(t, second.After.ExpiresAt, attempts.ExpiresAt)
+}
+
+func TestBeginLoginAttemptConcurrently(t *testing.T) {
+	storage := NewLoginAttemptStorage()
+	blockedAfterFive := func(attempts models.LoginAttempts) time.Time {
+		if attempts.Failures >= 5 {
+			return time.Now().Add(time.Hour)
+		}
+		return time.Time{}
+	}
+
+	// Checking and counting happen together, so no more than five attempts get through
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, blockedUntil, err := storage.BeginLoginAttempt("ip:192.0.2.1", time.Hour, blockedAfterFive)
+			if err == nil && blockedUntil.IsZero() {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, allowed)
+	attempts, err := storage.GetLoginAttempts("ip:192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, 5, attempts.Failures)
+}
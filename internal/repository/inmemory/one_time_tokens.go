@@ -0,0 +1,101 @@
+package inmemory
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"sync"
+	"time"
+)
+
+type OneTimeTokenStorage struct {
+	mu         sync.Mutex
+	tokens     map[string]*models.OneTimeToken
+	lastPruned time.Time
+}
+
+// NewOneTimeTokenStorage creates a new instance of OneTimeTokenStorage
+func NewOneTimeTokenStorage() *OneTimeTokenStorage {
+	return &OneTimeTokenStorage{
+		tokens:     make(map[string]*models.OneTimeToken),
+		lastPruned: time.Now(),
+	}
+}
+
+// CreateOneTimeToken stores a new one-time token under its hash
+func (s *OneTimeTokenStorage) CreateOneTimeToken(token models.OneTimeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+	s.tokens[token.TokenHash] = &token
+
+	return nil
+}
+
+// GetOneTimeToken returns the usable token with the given purpose and hash without consuming it
+func (s *OneTimeTokenStorage) GetOneTimeToken(purpose, hash string) (models.OneTimeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.usable(purpose, hash)
+	if err != nil {
+		return models.OneTimeToken{}, err
+	}
+
+	return *token, nil
+}
+
+// ConsumeOneTimeToken marks the usable token with the given purpose and hash as used and returns it;
+// of several concurrent calls only one succeeds
+func (s *OneTimeTokenStorage) ConsumeOneTimeToken(purpose, hash string) (models.OneTimeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.usable(purpose, hash)
+	if err != nil {
+		return models.OneTimeToken{}, err
+	}
+	token.UsedAt = time.Now()
+
+	return *token, nil
+}
+
+// DeleteUserOneTimeTokens removes the user's tokens with the given purpose
+func (s *OneTimeTokenStorage) DeleteUserOneTimeTokens(userID uuid.UUID, purpose string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+// usable looks up a token that has the expected purpose, is unused and has not expired
+func (s *OneTimeTokenStorage) usable(purpose, hash string) (*models.OneTimeToken, error) {
+	token, found := s.tokens[hash]
+	if !found || token.Purpose != purpose || !token.UsedAt.IsZero() || time.Now().After(token.ExpiresAt) {
+		return nil, apperrors.ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// pruneExpired drops expired tokens at most once per pruneInterval
+func (s *OneTimeTokenStorage) pruneExpired() {
+	now := time.Now()
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+}
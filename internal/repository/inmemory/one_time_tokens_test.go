@@ -0,0 +1,94 @@
+package inmemory
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOneTimeToken builds a live password reset token stored under hash
+func newOneTimeToken(hash string, userID uuid.UUID) models.OneTimeToken {
+	return models.OneTimeToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: hash,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestConsumeOneTimeToken(t *testing.T) {
+	storage := NewOneTimeTokenStorage()
+	userID := uuid.New()
+	expired := newOneTimeToken("expired", userID)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	for _, token := range []models.OneTimeToken{newOneTimeToken("live", userID), expired} {
+		require.NoError(t, storage.CreateOneTimeToken(token))
+	}
+
+	// Looking a token up does not use it
+	token, err := storage.GetOneTimeToken(models.TokenPurposePasswordReset, "live")
+	require.NoError(t, err)
+	assert.Equal(t, userID, token.UserID)
+
+	// A token only works for the purpose it was issued for
+	_, err = storage.ConsumeOneTimeToken("email_verification", "live")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+
+	token, err = storage.ConsumeOneTimeToken(models.TokenPurposePasswordReset, "live")
+	require.NoError(t, err)
+	assert.False(t, token.UsedAt.IsZero())
+
+	for _, hash := range []string{"live", "expired", "unknown"} {
+		_, err = storage.GetOneTimeToken(models.TokenPurposePasswordReset, hash)
+		assert.ErrorIs(t, err, apperrors.ErrInvalidToken, hash)
+		_, err = storage.ConsumeOneTimeToken(models.TokenPurposePasswordReset, hash)
+		assert.ErrorIs(t, err, apperrors.ErrInvalidToken, hash)
+	}
+}
+
+func TestConsumeOneTimeTokenConcurrently(t *testing.T) {
+	storage := NewOneTimeTokenStorage()
+	require.NoError(t, storage.CreateOneTimeToken(newOneTimeToken("hash", uuid.New())))
+
+	var consumed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := storage.ConsumeOneTimeToken(models.TokenPurposePasswordReset, "hash"); err == nil {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), consumed.Load())
+}
+
+func TestDeleteUserOneTimeTokens(t *testing.T) {
+	storage := NewOneTimeTokenStorage()
+	alice, bob := uuid.New(), uuid.New()
+	for _, token := range []models.OneTimeToken{newOneTimeToken("alice-1", alice), newOneTimeToken("alice-2", alice), newOneTimeToken("bob", bob)} {
+		require.NoError(t, storage.CreateOneTimeToken(token))
+	}
+
+	require.NoError(t, storage.DeleteUserOneTimeTokens(alice, models.TokenPurposePasswordReset))
+
+	for hash, expectedErr := range map[string]error{"alice-1": apperrors.ErrInvalidToken, "alice-2": apperrors.ErrInvalidToken, "bob": nil} {
+		_, err := storage.GetOneTimeToken(models.TokenPurposePasswordReset, hash)
+		if expectedErr != nil {
+			assert.ErrorIs(t, err, expectedErr, hash)
+		} else {
+			assert.NoError(t, err, hash)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package inmemory
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often expired refresh tokens are dropped from memory
+const pruneInterval = time.Minute
+
+type RefreshTokenStorage struct {
+	mu     sync.Mutex
+	tokens map[string]*models.RefreshToken
+	// revokedFamilies remembers revoked sessions until their tokens expire, so a rotation
+	// racing with the revocation cannot add a live token to them
+	revokedFamilies map[uuid.UUID]time.Time
+	lastPruned      time.Time
+}
+
+// NewRefreshTokenStorage creates a new instance of RefreshTokenStorage
+func NewRefreshTokenStorage() *RefreshTokenStorage {
+	return &RefreshTokenStorage{
+		tokens:          make(map[string]*models.RefreshToken),
+		revokedFamilies: make(map[uuid.UUID]time.Time),
+		lastPruned:      time.Now(),
+	}
+}
+
+// CreateRefreshToken stores a new refresh token under its hash
+func (s *RefreshTokenStorage) CreateRefreshToken(token models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneExpired()
+	if _, revoked := s.revokedFamilies[token.FamilyID]; revoked {
+		return apperrors.ErrInvalidRefreshToken
+	}
+	s.tokens[token.TokenHash] = &token
+
+	return nil
+}
+
+// ConsumeRefreshToken marks the token with the given hash as used and returns it as it was before,
+// so callers can tell a first use from a reuse
+func (s *RefreshTokenStorage) ConsumeRefreshToken(hash string) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, found := s.tokens[hash]
+	if !found {
+		return models.RefreshToken{}, apperrors.ErrInvalidRefreshToken
+	}
+
+	previous := *token
+	if token.UsedAt.IsZero() {
+		token.UsedAt = time.Now()
+	}
+
+	return previous, nil
+}
+
+// RevokeRefreshTokenFamily revokes every token that descends from the same login
+func (s *RefreshTokenStorage) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
+	s.revokeWhere(func(token *models.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every token of the user, ending all of their sessions
+func (s *RefreshTokenStorage) RevokeUserRefreshTokens(userID uuid.UUID) error {
+	s.revokeWhere(func(token *models.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+// revokeWhere revokes the tokens matching the predicate
+func (s *RefreshTokenStorage) revokeWhere(match func(token *models.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range s.tokens {
+		if !match(token) {
+			continue
+		}
+		if token.RevokedAt.IsZero() {
+			token.RevokedAt = now
+		}
+		if token.ExpiresAt.After(s.revokedFamilies[token.FamilyID]) {
+			s.revokedFamilies[token.FamilyID] = token.ExpiresAt
+		}
+	}
+}
+
+// pruneExpired drops expired tokens at most once per pruneInterval; expired tokens are
+// rejected anyway, so this only bounds memory usage
+func (s *RefreshTokenStorage) pruneExpired() {
+	now := time.Now()
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	for hash, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	for familyID, expiresAt := range s.revokedFamilies {
+		if now.After(expiresAt) {
+			delete(s.revokedFamilies, familyID)
+		}
+	}
+}
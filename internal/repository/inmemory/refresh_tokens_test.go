@@ -0,0 +1,95 @@
+package inmemory
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// newRefreshToken builds a live refresh token stored under hash
+func newRefreshToken(hash string, userID, familyID uuid.UUID) models.RefreshToken {
+	return models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestConsumeRefreshToken(t *testing.T) {
+	storage := NewRefreshTokenStorage()
+	require.NoError(t, storage.CreateRefreshToken(newRefreshToken("hash", uuid.New(), uuid.New())))
+
+	// The first use returns the token as it was before being marked
+	first, err := storage.ConsumeRefreshToken("hash")
+	require.NoError(t, err)
+	assert.True(t, first.UsedAt.IsZero())
+
+	// Later uses reveal that the token was already exchanged
+	second, err := storage.ConsumeRefreshToken("hash")
+	require.NoError(t, err)
+	assert.False(t, second.UsedAt.IsZero())
+
+	_, err = storage.ConsumeRefreshToken("unknown")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+}
+
+func TestRevokeRefreshTokens(t *testing.T) {
+	storage := NewRefreshTokenStorage()
+	alice, bob := uuid.New(), uuid.New()
+	aliceLaptop, alicePhone, bobLaptop := uuid.New(), uuid.New(), uuid.New()
+
+	for hash, token := range map[string]models.RefreshToken{
+		"alice-laptop-1": newRefreshToken("alice-laptop-1", alice, aliceLaptop),
+		"alice-laptop-2": newRefreshToken("alice-laptop-2", alice, aliceLaptop),
+		"alice-phone":    newRefreshToken("alice-phone", alice, alicePhone),
+		"bob-laptop":     newRefreshToken("bob-laptop", bob, bobLaptop),
+	} {
+		require.NoError(t, storage.CreateRefreshToken(token), hash)
+	}
+
+	revoked := func(hash string) bool {
+		t.Helper()
+		token, err := storage.ConsumeRefreshToken(hash)
+		require.NoError(t, err)
+		return !token.RevokedAt.IsZero()
+	}
+
+	require.NoError(t, storage.RevokeRefreshTokenFamily(aliceLaptop))
+	assert.True(t, revoked("alice-laptop-1"))
+	assert.True(t, revoked("alice-laptop-2"))
+	assert.False(t, revoked("alice-phone"))
+
+	// A rotation finishing after the revocation cannot revive the session
+	err := storage.CreateRefreshToken(newRefreshToken("alice-laptop-3", alice, aliceLaptop))
+	assert.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+
+	require.NoError(t, storage.RevokeUserRefreshTokens(alice))
+	assert.True(t, revoked("alice-phone"))
+	assert.False(t, revoked("bob-laptop"))
+}
+
+func TestPruneExpiredRefreshTokens(t *testing.T) {
+	storage := NewRefreshTokenStorage()
+
+	expired := newRefreshToken("expired", uuid.New(), uuid.New())
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, storage.CreateRefreshToken(expired))
+	require.NoError(t, storage.RevokeRefreshTokenFamily(expired.FamilyID))
+
+	// Pruning runs on the next write once the interval has passed
+	storage.lastPruned = time.Now().Add(-pruneInterval)
+	require.NoError(t, storage.CreateRefreshToken(newRefreshToken("live", uuid.New(), uuid.New())))
+
+	_, err := storage.ConsumeRefreshToken("expired")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+	assert.Empty(t, storage.revokedFamilies)
+	_, err = storage.ConsumeRefreshToken("live")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package inmemory
+
+import (
+	"bytes"
+	"cmp"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+)
+
+// sortUsers orders users by each key in turn and then by ID, so equal users always come in the same order;
+// without keys users keep their order
+func sortUsers(users []models.User, keys []models.SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(users, func(a, b models.User) int {
+		return compareUsers(&a, &b, keys)
+	})
+}
+
+// compareUsers compares two users by each key in turn and then by ID
+func compareUsers(a, b *models.User, keys []models.SortKey) int {
+	for _, key := range keys {
+		order := compareField(a, b, key.Field)
+		if key.Desc {
+			order = -order
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// compareField compares a field of two users; text is compared ignoring case and false comes before true
+func compareField(a, b *models.User, field string) int {
+	switch field {
+	case "email_verified":
+		if a.EmailVerified == b.EmailVerified {
+			return 0
+		}
+		if b.EmailVerified {
+			return -1
+		}
+		return 1
+	case "created_at", "updated_at":
+		return timeField(a, field).Compare(timeField(b, field))
+	}
+	return cmp.Compare(strings.ToLower(textField(a, field)), strings.ToLower(textField(b, field)))
+}
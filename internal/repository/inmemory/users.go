@@ -1,31 +1,50 @@
 package inmemory
 
 import (
-	"errors"
+	"context"
 	"github.com/google/uuid"
-	"github.com/jinzhu/copier"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/filter"
 	"github.com/sosshik/users-service/internal/models"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 )
 
 type InMemoryStorage struct {
 	mu            sync.RWMutex
-	users         []models.User
+	users         []*models.User
 	idIndex       map[uuid.UUID]*models.User
 	nicknameIndex map[string]*models.User
 	emailIndex    map[string]*models.User
+	// search is nil unless secondary indexes were requested
+	search *searchIndexes
+}
+
+// Option configures an InMemoryStorage
+type Option func(*InMemoryStorage)
+
+// WithSearchIndexes keeps secondary indexes on the country, nickname, email and names so filters
+// on them check only the users the indexes select instead of scanning every user. Writes get slower
+// and the indexes take memory roughly proportional to the length of the indexed values.
+func WithSearchIndexes() Option {
+	return func(s *InMemoryStorage) {
+		s.search = newSearchIndexes()
+	}
 }
 
 // NewInMemory creates a new instance of InMemoryStorage with initialized data structures
-func NewInMemory() *InMemoryStorage {
-	return &InMemoryStorage{
-		users:         make([]models.User, 0),
+func NewInMemory(options ...Option) *InMemoryStorage {
+	s := &InMemoryStorage{
+		users:         make([]*models.User, 0),
 		idIndex:       make(map[uuid.UUID]*models.User),
 		nicknameIndex: make(map[string]*models.User),
 		emailIndex:    make(map[string]*models.User),
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 // CreateUser adds a new user to the in-memory storage
@@ -34,21 +53,25 @@ func (s *InMemoryStorage) CreateUser(user models.User) (models.User, error) {
 	defer s.mu.Unlock()
 
 	// Check if a user with the given nickname or email already exists
-	exists, err := s.nicknameOrEmailExists(user.Nickname, user.Email)
+	exists, err := s.nicknameOrEmailExists(user.Nickname, user.Email, uuid.Nil)
 	if err != nil || exists {
 		return models.User{}, err
 	}
 
-	// Assign a new UUID and set timestamps
+	// Assign a new UUID, set timestamps and start versioning
 	user.ID = uuid.New()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.Version = 1
 
-	// Append the new user to the list and update indexes
-	s.users = append(s.users, user)
-	s.idIndex[user.ID] = &s.users[len(s.users)-1]
-	s.nicknameIndex[user.Nickname] = &s.users[len(s.users)-1]
-	s.emailIndex[user.Email] = &s.users[len(s.users)-1]
+	// Append the new user to the list and update indexes; users are stored by pointer
+	// so the indexes stay valid when the list grows or shrinks
+	stored := &user
+	s.users = append(s.users, stored)
+	s.idIndex[user.ID] = stored
+	s.nicknameIndex[user.Nickname] = stored
+	s.emailIndex[user.Email] = stored
+	s.search.insert(stored)
 
 	return user, nil
 }
@@ -57,117 +80,310 @@ func (s *InMemoryStorage) CreateUser(user models.User) (models.User, error) {
 func (s *InMemoryStorage) NicknameOrEmailExists(nickname, email string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.nicknameOrEmailExists(nickname, email)
+	return s.nicknameOrEmailExists(nickname, email, uuid.Nil)
 }
 
-// nicknameOrEmailExists is a helper function that checks existence of a user by nickname or email
-func (s *InMemoryStorage) nicknameOrEmailExists(nickname, email string) (bool, error) {
-	if _, exists := s.nicknameIndex[nickname]; exists {
-		return true, errors.New("user with this username already exists")
+// nicknameOrEmailExists is a helper function that checks existence of a user by nickname or email,
+// ignoring the user with excludeID so users can keep their own nickname and email
+func (s *InMemoryStorage) nicknameOrEmailExists(nickname, email string, excludeID uuid.UUID) (bool, error) {
+	if other, exists := s.nicknameIndex[nickname]; exists && other.ID != excludeID {
+		return true, apperrors.ErrNicknameTaken
 	}
 
-	if _, exists := s.emailIndex[email]; exists {
-		return true, errors.New("user with this email already exists")
+	if other, exists := s.emailIndex[email]; exists && other.ID != excludeID {
+		return true, apperrors.ErrEmailTaken
 	}
 
 	return false, nil
 }
 
-// GetUser retrieves a user by their ID
+// activeUser returns the stored user with the given ID unless they are missing or soft-deleted
+func (s *InMemoryStorage) activeUser(id uuid.UUID) (*models.User, bool) {
+	user, found := s.idIndex[id]
+	if !found || user.DeletedAt != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// GetUser retrieves a user by their ID, ignoring soft-deleted users
 func (s *InMemoryStorage) GetUser(id uuid.UUID) (models.User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	user, found := s.activeUser(id)
+	if !found {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return *user, nil
+}
+
+// GetUserIncludingDeleted retrieves a user by their ID even if they are soft-deleted
+func (s *InMemoryStorage) GetUserIncludingDeleted(id uuid.UUID) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	user, found := s.idIndex[id]
 	if !found {
-		return models.User{}, errors.New("user not found")
+		return models.User{}, apperrors.ErrUserNotFound
 	}
 
 	return *user, nil
 }
 
-// UpdateUser modifies an existing user's details
-func (s *InMemoryStorage) UpdateUser(user models.User) (models.User, error) {
+// GetUserByLogin retrieves a user whose nickname or email equals login, preferring a nickname match
+func (s *InMemoryStorage) GetUserByLogin(login string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, found := s.nicknameIndex[login]; found && user.DeletedAt == nil {
+		return *user, nil
+	}
+	if user, found := s.emailIndex[login]; found && user.DeletedAt == nil {
+		return *user, nil
+	}
+
+	return models.User{}, apperrors.ErrUserNotFound
+}
+
+// GetUserByEmail retrieves a user by their exact email
+func (s *InMemoryStorage) GetUserByEmail(email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, found := s.emailIndex[email]; found && user.DeletedAt == nil {
+		return *user, nil
+	}
+
+	return models.User{}, apperrors.ErrUserNotFound
+}
+
+// UpdateUser replaces an existing user's profile; the first and last name, nickname, email and country
+// are written exactly as given, so empty values clear them
+func (s *InMemoryStorage) UpdateUser(user models.User, expectedVersion int64) (models.User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check if user exists
-	if _, found := s.idIndex[user.ID]; found {
-		// Validate that new nickname/email does not exist
-		if exists, err := s.nicknameOrEmailExists(user.Nickname, user.Email); err != nil || exists {
-			return models.User{}, err
-		}
-		// Update timestamps and copy data
-		user.UpdatedAt = time.Now()
-		oldUser := *s.idIndex[user.ID]
-		err := copier.CopyWithOption(s.idIndex[user.ID], &user, copier.Option{IgnoreEmpty: true})
-		if err != nil {
-			return models.User{}, err
-		}
-		// Update indexes if nickname/email changed
-		if user.Nickname != oldUser.Nickname {
-			delete(s.nicknameIndex, oldUser.Nickname)
-			s.nicknameIndex[user.Nickname] = s.idIndex[user.ID]
-		}
-		if user.Email != oldUser.Email {
-			delete(s.emailIndex, oldUser.Email)
-			s.emailIndex[user.Email] = s.idIndex[user.ID]
-		}
-		return *s.idIndex[user.ID], nil
+	// Check if user exists and is not deleted
+	stored, found := s.activeUser(user.ID)
+	if !found {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	// Refuse to overwrite changes the caller has not seen
+	if expectedVersion != 0 && stored.Version != expectedVersion {
+		return models.User{}, apperrors.ErrVersionMismatch
+	}
+
+	// Validate that new nickname/email does not belong to another user
+	if exists, err := s.nicknameOrEmailExists(user.Nickname, user.Email, user.ID); err != nil || exists {
+		return models.User{}, err
+	}
+
+	// Update indexes if nickname/email changed
+	if user.Nickname != stored.Nickname {
+		delete(s.nicknameIndex, stored.Nickname)
+		s.nicknameIndex[user.Nickname] = stored
+	}
+	if user.Email != stored.Email {
+		delete(s.emailIndex, stored.Email)
+		s.emailIndex[user.Email] = stored
+		// A new email has to be verified again
+		stored.EmailVerified = false
+		stored.EmailVerifiedAt = nil
+	}
+
+	// Replace the profile while it is out of the search indexes, then update timestamps and version
+	s.search.unindex(stored)
+	stored.FirstName = user.FirstName
+	stored.LastName = user.LastName
+	stored.Nickname = user.Nickname
+	stored.Email = user.Email
+	stored.Country = user.Country
+	s.search.index(stored)
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+
+	return *stored, nil
+}
+
+// SetUserRole assigns a new role to the user
+func (s *InMemoryStorage) SetUserRole(id uuid.UUID, role string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.activeUser(id)
+	if !found {
+		return models.User{}, apperrors.ErrUserNotFound
 	}
 
-	return models.User{}, errors.New("user not found, unable to update")
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	user.Version++
+
+	return *user, nil
 }
 
-// DeleteUser removes a user from storage by their ID
-func (s *InMemoryStorage) DeleteUser(id uuid.UUID) error {
+// UpdateUserPassword replaces the user's password hash
+func (s *InMemoryStorage) UpdateUserPassword(id uuid.UUID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.activeUser(id)
+	if !found {
+		return apperrors.ErrUserNotFound
+	}
+
+	user.Password = hash
+	user.UpdatedAt = time.Now()
+	user.Version++
+
+	return nil
+}
+
+// MarkEmailVerified records that the user controls their current email
+func (s *InMemoryStorage) MarkEmailVerified(id uuid.UUID) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.activeUser(id)
+	if !found {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	now := time.Now()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	user.UpdatedAt = now
+	user.Version++
+
+	return *user, nil
+}
+
+// DeleteUser soft-deletes a user by their ID; the user keeps their nickname and email until purged
+func (s *InMemoryStorage) DeleteUser(id uuid.UUID, expectedVersion int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, found := s.activeUser(id)
+	if !found {
+		return apperrors.ErrUserNotFound
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return apperrors.ErrVersionMismatch
+	}
+
+	now := time.Now()
+	user.DeletedAt = &now
+	user.UpdatedAt = now
+	user.Version++
+
+	return nil
+}
+
+// RestoreUser undoes a soft delete
+func (s *InMemoryStorage) RestoreUser(id uuid.UUID) (models.User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	user, found := s.idIndex[id]
 	if !found {
-		return errors.New("user not found, unable to delete")
+		return models.User{}, apperrors.ErrUserNotFound
 	}
+	if user.DeletedAt == nil {
+		return models.User{}, apperrors.ErrUserNotDeleted
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	user.Version++
+
+	return *user, nil
+}
+
+// HardDeleteUser permanently removes a user, deleted or not, and frees their nickname and email
+func (s *InMemoryStorage) HardDeleteUser(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.idIndex[id]; !found {
+		return apperrors.ErrUserNotFound
+	}
+
+	s.users = slices.DeleteFunc(s.users, func(u *models.User) bool {
+		return u.ID == id
+	})
+	s.removeFromIndexes(id)
 
-	// Remove user from the list and indexes
-	for i, u := range s.users {
-		if u.ID == id {
-			s.users = append(s.users[:i], s.users[i+1:]...)
-			break
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes the users soft-deleted before deletedBefore and returns how many were removed
+func (s *InMemoryStorage) PurgeDeletedUsers(deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []uuid.UUID
+	s.users = slices.DeleteFunc(s.users, func(u *models.User) bool {
+		if u.DeletedAt != nil && u.DeletedAt.Before(deletedBefore) {
+			purged = append(purged, u.ID)
+			return true
 		}
+		return false
+	})
+	for _, id := range purged {
+		s.removeFromIndexes(id)
 	}
 
+	return len(purged), nil
+}
+
+// removeFromIndexes drops a user from every index
+func (s *InMemoryStorage) removeFromIndexes(id uuid.UUID) {
+	user := s.idIndex[id]
 	delete(s.idIndex, id)
 	delete(s.nicknameIndex, user.Nickname)
 	delete(s.emailIndex, user.Email)
+	s.search.delete(user)
+}
 
-	return nil
+// filterCandidates returns the users that have to be checked against expr: those the search indexes
+// select when they can answer it, every user otherwise; both are in insertion order
+func (s *InMemoryStorage) filterCandidates(expr filter.Expr) []*models.User {
+	if users, ok := s.search.candidates(expr); ok {
+		return users
+	}
+	return s.users
 }
 
-// GetFilteredUsers retrieves users based on a filter and pagination parameters
-func (s *InMemoryStorage) GetFilteredUsers(field, value string, limit, offset int) ([]models.User, int, error) {
+// GetFilteredUsers retrieves users based on a filter, sort order and pagination parameters
+func (s *InMemoryStorage) GetFilteredUsers(query models.UserQuery) ([]models.User, int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []models.User
 
-	// Normalize the value to lowercase
-	value = strings.ToLower(value)
-
-	// Filter users based on the provided field and value
-	for _, user := range s.users {
-		if needToIncludeUser(user, field, value) {
-			result = append(result, user)
+	// Filter users, skipping soft-deleted users unless requested
+	for _, user := range s.filterCandidates(query.Filter) {
+		if user.DeletedAt != nil && !query.IncludeDeleted {
+			continue
+		}
+		if matchesFilter(query.Filter, user) {
+			result = append(result, *user)
 		}
 	}
 
+	// Sort before paging so every page continues where the previous one ended
+	sortUsers(result, query.Sort)
+
 	// Implement pagination
-	start := offset
+	start := query.Offset
 	if start > len(result) {
 		start = len(result)
 	}
 
-	end := start + limit
+	end := start + query.Limit
 	if end > len(result) {
 		end = len(result)
 	}
@@ -175,22 +391,47 @@ func (s *InMemoryStorage) GetFilteredUsers(field, value string, limit, offset in
 	return result[start:end], len(result), nil
 }
 
-// needToIncludeUser checks if a user should be included in the result based on the filter criteria
-func needToIncludeUser(user models.User, field, value string) bool {
-	if field == "" || value == "" {
-		return true
-	}
-	switch field {
-	case "nickname":
-		return strings.Contains(strings.ToLower(user.Nickname), value)
-	case "email":
-		return strings.Contains(strings.ToLower(user.Email), value)
-	case "first_name":
-		return strings.Contains(strings.ToLower(user.FirstName), value)
-	case "last_name":
-		return strings.Contains(strings.ToLower(user.LastName), value)
-	case "country":
-		return strings.Contains(strings.ToLower(user.Country), value)
-	}
-	return false
+// SeekFilteredUsers retrieves the users following or preceding a boundary user in sort order
+func (s *InMemoryStorage) SeekFilteredUsers(query models.UserQuery, seek models.UserSeek) ([]models.User, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.User
+	total := 0
+
+	// Filter users and keep those on the requested side of the boundary
+	for _, user := range s.filterCandidates(query.Filter) {
+		if user.DeletedAt != nil && !query.IncludeDeleted {
+			continue
+		}
+		if !matchesFilter(query.Filter, user) {
+			continue
+		}
+		total++
+
+		order := compareUsers(user, &seek.Boundary, query.Sort)
+		if (!seek.Backward && order > 0) || (seek.Backward && order < 0) {
+			result = append(result, *user)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.User) int {
+		return compareUsers(&a, &b, query.Sort)
+	})
+
+	// Going backward the page ends right before the boundary
+	if seek.Backward {
+		return result[max(len(result)-query.Limit, 0):], total, nil
+	}
+	return result[:min(query.Limit, len(result))], total, nil
+}
+
+// Ping reports whether the storage is reachable; the in-memory storage always is
+func (s *InMemoryStorage) Ping(_ context.Context) error {
+	return nil
+}
+
+// Close releases the storage; the in-memory storage holds no external resources
+func (s *InMemoryStorage) Close() error {
+	return nil
 }
@@ -2,6 +2,7 @@ package inmemory
 
 import (
 	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/filter"
 	"github.com/sosshik/users-service/internal/models"
 	"testing"
 )
@@ -80,6 +81,13 @@ func TestUpdateUser(t *testing.T) {
 		LastName:  "User",
 		Country:   "Country",
 	})
+	_, _ = storage.CreateUser(models.User{
+		Nickname:  "otheruser",
+		Email:     "otheruser@example.com",
+		FirstName: "Other",
+		LastName:  "User",
+		Country:   "Country",
+	})
 
 	tests := []struct {
 		name      string
@@ -99,10 +107,22 @@ func TestUpdateUser(t *testing.T) {
 			expectErr: false,
 		},
 		{
-			name: "Update user with existing nickname",
+			name: "Keep own nickname and email",
 			input: models.User{
 				ID:        user.ID,
 				Nickname:  "updateduser",
+				Email:     "updateduser@example.com",
+				FirstName: "Updated",
+				LastName:  "",
+				Country:   "UpdatedCountry",
+			},
+			expectErr: false,
+		},
+		{
+			name: "Update user with existing nickname",
+			input: models.User{
+				ID:        user.ID,
+				Nickname:  "otheruser",
 				Email:     "newemail@example.com",
 				FirstName: "Another",
 				LastName:  "User",
@@ -115,7 +135,7 @@ func TestUpdateUser(t *testing.T) {
 			input: models.User{
 				ID:        user.ID,
 				Nickname:  "differentuser",
-				Email:     "updateduser@example.com",
+				Email:     "otheruser@example.com",
 				FirstName: "Another",
 				LastName:  "User",
 				Country:   "Country",
@@ -138,7 +158,7 @@ func TestUpdateUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := storage.UpdateUser(tt.input)
+			_, err := storage.UpdateUser(tt.input, 0)
 			if (err != nil) != tt.expectErr {
 				t.Errorf("UpdateUser() error = %v, expectErr %v", err, tt.expectErr)
 			}
@@ -214,7 +234,7 @@ func TestDeleteUser(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := storage.DeleteUser(tt.input)
+			err := storage.DeleteUser(tt.input, 0)
 			if (err != nil) != tt.expectErr {
 				t.Errorf("DeleteUser() error = %v, expectErr %v", err, tt.expectErr)
 			}
@@ -258,8 +278,7 @@ func TestGetFilteredUsers(t *testing.T) {
 
 	tests := []struct {
 		name          string
-		field         string
-		value         string
+		filter        string
 		limit         int
 		offset        int
 		expected      []models.User
@@ -267,8 +286,7 @@ func TestGetFilteredUsers(t *testing.T) {
 	}{
 		{
 			name:          "Filter by nickname",
-			field:         "nickname",
-			value:         "bob",
+			filter:        "nickname=bob",
 			limit:         10,
 			offset:        0,
 			expected:      []models.User{users[1]},
@@ -276,8 +294,7 @@ func TestGetFilteredUsers(t *testing.T) {
 		},
 		{
 			name:          "Filter by email",
-			field:         "email",
-			value:         "alice@example.com",
+			filter:        "email=alice@example.com",
 			limit:         10,
 			offset:        0,
 			expected:      []models.User{users[0]},
@@ -285,8 +302,7 @@ func TestGetFilteredUsers(t *testing.T) {
 		},
 		{
 			name:          "Filter by first name",
-			field:         "first_name",
-			value:         "Bob",
+			filter:        "first_name=Bob",
 			limit:         10,
 			offset:        0,
 			expected:      []models.User{users[1]},
@@ -294,8 +310,7 @@ func TestGetFilteredUsers(t *testing.T) {
 		},
 		{
 			name:          "Filter by country",
-			field:         "country",
-			value:         "USA",
+			filter:        "country=USA",
 			limit:         1,
 			offset:        0,
 			expected:      []models.User{users[1]},
@@ -303,8 +318,7 @@ func TestGetFilteredUsers(t *testing.T) {
 		},
 		{
 			name:          "Pagination",
-			field:         "nickname",
-			value:         "",
+			filter:        "",
 			limit:         2,
 			offset:        1,
 			expected:      []models.User{users[1], users[2]},
@@ -312,8 +326,7 @@ func TestGetFilteredUsers(t *testing.T) {
 		},
 		{
 			name:          "No results",
-			field:         "nickname",
-			value:         "nonexistent",
+			filter:        "nickname=nonexistent",
 			limit:         10,
 			offset:        0,
 			expected:      []models.User{},
@@ -323,7 +336,11 @@ func TestGetFilteredUsers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, count, err := storage.GetFilteredUsers(tt.field, tt.value, tt.limit, tt.offset)
+			userFilter, err := filter.Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("filter.Parse() error = %v", err)
+			}
+			result, count, err := storage.GetFilteredUsers(models.UserQuery{Filter: userFilter, Limit: tt.limit, Offset: tt.offset})
 			if err != nil {
 				t.Errorf("GetFilteredUsers() error = %v", err)
 				return
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+// Mock login attempt repository
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) BeginLoginAttempt(key string, ttl time.Duration, blockedUntil func(models.LoginAttempts) time.Time) (models.LoginAttempt, time.Time, error) {
+	args := m.Called(key, ttl, blockedUntil)
+	return args.Get(0).(models.LoginAttempt), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockLoginAttemptRepository) RefundLoginAttempt(attempt models.LoginAttempt) error {
+	args := m.Called(attempt)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) GetLoginAttempts(key string) (models.LoginAttempts, error) {
+	args := m.Called(key)
+	return args.Get(0).(models.LoginAttempts), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) ResetLoginAttempts(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+// Keep the mock in sync with the repository interface
+var _ repository.LoginAttempts = (*MockLoginAttemptRepository)(nil)
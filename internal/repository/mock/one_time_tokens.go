@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock one-time token repository
+type MockOneTimeTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockOneTimeTokenRepository) CreateOneTimeToken(token models.OneTimeToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockOneTimeTokenRepository) GetOneTimeToken(purpose, hash string) (models.OneTimeToken, error) {
+	args := m.Called(purpose, hash)
+	return args.Get(0).(models.OneTimeToken), args.Error(1)
+}
+
+func (m *MockOneTimeTokenRepository) ConsumeOneTimeToken(purpose, hash string) (models.OneTimeToken, error) {
+	args := m.Called(purpose, hash)
+	return args.Get(0).(models.OneTimeToken), args.Error(1)
+}
+
+func (m *MockOneTimeTokenRepository) DeleteUserOneTimeTokens(userID uuid.UUID, purpose string) error {
+	args := m.Called(userID, purpose)
+	return args.Error(0)
+}
+
+// Keep the mock in sync with the repository interface
+var _ repository.OneTimeTokens = (*MockOneTimeTokenRepository)(nil)
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock refresh token repository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) CreateRefreshToken(token models.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) ConsumeRefreshToken(hash string) (models.RefreshToken, error) {
+	args := m.Called(hash)
+	return args.Get(0).(models.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeRefreshTokenFamily(familyID uuid.UUID) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeUserRefreshTokens(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// Keep the mock in sync with the repository interface
+var _ repository.RefreshTokens = (*MockRefreshTokenRepository)(nil)
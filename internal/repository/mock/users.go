@@ -1,9 +1,12 @@
 package mocks
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 // Mock repository
@@ -21,22 +24,85 @@ func (m *MockUserRepository) GetUser(id uuid.UUID) (models.User, error) {
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) UpdateUser(user models.User) (models.User, error) {
-	args := m.Called(user)
+func (m *MockUserRepository) GetUserIncludingDeleted(id uuid.UUID) (models.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserByLogin(login string) (models.User, error) {
+	args := m.Called(login)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserByEmail(email string) (models.User, error) {
+	args := m.Called(email)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateUser(user models.User, expectedVersion int64) (models.User, error) {
+	args := m.Called(user, expectedVersion)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) SetUserRole(id uuid.UUID, role string) (models.User, error) {
+	args := m.Called(id, role)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateUserPassword(id uuid.UUID, hash string) error {
+	args := m.Called(id, hash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DeleteUser(id uuid.UUID, expectedVersion int64) error {
+	args := m.Called(id, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RestoreUser(id uuid.UUID) (models.User, error) {
+	args := m.Called(id)
 	return args.Get(0).(models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) DeleteUser(id uuid.UUID) error {
+func (m *MockUserRepository) HardDeleteUser(id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) PurgeDeletedUsers(deletedBefore time.Time) (int, error) {
+	args := m.Called(deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(id uuid.UUID) (models.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
 func (m *MockUserRepository) NicknameOrEmailExists(nickname, email string) (bool, error) {
 	args := m.Called(nickname, email)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockUserRepository) GetFilteredUsers(field, value string, limit, offset int) ([]models.User, int, error) {
-	args := m.Called(field, value, limit, offset)
+func (m *MockUserRepository) GetFilteredUsers(query models.UserQuery) ([]models.User, int, error) {
+	args := m.Called(query)
 	return args.Get(0).([]models.User), args.Int(1), args.Error(2)
 }
+
+func (m *MockUserRepository) SeekFilteredUsers(query models.UserQuery, seek models.UserSeek) ([]models.User, int, error) {
+	args := m.Called(query, seek)
+	return args.Get(0).([]models.User), args.Int(1), args.Error(2)
+}
+
+func (m *MockUserRepository) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+// Keep the mock in sync with the repository interface
+var _ repository.Users = (*MockUserRepository)(nil)
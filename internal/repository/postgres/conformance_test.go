@@ -0,0 +1,42 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/sosshik/users-service/internal/repository/postgres"
+	"github.com/sosshik/users-service/internal/repository/repositorytest"
+	"os"
+	"testing"
+)
+
+// TestUsersConformance runs against the database from USERS_SERVICE_TEST_POSTGRES_DSN,
+// which is truncated before every run. It is skipped without one, except on CI, where a missing
+// database must not pass for a green PostgreSQL backend.
+func TestUsersConformance(t *testing.T) {
+	dsn := os.Getenv("USERS_SERVICE_TEST_POSTGRES_DSN")
+	if dsn == "" && os.Getenv("CI") != "" {
+		t.Fatal("USERS_SERVICE_TEST_POSTGRES_DSN must be set when CI is")
+	}
+	if dsn == "" {
+		t.Skip("USERS_SERVICE_TEST_POSTGRES_DSN is not set, so the PostgreSQL backend is not tested")
+	}
+
+	storage, err := postgres.NewPostgres(dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repositorytest.RunUsersConformance(t, func() repository.Users {
+		if _, err := db.Exec(`TRUNCATE users`); err != nil {
+			t.Fatalf("Failed to truncate users: %v", err)
+		}
+		return storage
+	})
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/filter"
+	"strings"
+)
+
+// filterColumns maps the filterable fields to their columns
+var filterColumns = map[string]string{
+	"nickname":       "nickname",
+	"email":          "email",
+	"first_name":     "first_name",
+	"last_name":      "last_name",
+	"country":        "country",
+	"role":           "role",
+	"email_verified": "email_verified",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}
+
+// comparisons maps the operators that compare values directly to SQL
+var comparisons = map[filter.Operator]string{
+	filter.Eq:  "=",
+	filter.Neq: "<>",
+	filter.Gt:  ">",
+	filter.Lt:  "<",
+}
+
+// whereFilter translates a parsed filter into a condition with numbered placeholders, appending its values to args
+func whereFilter(expr filter.Expr, args []any) (string, []any, error) {
+	switch expr := expr.(type) {
+	case filter.And:
+		return joinFilters(expr, ` AND `, args)
+	case filter.Or:
+		return joinFilters(expr, ` OR `, args)
+	case *filter.Condition:
+		return whereCondition(expr, args)
+	}
+	return "", nil, fmt.Errorf("unsupported filter expression %T", expr)
+}
+
+// joinFilters translates every term and joins them with op
+func joinFilters(terms []filter.Expr, op string, args []any) (string, []any, error) {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part, termArgs, err := whereFilter(term, args)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+		args = termArgs
+	}
+	return `(` + strings.Join(parts, op) + `)`, args, nil
+}
+
+// whereCondition translates a single condition; text is compared case-insensitively with ILIKE
+// and escaped LIKE wildcards
+func whereCondition(cond *filter.Condition, args []any) (string, []any, error) {
+	column, ok := filterColumns[cond.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported filter field %q", cond.Field)
+	}
+
+	// placeholder appends a value and returns its placeholder
+	placeholder := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf(`$%d`, len(args))
+	}
+
+	switch cond.Kind {
+	case filter.Bool:
+		return column + ` ` + comparisons[cond.Op] + ` ` + placeholder(cond.Bool), args, nil
+	case filter.Time:
+		return column + ` ` + comparisons[cond.Op] + ` ` + placeholder(cond.Time), args, nil
+	}
+
+	switch cond.Op {
+	case filter.Eq:
+		return column + ` ILIKE ` + placeholder(escapeLike(cond.Text[0])), args, nil
+	case filter.Neq:
+		return column + ` NOT ILIKE ` + placeholder(escapeLike(cond.Text[0])), args, nil
+	case filter.Contains:
+		return column + ` ILIKE '%' || ` + placeholder(escapeLike(cond.Text[0])) + ` || '%'`, args, nil
+	case filter.Prefix:
+		return column + ` ILIKE ` + placeholder(escapeLike(cond.Text[0])) + ` || '%'`, args, nil
+	case filter.In:
+		matches := make([]string, 0, len(cond.Text))
+		for _, text := range cond.Text {
+			matches = append(matches, column+` ILIKE `+placeholder(escapeLike(text)))
+		}
+		return `(` + strings.Join(matches, ` OR `) + `)`, args, nil
+	}
+	return "", nil, fmt.Errorf("unsupported filter operator %q for %s", cond.Op, cond.Field)
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWhereFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       string
+		initialArgs  []any
+		expectedSQL  string
+		expectedArgs []any
+	}{
+		{
+			name:         "Short form",
+			filter:       "nickname=al_",
+			expectedSQL:  `nickname ILIKE '%' || $1 || '%'`,
+			expectedArgs: []any{`al\_`},
+		},
+		{
+			name:         "Placeholders continue after existing arguments",
+			filter:       "country eq DE",
+			initialArgs:  []any{"earlier"},
+			expectedSQL:  `country ILIKE $2`,
+			expectedArgs: []any{"earlier", "DE"},
+		},
+		{
+			name:         "Prefix and not equal",
+			filter:       "email prefix admin AND role neq user",
+			expectedSQL:  `(email ILIKE $1 || '%' AND role NOT ILIKE $2)`,
+			expectedArgs: []any{"admin", "user"},
+		},
+		{
+			name:         "In",
+			filter:       `country in (DE, "AT")`,
+			expectedSQL:  `(country ILIKE $1 OR country ILIKE $2)`,
+			expectedArgs: []any{"DE", "AT"},
+		},
+		{
+			name:         "Nested groups",
+			filter:       "email_verified eq true OR (created_at gt 2024-01-01 AND updated_at lt 2024-02-01)",
+			expectedSQL:  `(email_verified = $1 OR (created_at > $2 AND updated_at < $3))`,
+			expectedArgs: []any{true, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := filter.Parse(tt.filter)
+			require.NoError(t, err)
+
+			where, args, err := whereFilter(expr, tt.initialArgs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSQL, where)
+			assert.Equal(t, tt.expectedArgs, args)
+		})
+	}
+}
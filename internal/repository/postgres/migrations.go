@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrationLockID is the advisory lock key that serializes migrations across service instances
+const migrationLockID = 7318230512
+
+// migrations holds the schema changes in the order they must be applied.
+// Applied migrations are recorded in schema_migrations, so entries must never be edited or reordered.
+var migrations = []string{
+	`CREATE TABLE users (
+		seq        BIGINT GENERATED ALWAYS AS IDENTITY,
+		id         UUID PRIMARY KEY,
+		first_name TEXT NOT NULL DEFAULT '',
+		last_name  TEXT NOT NULL DEFAULT '',
+		nickname   TEXT NOT NULL,
+		password   TEXT NOT NULL DEFAULT '',
+		email      TEXT NOT NULL,
+		country    TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		CONSTRAINT users_nickname_key UNIQUE (nickname),
+		CONSTRAINT users_email_key UNIQUE (email)
+	)`,
+	`CREATE INDEX users_seq_idx ON users (seq)`,
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`,
+	`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ`,
+	`ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ`,
+	`CREATE INDEX users_deleted_at_idx ON users (deleted_at)`,
+	`ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1`,
+	`CREATE INDEX users_created_at_id_idx ON users (created_at, id)`,
+}
+
+// migrate applies all pending migrations inside a single transaction
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Make sure concurrently starting instances do not race on the schema
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        []models.SortKey
+		expectedSQL string
+	}{
+		{
+			name:        "Insertion order",
+			expectedSQL: ` ORDER BY seq`,
+		},
+		{
+			name:        "Text and timestamps",
+			keys:        []models.SortKey{{Field: "created_at", Desc: true}, {Field: "nickname"}},
+			expectedSQL: ` ORDER BY created_at DESC, lower(nickname) COLLATE "C", id`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := orderBy(tt.keys)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSQL, order)
+		})
+	}
+
+	_, err := orderBy([]models.SortKey{{Field: "password"}})
+	assert.Error(t, err)
+}
+
+func TestWhereSeek(t *testing.T) {
+	id := uuid.New()
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	boundary := models.User{ID: id, Nickname: "Alice", CreatedAt: createdAt}
+
+	tests := []struct {
+		name         string
+		keys         []models.SortKey
+		backward     bool
+		initialArgs  []any
+		expectedSQL  string
+		expectedArgs []any
+	}{
+		{
+			name:         "ID only",
+			expectedSQL:  `((id > $1))`,
+			expectedArgs: []any{id},
+		},
+		{
+			name:         "Keys in turn with placeholders after existing arguments",
+			keys:         []models.SortKey{{Field: "created_at", Desc: true}, {Field: "nickname"}},
+			initialArgs:  []any{"earlier"},
+			expectedSQL:  `((created_at < $2) OR (created_at = $2 AND lower(nickname) COLLATE "C" > $3) OR (created_at = $2 AND lower(nickname) COLLATE "C" = $3 AND id > $4))`,
+			expectedArgs: []any{"earlier", createdAt, "alice", id},
+		},
+		{
+			name:         "Backward",
+			keys:         []models.SortKey{{Field: "nickname"}},
+			backward:     true,
+			expectedSQL:  `((lower(nickname) COLLATE "C" < $1) OR (lower(nickname) COLLATE "C" = $1 AND id < $2))`,
+			expectedArgs: []any{"alice", id},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := whereSeek(tt.keys, models.UserSeek{Boundary: boundary, Backward: tt.backward}, tt.initialArgs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedSQL, where)
+			assert.Equal(t, tt.expectedArgs, args)
+		})
+	}
+
+	order, err := keyOrder([]models.SortKey{{Field: "created_at", Desc: true}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, ` ORDER BY created_at, id DESC`, order)
+}
@@ -0,0 +1,509 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// uniqueViolation is the SQLSTATE code Postgres reports for unique constraint violations
+const uniqueViolation = "23505"
+
+// userColumns lists the users table columns in the order expected by scanUser
+const userColumns = `id, first_name, last_name, nickname, password, email, country, role, email_verified,
+	email_verified_at, created_at, updated_at, deleted_at, version`
+
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// NewPostgres connects to the database behind dsn and applies pending schema migrations
+func NewPostgres(dsn string) (*PostgresStorage, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to connect to postgres: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to migrate postgres schema: %w", err)
+	}
+
+	return &PostgresStorage{db: db}, nil
+}
+
+// CreateUser inserts a new user into the users table
+func (s *PostgresStorage) CreateUser(user models.User) (models.User, error) {
+	// Check if a user with the given nickname or email already exists
+	exists, err := nicknameOrEmailExists(s.db, user.Nickname, user.Email, uuid.Nil)
+	if err != nil || exists {
+		return models.User{}, err
+	}
+
+	// Assign a new UUID, set timestamps and start versioning
+	now := time.Now()
+	user.ID = uuid.New()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+
+	row := s.db.QueryRow(`INSERT INTO users (`+userColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING `+userColumns,
+		user.ID, user.FirstName, user.LastName, user.Nickname, user.Password, user.Email, user.Country, user.Role,
+		user.EmailVerified, user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt, user.DeletedAt, user.Version)
+
+	// The unique constraints still guard against concurrent inserts racing past the check above
+	user, err = scanUser(row)
+	if err != nil {
+		return models.User{}, mapError(err)
+	}
+
+	return user, nil
+}
+
+// NicknameOrEmailExists checks if a user with the given nickname or email already exists
+func (s *PostgresStorage) NicknameOrEmailExists(nickname, email string) (bool, error) {
+	return nicknameOrEmailExists(s.db, nickname, email, uuid.Nil)
+}
+
+// nicknameOrEmailExists is a helper function that checks existence of a user by nickname or email
+func nicknameOrEmailExists(q querier, nickname, email string, excludeID uuid.UUID) (bool, error) {
+	var nicknameExists, emailExists bool
+	err := q.QueryRow(`SELECT
+		EXISTS (SELECT 1 FROM users WHERE nickname = $1 AND id <> $3),
+		EXISTS (SELECT 1 FROM users WHERE email = $2 AND id <> $3)`,
+		nickname, email, excludeID).Scan(&nicknameExists, &emailExists)
+	if err != nil {
+		return false, err
+	}
+
+	if nicknameExists {
+		return true, apperrors.ErrNicknameTaken
+	}
+
+	if emailExists {
+		return true, apperrors.ErrEmailTaken
+	}
+
+	return false, nil
+}
+
+// GetUser retrieves a user by their ID, ignoring soft-deleted users
+func (s *PostgresStorage) GetUser(id uuid.UUID) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserIncludingDeleted retrieves a user by their ID even if they are soft-deleted
+func (s *PostgresStorage) GetUserIncludingDeleted(id uuid.UUID) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserByLogin retrieves a user whose nickname or email equals login, preferring a nickname match
+func (s *PostgresStorage) GetUserByLogin(login string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users
+		WHERE (nickname = $1 OR email = $1) AND deleted_at IS NULL
+		ORDER BY nickname = $1 DESC
+		LIMIT 1`, login))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserByEmail retrieves a user by their exact email
+func (s *PostgresStorage) GetUserByEmail(email string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1 AND deleted_at IS NULL`, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// UpdateUser replaces an existing user's profile; the first and last name, nickname, email and country
+// are written exactly as given, so empty values clear them
+func (s *PostgresStorage) UpdateUser(user models.User, expectedVersion int64) (models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	// Check if user exists, is not deleted and has not changed since the caller read it
+	if err := lockVersion(tx, user.ID, expectedVersion); err != nil {
+		return models.User{}, err
+	}
+
+	// Validate that new nickname/email does not belong to another user
+	if exists, err := nicknameOrEmailExists(tx, user.Nickname, user.Email, user.ID); err != nil || exists {
+		return models.User{}, err
+	}
+
+	row := tx.QueryRow(`UPDATE users SET
+			first_name = $2,
+			last_name  = $3,
+			nickname   = $4,
+			email      = $5,
+			country    = $6,
+			updated_at = $7,
+			version    = version + 1,
+			-- A new email has to be verified again
+			email_verified    = CASE WHEN $5 = email THEN email_verified ELSE FALSE END,
+			email_verified_at = CASE WHEN $5 = email THEN email_verified_at ELSE NULL END
+		WHERE id = $1
+		RETURNING `+userColumns,
+		user.ID, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country, time.Now())
+
+	updated, err := scanUser(row)
+	if err != nil {
+		return models.User{}, mapError(err)
+	}
+
+	return updated, tx.Commit()
+}
+
+// SetUserRole assigns a new role to the user
+func (s *PostgresStorage) SetUserRole(id uuid.UUID, role string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`UPDATE users SET role = $2, updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+userColumns, id, role, time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// MarkEmailVerified records that the user controls their current email
+func (s *PostgresStorage) MarkEmailVerified(id uuid.UUID) (models.User, error) {
+	now := time.Now()
+	user, err := scanUser(s.db.QueryRow(`UPDATE users SET email_verified = TRUE, email_verified_at = $2, updated_at = $2,
+			version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+userColumns, id, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// UpdateUserPassword replaces the user's password hash
+func (s *PostgresStorage) UpdateUserPassword(id uuid.UUID, hash string) error {
+	res, err := s.db.Exec(`UPDATE users SET password = $2, updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`, id, hash, time.Now())
+	return requireAffected(res, err)
+}
+
+// DeleteUser soft-deletes a user by their ID; the user keeps their nickname and email until purged
+func (s *PostgresStorage) DeleteUser(id uuid.UUID, expectedVersion int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockVersion(tx, id, expectedVersion); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET deleted_at = $2, updated_at = $2, version = version + 1 WHERE id = $1`,
+		id, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockVersion locks the user's row for the rest of the transaction. It fails with ErrUserNotFound unless
+// the user exists and is not deleted, and with ErrVersionMismatch unless expectedVersion is 0 or the stored version.
+func lockVersion(q querier, id uuid.UUID, expectedVersion int64) error {
+	var version int64
+	err := q.QueryRow(`SELECT version FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if expectedVersion != 0 && version != expectedVersion {
+		return apperrors.ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// RestoreUser undoes a soft delete
+func (s *PostgresStorage) RestoreUser(id uuid.UUID) (models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	// Tell a missing user apart from one that is not deleted, and lock the row for the rest of the transaction
+	var deletedAt sql.NullTime
+	err = tx.QueryRow(`SELECT deleted_at FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if !deletedAt.Valid {
+		return models.User{}, apperrors.ErrUserNotDeleted
+	}
+
+	user, err := scanUser(tx.QueryRow(`UPDATE users SET deleted_at = NULL, updated_at = $2, version = version + 1
+		WHERE id = $1
+		RETURNING `+userColumns, id, time.Now()))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+// HardDeleteUser permanently removes a user, deleted or not, and frees their nickname and email
+func (s *PostgresStorage) HardDeleteUser(id uuid.UUID) error {
+	res, err := s.db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	return requireAffected(res, err)
+}
+
+// PurgeDeletedUsers permanently removes the users soft-deleted before deletedBefore and returns how many were removed
+func (s *PostgresStorage) PurgeDeletedUsers(deletedBefore time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM users WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// requireAffected reports ErrUserNotFound when a statement changed no users
+func requireAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetFilteredUsers retrieves users based on a filter, sort order and pagination parameters
+func (s *PostgresStorage) GetFilteredUsers(query models.UserQuery) ([]models.User, int, error) {
+	conditions, args, err := queryConditions(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, err := orderBy(query.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s.selectPage(conditions, args, "", nil, order, query.Limit, query.Offset)
+}
+
+// SeekFilteredUsers retrieves the users following or preceding a boundary user in sort order
+func (s *PostgresStorage) SeekFilteredUsers(query models.UserQuery, seek models.UserSeek) ([]models.User, int, error) {
+	conditions, args, err := queryConditions(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only the rows beyond the boundary are read, so deep pages cost no more than the first
+	seekCondition, seekArgs, err := whereSeek(query.Sort, seek, args)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, err := keyOrder(query.Sort, seek.Backward)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users, total, err := s.selectPage(conditions, args, seekCondition, seekArgs[len(args):], order, query.Limit, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Going backward the nearest rows come first
+	if seek.Backward {
+		slices.Reverse(users)
+	}
+
+	return users, total, nil
+}
+
+// queryConditions translates the filter of query and the visibility of deleted users into conditions
+func queryConditions(query models.UserQuery) ([]string, []any, error) {
+	var conditions []string
+	var args []any
+
+	// Soft-deleted users are hidden unless requested
+	if !query.IncludeDeleted {
+		conditions = append(conditions, `deleted_at IS NULL`)
+	}
+
+	// Translate the filter into a parameterized condition
+	if query.Filter != nil {
+		condition, filterArgs, err := whereFilter(query.Filter, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, condition)
+		args = filterArgs
+	}
+
+	return conditions, args, nil
+}
+
+// selectPage counts the users matching conditions and selects a page of those also matching the seek condition, if any;
+// the placeholders of the seek condition continue those of conditions
+func (s *PostgresStorage) selectPage(conditions []string, args []any, seekCondition string, seekArgs []any,
+	order string, limit, offset int) ([]models.User, int, error) {
+	pageConditions, pageArgs := conditions, args
+	if seekCondition != "" {
+		pageConditions = append(slices.Clip(conditions), seekCondition)
+		pageArgs = append(slices.Clip(args), seekArgs...)
+	}
+
+	// Count and page within one snapshot so the total matches the returned page
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users`+whereClause(conditions), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := tx.Query(`SELECT `+userColumns+` FROM users`+whereClause(pageConditions)+order+
+		fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(pageArgs)+1, len(pageArgs)+2),
+		append(pageArgs, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var result []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, tx.Commit()
+}
+
+// whereClause joins conditions into a WHERE clause; no conditions select every row
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+// Ping verifies that the database can still be reached
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the underlying database connections
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
+}
+
+// scanUser reads a single users row selected with userColumns
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var verifiedAt, deletedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Password,
+		&user.Email, &user.Country, &user.Role, &user.EmailVerified, &verifiedAt, &user.CreatedAt, &user.UpdatedAt,
+		&deletedAt, &user.Version)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+
+	return user, nil
+}
+
+// mapError translates unique constraint violations into domain errors
+func mapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		switch pgErr.ConstraintName {
+		case "users_nickname_key":
+			return apperrors.ErrNicknameTaken
+		case "users_email_key":
+			return apperrors.ErrEmailTaken
+		}
+	}
+	return err
+}
+
+// escapeLike escapes the LIKE wildcards so the value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -1,26 +1,103 @@
 package repository
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/config"
 	"github.com/sosshik/users-service/internal/models"
 	"github.com/sosshik/users-service/internal/repository/inmemory"
+	"github.com/sosshik/users-service/internal/repository/postgres"
+	"github.com/sosshik/users-service/internal/repository/sqlite"
+	"time"
 )
 
 type Users interface {
 	CreateUser(user models.User) (models.User, error)
 	GetUser(id uuid.UUID) (models.User, error)
-	UpdateUser(user models.User) (models.User, error)
-	DeleteUser(id uuid.UUID) error
+	GetUserIncludingDeleted(id uuid.UUID) (models.User, error)
+	GetUserByLogin(login string) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	// UpdateUser and DeleteUser fail with apperrors.ErrVersionMismatch unless expectedVersion is 0
+	// or equals the stored version
+	UpdateUser(user models.User, expectedVersion int64) (models.User, error)
+	SetUserRole(id uuid.UUID, role string) (models.User, error)
+	UpdateUserPassword(id uuid.UUID, hash string) error
+	MarkEmailVerified(id uuid.UUID) (models.User, error)
+	DeleteUser(id uuid.UUID, expectedVersion int64) error
+	RestoreUser(id uuid.UUID) (models.User, error)
+	HardDeleteUser(id uuid.UUID) error
+	PurgeDeletedUsers(deletedBefore time.Time) (int, error)
 	NicknameOrEmailExists(nickname, email string) (bool, error)
-	GetFilteredUsers(field, value string, limit, offset int) ([]models.User, int, error)
+	GetFilteredUsers(query models.UserQuery) ([]models.User, int, error)
+	// SeekFilteredUsers returns up to query.Limit users next to seek.Boundary in sort order instead of
+	// skipping query.Offset users, together with the number of users matching the filter
+	SeekFilteredUsers(query models.UserQuery, seek models.UserSeek) ([]models.User, int, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type RefreshTokens interface {
+	CreateRefreshToken(token models.RefreshToken) error
+	ConsumeRefreshToken(hash string) (models.RefreshToken, error)
+	RevokeRefreshTokenFamily(familyID uuid.UUID) error
+	RevokeUserRefreshTokens(userID uuid.UUID) error
+}
+
+type OneTimeTokens interface {
+	CreateOneTimeToken(token models.OneTimeToken) error
+	GetOneTimeToken(purpose, hash string) (models.OneTimeToken, error)
+	ConsumeOneTimeToken(purpose, hash string) (models.OneTimeToken, error)
+	DeleteUserOneTimeTokens(userID uuid.UUID, purpose string) error
+}
+
+type LoginAttempts interface {
+	BeginLoginAttempt(key string, ttl time.Duration, blockedUntil func(models.LoginAttempts) time.Time) (models.LoginAttempt, time.Time, error)
+	RefundLoginAttempt(attempt models.LoginAttempt) error
+	GetLoginAttempts(key string) (models.LoginAttempts, error)
+	ResetLoginAttempts(key string) error
 }
 
 type Repository struct {
 	Users
+	RefreshTokens
+	OneTimeTokens
+	LoginAttempts
 }
 
-func NewRepository() *Repository {
-	return &Repository{
-		Users: inmemory.NewInMemory(),
+// NewRepository creates a repository backed by the configured storage.
+// Refresh and one-time tokens and failed login counters are always kept in memory, so sessions,
+// pending password resets and lockouts end when the service restarts.
+func NewRepository(cfg config.StorageConfig) (*Repository, error) {
+	repo := &Repository{
+		RefreshTokens: inmemory.NewRefreshTokenStorage(),
+		OneTimeTokens: inmemory.NewOneTimeTokenStorage(),
+		LoginAttempts: inmemory.NewLoginAttemptStorage(),
+	}
+
+	switch cfg.Backend {
+	case config.StorageMemory:
+		var options []inmemory.Option
+		if cfg.SearchIndexes {
+			options = append(options, inmemory.WithSearchIndexes())
+		}
+		repo.Users = inmemory.NewInMemory(options...)
+		return repo, nil
+	case config.StoragePostgres:
+		users, err := postgres.NewPostgres(cfg.DSN)
+		if err != nil {
+			return nil, err
+		}
+		repo.Users = users
+		return repo, nil
+	case config.StorageSQLite:
+		users, err := sqlite.NewSQLite(cfg.DSN)
+		if err != nil {
+			return nil, err
+		}
+		repo.Users = users
+		return repo, nil
 	}
+
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
 }
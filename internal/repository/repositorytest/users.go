@@ -0,0 +1,1113 @@
+// Package repositorytest provides a behavioral test suite shared by every repository.Users implementation.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/filter"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunUsersConformance runs the shared behavioral tests against the storage returned by factory.
+// Every call to factory must return a storage that contains no users.
+func RunUsersConformance(t *testing.T, factory func() repository.Users) {
+	t.Run("CreateUser", func(t *testing.T) { testCreateUser(t, factory()) })
+	t.Run("CreateUserConflicts", func(t *testing.T) { testCreateUserConflicts(t, factory()) })
+	t.Run("GetUser", func(t *testing.T) { testGetUser(t, factory()) })
+	t.Run("GetUserByLogin", func(t *testing.T) { testGetUserByLogin(t, factory()) })
+	t.Run("GetUserByEmail", func(t *testing.T) { testGetUserByEmail(t, factory()) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, factory()) })
+	t.Run("UpdateUserConflicts", func(t *testing.T) { testUpdateUserConflicts(t, factory()) })
+	t.Run("Versioning", func(t *testing.T) { testVersioning(t, factory()) })
+	t.Run("ConcurrentVersionedUpdates", func(t *testing.T) { testConcurrentVersionedUpdates(t, factory()) })
+	t.Run("SetUserRole", func(t *testing.T) { testSetUserRole(t, factory()) })
+	t.Run("UpdateUserPassword", func(t *testing.T) { testUpdateUserPassword(t, factory()) })
+	t.Run("EmailVerification", func(t *testing.T) { testEmailVerification(t, factory()) })
+	t.Run("DeleteUser", func(t *testing.T) { testDeleteUser(t, factory()) })
+	t.Run("DeletedUsersAreHidden", func(t *testing.T) { testDeletedUsersAreHidden(t, factory()) })
+	t.Run("RestoreUser", func(t *testing.T) { testRestoreUser(t, factory()) })
+	t.Run("HardDeleteUser", func(t *testing.T) { testHardDeleteUser(t, factory()) })
+	t.Run("PurgeDeletedUsers", func(t *testing.T) { testPurgeDeletedUsers(t, factory()) })
+	t.Run("NicknameOrEmailExists", func(t *testing.T) { testNicknameOrEmailExists(t, factory()) })
+	t.Run("GetFilteredUsers", func(t *testing.T) { testGetFilteredUsers(t, factory()) })
+	t.Run("FilterTimestamps", func(t *testing.T) { testFilterTimestamps(t, factory()) })
+	t.Run("FilterAfterChanges", func(t *testing.T) { testFilterAfterChanges(t, factory()) })
+	t.Run("Sort", func(t *testing.T) { testSort(t, factory()) })
+	t.Run("Seek", func(t *testing.T) { testSeek(t, factory()) })
+	t.Run("SeekWhileChanging", func(t *testing.T) { testSeekWhileChanging(t, factory()) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, factory()) })
+	t.Run("ConcurrentAccess", func(t *testing.T) { testConcurrentAccess(t, factory()) })
+	t.Run("Ping", func(t *testing.T) { assert.NoError(t, factory().Ping(context.Background())) })
+}
+
+// newUser builds a valid user whose unique fields are derived from name
+func newUser(name string) models.User {
+	return models.User{
+		FirstName: "First " + name,
+		LastName:  "Last " + name,
+		Nickname:  name,
+		Password:  "hash-" + name,
+		Email:     name + "@example.com",
+		Country:   "Country",
+		Role:      models.RoleUser,
+	}
+}
+
+// mustCreate stores the given users and returns them in creation order
+func mustCreate(t *testing.T, storage repository.Users, users ...models.User) []models.User {
+	t.Helper()
+
+	created := make([]models.User, 0, len(users))
+	for _, user := range users {
+		stored, err := storage.CreateUser(user)
+		require.NoError(t, err, "creating user %s", user.Nickname)
+		created = append(created, stored)
+	}
+
+	return created
+}
+
+// assertSameUser compares users ignoring the monotonic clock and location of timestamps
+func assertSameUser(t *testing.T, expected, actual models.User) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.FirstName, actual.FirstName)
+	assert.Equal(t, expected.LastName, actual.LastName)
+	assert.Equal(t, expected.Nickname, actual.Nickname)
+	assert.Equal(t, expected.Password, actual.Password)
+	assert.Equal(t, expected.Email, actual.Email)
+	assert.Equal(t, expected.Country, actual.Country)
+	assert.Equal(t, expected.Role, actual.Role)
+	assert.Equal(t, expected.EmailVerified, actual.EmailVerified)
+	assert.Equal(t, expected.Version, actual.Version)
+	if assert.Equal(t, expected.EmailVerifiedAt == nil, actual.EmailVerifiedAt == nil, "email_verified_at presence") && expected.EmailVerifiedAt != nil {
+		assert.True(t, expected.EmailVerifiedAt.Equal(*actual.EmailVerifiedAt), "email_verified_at %s != %s", expected.EmailVerifiedAt, actual.EmailVerifiedAt)
+	}
+	assert.True(t, expected.CreatedAt.Equal(actual.CreatedAt), "created_at %s != %s", expected.CreatedAt, actual.CreatedAt)
+	assert.True(t, expected.UpdatedAt.Equal(actual.UpdatedAt), "updated_at %s != %s", expected.UpdatedAt, actual.UpdatedAt)
+	if assert.Equal(t, expected.DeletedAt == nil, actual.DeletedAt == nil, "deleted_at presence") && expected.DeletedAt != nil {
+		assert.True(t, expected.DeletedAt.Equal(*actual.DeletedAt), "deleted_at %s != %s", expected.DeletedAt, actual.DeletedAt)
+	}
+}
+
+// withCountry returns a copy of user living in country, for updates that only need to change something
+func withCountry(user models.User, country string) models.User {
+	user.Country = country
+	return user
+}
+
+// mustParseFilter parses a filter that is known to be valid
+func mustParseFilter(t *testing.T, text string) filter.Expr {
+	t.Helper()
+
+	expr, err := filter.Parse(text)
+	require.NoError(t, err)
+	return expr
+}
+
+// nicknames extracts the nicknames of users in order
+func nicknames(users []models.User) []string {
+	result := make([]string, 0, len(users))
+	for _, user := range users {
+		result = append(result, user.Nickname)
+	}
+	return result
+}
+
+func testCreateUser(t *testing.T, storage repository.Users) {
+	input := newUser("alice")
+	input.ID = uuid.New()
+
+	before := time.Now().Add(-time.Second)
+	created, err := storage.CreateUser(input)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.NotEqual(t, input.ID, created.ID, "storage must assign its own ID")
+	assert.Equal(t, input.Nickname, created.Nickname)
+	assert.Equal(t, input.Email, created.Email)
+	assert.Equal(t, input.Password, created.Password)
+	assert.Equal(t, input.FirstName, created.FirstName)
+	assert.Equal(t, input.LastName, created.LastName)
+	assert.Equal(t, input.Country, created.Country)
+	assert.True(t, created.CreatedAt.After(before), "created_at must be set")
+	assert.True(t, created.UpdatedAt.After(before), "updated_at must be set")
+
+	stored, err := storage.GetUser(created.ID)
+	require.NoError(t, err)
+	assertSameUser(t, created, stored)
+}
+
+func testCreateUserConflicts(t *testing.T, storage repository.Users) {
+	existing := mustCreate(t, storage, newUser("existing"))[0]
+
+	tests := []struct {
+		name        string
+		input       models.User
+		expectedErr error
+	}{
+		{
+			name:        "Existing nickname",
+			input:       models.User{Nickname: existing.Nickname, Email: "other@example.com"},
+			expectedErr: apperrors.ErrNicknameTaken,
+		},
+		{
+			name:        "Existing email",
+			input:       models.User{Nickname: "other", Email: existing.Email},
+			expectedErr: apperrors.ErrEmailTaken,
+		},
+		{
+			name:        "Existing nickname and email reports nickname",
+			input:       models.User{Nickname: existing.Nickname, Email: existing.Email},
+			expectedErr: apperrors.ErrNicknameTaken,
+		},
+		{
+			name:        "Nickname differing only by case",
+			input:       models.User{Nickname: "EXISTING", Email: "upper@example.com"},
+			expectedErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := storage.CreateUser(tt.input)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func testGetUser(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"))
+
+	for _, user := range created {
+		stored, err := storage.GetUser(user.ID)
+		require.NoError(t, err)
+		assertSameUser(t, user, stored)
+	}
+
+	_, err := storage.GetUser(uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testGetUserByLogin(t *testing.T, storage repository.Users) {
+	alice := newUser("alice")
+	// A nickname that equals another user's email must resolve to the nickname owner
+	tricky := newUser("bob")
+	tricky.Nickname = alice.Email
+	created := mustCreate(t, storage, alice, tricky)
+
+	tests := []struct {
+		name        string
+		login       string
+		expected    models.User
+		expectedErr error
+	}{
+		{name: "By nickname", login: "alice", expected: created[0]},
+		{name: "By email", login: "bob@example.com", expected: created[1]},
+		{name: "Nickname wins over email", login: "alice@example.com", expected: created[1]},
+		{name: "Case sensitive", login: "ALICE", expectedErr: apperrors.ErrUserNotFound},
+		{name: "Unknown", login: "carol", expectedErr: apperrors.ErrUserNotFound},
+		{name: "Empty", login: "", expectedErr: apperrors.ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := storage.GetUserByLogin(tt.login)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assertSameUser(t, tt.expected, user)
+		})
+	}
+}
+
+func testGetUserByEmail(t *testing.T, storage repository.Users) {
+	alice := newUser("alice")
+	// Unlike a login, an email must never resolve to a user with that nickname
+	tricky := newUser("bob")
+	tricky.Nickname = alice.Email
+	created := mustCreate(t, storage, alice, tricky)
+
+	user, err := storage.GetUserByEmail(alice.Email)
+	require.NoError(t, err)
+	assertSameUser(t, created[0], user)
+
+	_, err = storage.GetUserByEmail("ALICE@example.com")
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	_, err = storage.GetUserByEmail("alice")
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testUpdateUser(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"))[0]
+
+	time.Sleep(time.Millisecond)
+	updated, err := storage.UpdateUser(models.User{
+		ID:       created.ID,
+		LastName: created.LastName,
+		Nickname: "alice2",
+		Email:    created.Email,
+		Country:  "Wonderland",
+		Password: "ignored",
+		Role:     models.RoleAdmin,
+	}, 0)
+	require.NoError(t, err)
+
+	// The profile is replaced as a whole, so empty fields are cleared
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, "alice2", updated.Nickname)
+	assert.Equal(t, "Wonderland", updated.Country)
+	assert.Equal(t, created.Email, updated.Email)
+	assert.Empty(t, updated.FirstName)
+	assert.Equal(t, created.LastName, updated.LastName)
+
+	// Password and role have dedicated methods and are never touched
+	assert.Equal(t, created.Password, updated.Password)
+	assert.Equal(t, created.Role, updated.Role)
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt), "created_at must not change")
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt), "updated_at must advance")
+
+	stored, err := storage.GetUser(created.ID)
+	require.NoError(t, err)
+	assertSameUser(t, updated, stored)
+
+	// The previous nickname is released and the new one is reserved
+	_, err = storage.CreateUser(models.User{Nickname: "alice", Email: "new-alice@example.com"})
+	assert.NoError(t, err)
+	_, err = storage.CreateUser(models.User{Nickname: "alice2", Email: "another@example.com"})
+	assert.ErrorIs(t, err, apperrors.ErrNicknameTaken)
+
+	// Filtering sees the updated values
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, "country=wonderland"), Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []string{"alice2"}, nicknames(users))
+
+	// Users may keep their own nickname and email
+	updated, err = storage.UpdateUser(withCountry(updated, "Elsewhere"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Elsewhere", updated.Country)
+
+	_, err = storage.UpdateUser(models.User{ID: uuid.New(), Nickname: "ghost"}, 0)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testUpdateUserConflicts(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"))
+	alice, bob := created[0], created[1]
+
+	changed := alice
+	changed.Nickname = bob.Nickname
+	_, err := storage.UpdateUser(changed, 0)
+	assert.ErrorIs(t, err, apperrors.ErrNicknameTaken)
+
+	changed = alice
+	changed.Email = bob.Email
+	_, err = storage.UpdateUser(changed, 0)
+	assert.ErrorIs(t, err, apperrors.ErrEmailTaken)
+
+	// A failed update leaves the user untouched
+	stored, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assertSameUser(t, alice, stored)
+}
+
+func testVersioning(t *testing.T, storage repository.Users) {
+	alice := mustCreate(t, storage, newUser("alice"))[0]
+	assert.Equal(t, int64(1), alice.Version, "new users start at version 1")
+
+	// Every kind of change bumps the version
+	updated, err := storage.UpdateUser(withCountry(alice, "Wonderland"), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+	updated, err = storage.SetUserRole(alice.ID, "support")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updated.Version)
+	updated, err = storage.MarkEmailVerified(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), updated.Version)
+	require.NoError(t, storage.UpdateUserPassword(alice.ID, "new-hash"))
+
+	stored, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stored.Version)
+
+	// A stale version is refused and leaves the user untouched
+	_, err = storage.UpdateUser(withCountry(alice, "Stale"), 4)
+	assert.ErrorIs(t, err, apperrors.ErrVersionMismatch)
+	assert.ErrorIs(t, storage.DeleteUser(alice.ID, 4), apperrors.ErrVersionMismatch)
+	unchanged, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assertSameUser(t, stored, unchanged)
+
+	// A missing user is reported as such whatever the version
+	_, err = storage.UpdateUser(models.User{ID: uuid.New(), Country: "Nowhere"}, 1)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	assert.ErrorIs(t, storage.DeleteUser(uuid.New(), 1), apperrors.ErrUserNotFound)
+
+	// Version 0 skips the check
+	updated, err = storage.UpdateUser(withCountry(alice, "Anywhere"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), updated.Version)
+
+	// Deleting and restoring are changes too
+	require.NoError(t, storage.DeleteUser(alice.ID, 6))
+	deleted, err := storage.GetUserIncludingDeleted(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), deleted.Version)
+	restored, err := storage.RestoreUser(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), restored.Version)
+}
+
+func testConcurrentVersionedUpdates(t *testing.T, storage repository.Users) {
+	const writers = 10
+	alice := mustCreate(t, storage, newUser("alice"))[0]
+
+	// Writers that all read version 1 race; exactly one of them may win
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var won, lost int
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := storage.UpdateUser(withCountry(alice, fmt.Sprintf("Country %d", i)), alice.Version)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, apperrors.ErrVersionMismatch):
+				lost++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, won)
+	assert.Equal(t, writers-1, lost)
+
+	stored, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+}
+
+func testSetUserRole(t *testing.T, storage repository.Users) {
+	admin := newUser("admin")
+	admin.Role = models.RoleAdmin
+	created := mustCreate(t, storage, newUser("alice"), admin)
+	assert.Equal(t, models.RoleAdmin, created[1].Role)
+
+	time.Sleep(time.Millisecond)
+	updated, err := storage.SetUserRole(created[0].ID, "support")
+	require.NoError(t, err)
+	assert.Equal(t, "support", updated.Role)
+	assert.Equal(t, created[0].Nickname, updated.Nickname)
+	assert.Equal(t, created[0].Password, updated.Password)
+	assert.True(t, updated.UpdatedAt.After(created[0].UpdatedAt), "updated_at must advance")
+
+	stored, err := storage.GetUser(created[0].ID)
+	require.NoError(t, err)
+	assertSameUser(t, updated, stored)
+
+	// Roles are filterable
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, "role=support"), Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []string{"alice"}, nicknames(users))
+
+	_, err = storage.SetUserRole(uuid.New(), models.RoleAdmin)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testEmailVerification(t *testing.T, storage repository.Users) {
+	verifiedAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	preverified := newUser("admin")
+	preverified.EmailVerified = true
+	preverified.EmailVerifiedAt = &verifiedAt
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"), preverified)
+	alice := created[0]
+
+	// New users start unverified unless created as verified
+	assert.False(t, alice.EmailVerified)
+	assert.Nil(t, alice.EmailVerifiedAt)
+	assert.True(t, created[2].EmailVerified)
+	require.NotNil(t, created[2].EmailVerifiedAt)
+	assert.True(t, verifiedAt.Equal(*created[2].EmailVerifiedAt))
+
+	time.Sleep(time.Millisecond)
+	verified, err := storage.MarkEmailVerified(alice.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+	require.NotNil(t, verified.EmailVerifiedAt)
+	assert.True(t, verified.UpdatedAt.After(alice.UpdatedAt), "updated_at must advance")
+
+	stored, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assertSameUser(t, verified, stored)
+
+	// Verified users are filterable
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, "email_verified=true"), Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []string{"alice", "admin"}, nicknames(users))
+	users, _, err = storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, "email_verified=false"), Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, nicknames(users))
+
+	// Other changes keep the verification, a new email drops it
+	updated, err := storage.UpdateUser(withCountry(alice, "Wonderland"), 0)
+	require.NoError(t, err)
+	assert.True(t, updated.EmailVerified)
+	updated.Email = "alice2@example.com"
+	updated, err = storage.UpdateUser(updated, 0)
+	require.NoError(t, err)
+	assert.False(t, updated.EmailVerified)
+	assert.Nil(t, updated.EmailVerifiedAt)
+
+	_, err = storage.MarkEmailVerified(uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testUpdateUserPassword(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"))
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, storage.UpdateUserPassword(created[0].ID, "new-hash"))
+
+	stored, err := storage.GetUser(created[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", stored.Password)
+	assert.Equal(t, created[0].Nickname, stored.Nickname)
+	assert.True(t, stored.UpdatedAt.After(created[0].UpdatedAt), "updated_at must advance")
+
+	// Other users keep their passwords
+	other, err := storage.GetUser(created[1].ID)
+	require.NoError(t, err)
+	assertSameUser(t, created[1], other)
+
+	assert.ErrorIs(t, storage.UpdateUserPassword(uuid.New(), "hash"), apperrors.ErrUserNotFound)
+}
+
+func testDeleteUser(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"), newUser("carol"), newUser("dave"))
+
+	time.Sleep(time.Millisecond)
+	before := time.Now()
+	require.NoError(t, storage.DeleteUser(created[2].ID, 0))
+
+	_, err := storage.GetUser(created[2].ID)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	assert.ErrorIs(t, storage.DeleteUser(created[2].ID, 0), apperrors.ErrUserNotFound)
+	assert.ErrorIs(t, storage.DeleteUser(uuid.New(), 0), apperrors.ErrUserNotFound)
+
+	// The deleted user is kept with the time of deletion
+	deleted, err := storage.GetUserIncludingDeleted(created[2].ID)
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.False(t, deleted.DeletedAt.Before(before), "deleted_at must be set")
+	assert.True(t, deleted.UpdatedAt.After(created[2].UpdatedAt), "updated_at must advance")
+	assert.Equal(t, created[2].Nickname, deleted.Nickname)
+
+	// Creating after a delete must not disturb the remaining users
+	erin := mustCreate(t, storage, newUser("erin"))[0]
+	for _, user := range []models.User{created[0], created[1], created[3], erin} {
+		stored, err := storage.GetUser(user.ID)
+		require.NoError(t, err)
+		assertSameUser(t, user, stored)
+	}
+
+	// The deleted user's nickname and email stay reserved so they can be restored
+	_, err = storage.CreateUser(models.User{Nickname: "carol", Email: "new-carol@example.com"})
+	assert.ErrorIs(t, err, apperrors.ErrNicknameTaken)
+	_, err = storage.CreateUser(models.User{Nickname: "new-carol", Email: "carol@example.com"})
+	assert.ErrorIs(t, err, apperrors.ErrEmailTaken)
+
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"alice", "bob", "dave", "erin"}, nicknames(users))
+
+	users, total, err = storage.GetFilteredUsers(models.UserQuery{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Equal(t, []string{"alice", "bob", "carol", "dave", "erin"}, nicknames(users))
+}
+
+func testDeletedUsersAreHidden(t *testing.T, storage repository.Users) {
+	alice := mustCreate(t, storage, newUser("alice"))[0]
+	require.NoError(t, storage.DeleteUser(alice.ID, 0))
+
+	_, err := storage.GetUserByLogin(alice.Nickname)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	_, err = storage.GetUserByLogin(alice.Email)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	_, err = storage.GetUserByEmail(alice.Email)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	// A deleted user cannot be modified until restored
+	_, err = storage.UpdateUser(withCountry(alice, "Wonderland"), 0)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	_, err = storage.SetUserRole(alice.ID, models.RoleAdmin)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	assert.ErrorIs(t, storage.UpdateUserPassword(alice.ID, "new-hash"), apperrors.ErrUserNotFound)
+	_, err = storage.MarkEmailVerified(alice.ID)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+
+	stored, err := storage.GetUserIncludingDeleted(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alice.Country, stored.Country)
+	assert.Equal(t, alice.Password, stored.Password)
+
+	_, err = storage.GetUserIncludingDeleted(uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testRestoreUser(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"))
+	alice := created[0]
+	require.NoError(t, storage.DeleteUser(alice.ID, 0))
+	deleted, err := storage.GetUserIncludingDeleted(alice.ID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+	restored, err := storage.RestoreUser(alice.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, alice.Nickname, restored.Nickname)
+	assert.Equal(t, alice.Password, restored.Password)
+	assert.True(t, restored.UpdatedAt.After(deleted.UpdatedAt), "updated_at must advance")
+
+	stored, err := storage.GetUser(alice.ID)
+	require.NoError(t, err)
+	assertSameUser(t, restored, stored)
+
+	// The restored user can log in again and keeps their place in listings
+	_, err = storage.GetUserByLogin(alice.Nickname)
+	assert.NoError(t, err)
+	users, _, err := storage.GetFilteredUsers(models.UserQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, nicknames(users))
+
+	_, err = storage.RestoreUser(alice.ID)
+	assert.ErrorIs(t, err, apperrors.ErrUserNotDeleted)
+	_, err = storage.RestoreUser(uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+}
+
+func testHardDeleteUser(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"), newUser("carol"))
+
+	// Both active and soft-deleted users can be removed for good
+	require.NoError(t, storage.DeleteUser(created[1].ID, 0))
+	require.NoError(t, storage.HardDeleteUser(created[0].ID))
+	require.NoError(t, storage.HardDeleteUser(created[1].ID))
+
+	for _, user := range created[:2] {
+		_, err := storage.GetUserIncludingDeleted(user.ID)
+		assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+		assert.ErrorIs(t, storage.HardDeleteUser(user.ID), apperrors.ErrUserNotFound)
+	}
+
+	stored, err := storage.GetUser(created[2].ID)
+	require.NoError(t, err)
+	assertSameUser(t, created[2], stored)
+
+	// The removed users' nicknames and emails become available again
+	recreated := mustCreate(t, storage, newUser("alice"), newUser("bob"))
+
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"carol", "alice", "bob"}, nicknames(users))
+	assert.NotEqual(t, created[0].ID, recreated[0].ID)
+}
+
+func testPurgeDeletedUsers(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"), newUser("carol"), newUser("dave"))
+
+	require.NoError(t, storage.DeleteUser(created[0].ID, 0))
+	require.NoError(t, storage.DeleteUser(created[1].ID, 0))
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, storage.DeleteUser(created[2].ID, 0))
+
+	// Only users deleted before the cutoff are purged
+	purged, err := storage.PurgeDeletedUsers(cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	for _, user := range created[:2] {
+		_, err := storage.GetUserIncludingDeleted(user.ID)
+		assert.ErrorIs(t, err, apperrors.ErrUserNotFound)
+	}
+	_, err = storage.GetUserIncludingDeleted(created[2].ID)
+	assert.NoError(t, err)
+	_, err = storage.GetUser(created[3].ID)
+	assert.NoError(t, err)
+
+	// Purging again finds nothing and the purged nicknames are free
+	purged, err = storage.PurgeDeletedUsers(cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	mustCreate(t, storage, newUser("alice"))
+
+	users, total, err := storage.GetFilteredUsers(models.UserQuery{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"carol", "dave", "alice"}, nicknames(users))
+}
+
+func testNicknameOrEmailExists(t *testing.T, storage repository.Users) {
+	alice := mustCreate(t, storage, newUser("alice"))[0]
+
+	tests := []struct {
+		name        string
+		nickname    string
+		email       string
+		expected    bool
+		expectedErr error
+	}{
+		{"Free nickname and email", "bob", "bob@example.com", false, nil},
+		{"Taken nickname", alice.Nickname, "bob@example.com", true, apperrors.ErrNicknameTaken},
+		{"Taken email", "bob", alice.Email, true, apperrors.ErrEmailTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := storage.NicknameOrEmailExists(tt.nickname, tt.email)
+			assert.Equal(t, tt.expected, exists)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func testGetFilteredUsers(t *testing.T, storage repository.Users) {
+	users := []models.User{
+		{Nickname: "alice", Email: "alice@example.com", FirstName: "Alice", LastName: "Smith", Country: "Wonderland"},
+		{Nickname: "bob", Email: "bob@example.com", FirstName: "Bob", LastName: "Johnson", Country: "USA"},
+		{Nickname: "carol", Email: "carol@example.org", FirstName: "Carol", LastName: "Williams", Country: "UK"},
+		{Nickname: "dmitry", Email: "dmitry_100%@example.org", FirstName: "Дмитрий", LastName: "Öztürk", Country: "Österreich"},
+	}
+	mustCreate(t, storage, users...)
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []string
+	}{
+		{"No filter", "", []string{"alice", "bob", "carol", "dmitry"}},
+		{"Empty value matches everything", `nickname=""`, []string{"alice", "bob", "carol", "dmitry"}},
+		{"Short form", "nickname=bob", []string{"bob"}},
+		{"Substring", "email contains example.org", []string{"carol", "dmitry"}},
+		{"Case-insensitive", "first_name:ALI", []string{"alice"}},
+		{"Last name", "last_name=son", []string{"bob"}},
+		{"Country", "country=u", []string{"bob", "carol"}},
+		{"Unicode case folding", "first_name=ДМИТ", []string{"dmitry"}},
+		{"Unicode case folding on accented letters", "country=ÖSTER", []string{"dmitry"}},
+		{"Percent matched literally", "email=%", []string{"dmitry"}},
+		{"Underscore matched literally", "email=_", []string{"dmitry"}},
+		{"No results", "nickname=nonexistent", nil},
+		{"Equal ignoring case", "country eq uk", []string{"carol"}},
+		{"Equal is not a substring match", "nickname eq bo", nil},
+		{"Not equal", "country neq usa", []string{"alice", "carol", "dmitry"}},
+		{"Prefix", "email prefix CA", []string{"carol"}},
+		{"Prefix with a wildcard", "email prefix dmitry_", []string{"dmitry"}},
+		{"In", "country in (uk, \"usa\", nowhere)", []string{"bob", "carol"}},
+		{"In with unicode case folding", "country in (ÖSTERREICH)", []string{"dmitry"}},
+		{"And", "email contains example.org AND country eq uk", []string{"carol"}},
+		{"Or", "nickname eq alice or nickname eq dmitry", []string{"alice", "dmitry"}},
+		{"And binds tighter than or", "nickname eq alice OR email contains .org AND country eq uk", []string{"alice", "carol"}},
+		{"Parentheses", "(nickname eq alice OR email contains .org) AND country neq uk", []string{"alice", "dmitry"}},
+		{"Quoted value with escapes", `last_name eq "O\"Brien" OR first_name eq "Alice"`, []string{"alice"}},
+		{"Yes/no field", "email_verified eq false AND nickname prefix b", []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, tt.filter), Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.expected), total)
+			assert.Equal(t, len(tt.expected), len(result))
+			if len(tt.expected) > 0 {
+				assert.Equal(t, tt.expected, nicknames(result))
+			}
+		})
+	}
+}
+
+func testFilterAfterChanges(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("alice"), newUser("bob"), newUser("carol"), newUser("dave"), newUser("erin"))
+
+	// Rename alice and move her abroad, remove bob for good and soft-delete carol
+	alice := created[0]
+	alice.Nickname = "zoe"
+	alice.Email = "zoe@example.net"
+	alice.FirstName = "Zoe"
+	alice.Country = "Norway"
+	_, err := storage.UpdateUser(alice, 0)
+	require.NoError(t, err)
+	require.NoError(t, storage.HardDeleteUser(created[1].ID))
+	require.NoError(t, storage.DeleteUser(created[2].ID, 0))
+
+	// A new user takes over the freed nickname
+	mustCreate(t, storage, newUser("alice"))
+
+	tests := []struct {
+		name           string
+		filter         string
+		includeDeleted bool
+		expected       []string
+	}{
+		{"Old nickname belongs to the new user", "nickname eq alice", false, []string{"alice"}},
+		{"New nickname", "nickname prefix zo", false, []string{"zoe"}},
+		{"New email", "email contains example.net", false, []string{"zoe"}},
+		{"Old first name is gone", `first_name contains "first alice"`, false, []string{"alice"}},
+		{"New country", "country eq norway", false, []string{"zoe"}},
+		{"Old country", "country eq country", false, []string{"dave", "erin", "alice"}},
+		{"Hard-deleted user is gone", "nickname in (bob, carol)", false, nil},
+		{"Soft-deleted user on request", "nickname in (bob, carol)", true, []string{"carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, tt.filter), Limit: 10, IncludeDeleted: tt.includeDeleted})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.expected), total)
+			if len(tt.expected) > 0 {
+				assert.Equal(t, tt.expected, nicknames(result))
+			}
+		})
+	}
+}
+
+func testFilterTimestamps(t *testing.T, storage repository.Users) {
+	mustCreate(t, storage, newUser("alice"), newUser("bob"))
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	created := mustCreate(t, storage, newUser("carol"), newUser("dmitry"))
+
+	// Updating moves updated_at but not created_at
+	time.Sleep(10 * time.Millisecond)
+	updateCutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	_, err := storage.UpdateUser(withCountry(created[0], "Wonderland"), 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []string
+	}{
+		{"Created after", "created_at gt " + cutoff.Format(time.RFC3339Nano), []string{"carol", "dmitry"}},
+		{"Created before", "created_at lt " + cutoff.Format(time.RFC3339Nano), []string{"alice", "bob"}},
+		{"Updated after", "updated_at gt " + updateCutoff.Format(time.RFC3339Nano), []string{"carol"}},
+		{"Date", "created_at gt 2000-01-01 AND created_at lt 9999-12-31", []string{"alice", "bob", "carol", "dmitry"}},
+		{"Long ago", "created_at lt 0001-01-01", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: mustParseFilter(t, tt.filter), Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.expected), total)
+			assert.Equal(t, tt.expected, nicknames(result))
+		})
+	}
+}
+
+func testSort(t *testing.T, storage repository.Users) {
+	// Distinct creation times make created_at order predictable
+	var created []models.User
+	for _, user := range []models.User{
+		withCountry(newUser("alice"), "DE"),
+		withCountry(newUser("bob"), "AT"),
+		withCountry(newUser("Carol"), "DE"),
+		withCountry(newUser("dave"), "AT"),
+	} {
+		created = append(created, mustCreate(t, storage, user)...)
+		time.Sleep(2 * time.Millisecond)
+	}
+	_, err := storage.MarkEmailVerified(created[1].ID)
+	require.NoError(t, err)
+
+	// Users in the same country are ordered by ID
+	byID := func(a, b models.User) []string {
+		if a.ID.String() > b.ID.String() {
+			a, b = b, a
+		}
+		return []string{a.Nickname, b.Nickname}
+	}
+	byCountry := append(byID(created[1], created[3]), byID(created[0], created[2])...)
+
+	tests := []struct {
+		name     string
+		query    models.UserQuery
+		expected []string
+	}{
+		{
+			name:     "Creation order without keys",
+			query:    models.UserQuery{Limit: 10},
+			expected: []string{"alice", "bob", "Carol", "dave"},
+		},
+		{
+			name:     "Newest first",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "created_at", Desc: true}}, Limit: 10},
+			expected: []string{"dave", "Carol", "bob", "alice"},
+		},
+		{
+			name:     "Text ignores case",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "nickname"}}, Limit: 10},
+			expected: []string{"alice", "bob", "Carol", "dave"},
+		},
+		{
+			name:     "Descending text",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "email", Desc: true}}, Limit: 10},
+			expected: []string{"dave", "Carol", "bob", "alice"},
+		},
+		{
+			name:     "Several keys",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "country"}, {Field: "created_at", Desc: true}}, Limit: 10},
+			expected: []string{"dave", "bob", "Carol", "alice"},
+		},
+		{
+			name:     "Verified first",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "email_verified", Desc: true}, {Field: "nickname"}}, Limit: 10},
+			expected: []string{"bob", "alice", "Carol", "dave"},
+		},
+		{
+			name:     "Ties broken by ID",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "country"}}, Limit: 10},
+			expected: byCountry,
+		},
+		{
+			name:     "Pages continue the order",
+			query:    models.UserQuery{Sort: []models.SortKey{{Field: "nickname"}}, Limit: 2, Offset: 2},
+			expected: []string{"Carol", "dave"},
+		},
+		{
+			name:     "Filtered",
+			query:    models.UserQuery{Filter: mustParseFilter(t, "country eq de"), Sort: []models.SortKey{{Field: "nickname", Desc: true}}, Limit: 10},
+			expected: []string{"Carol", "alice"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeating the query must not change the order
+			for i := 0; i < 3; i++ {
+				result, _, err := storage.GetFilteredUsers(tt.query)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, nicknames(result))
+			}
+		})
+	}
+}
+
+func testSeek(t *testing.T, storage repository.Users) {
+	for i, country := range []string{"DE", "AT", "DE", "CH", "AT", "DE", "AT"} {
+		mustCreate(t, storage, withCountry(newUser(fmt.Sprintf("user%d", i)), country))
+		time.Sleep(time.Millisecond)
+	}
+
+	// Without sort keys users are ordered by ID, and the zero ID comes before all of them
+	byID, total, err := storage.SeekFilteredUsers(models.UserQuery{Limit: 100}, models.UserSeek{})
+	require.NoError(t, err)
+	assert.Equal(t, 7, total)
+	require.Len(t, byID, 7)
+	assert.True(t, slices.IsSortedFunc(byID, func(a, b models.User) int { return strings.Compare(a.ID.String(), b.ID.String()) }))
+
+	tests := []struct {
+		name   string
+		filter string
+		sort   []models.SortKey
+	}{
+		{"Equal values", "", []models.SortKey{{Field: "country"}}},
+		{"Several keys", "", []models.SortKey{{Field: "country", Desc: true}, {Field: "created_at", Desc: true}}},
+		{"Filtered", "country neq CH", []models.SortKey{{Field: "nickname", Desc: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := models.UserQuery{Sort: tt.sort, Limit: 100}
+			if tt.filter != "" {
+				query.Filter = mustParseFilter(t, tt.filter)
+			}
+			all, expectedTotal, err := storage.GetFilteredUsers(query)
+			require.NoError(t, err)
+			require.Len(t, all, expectedTotal)
+
+			// Walking forward two users at a time visits every user once, in order
+			query.Limit = 2
+			page, total, err := storage.GetFilteredUsers(query)
+			require.NoError(t, err)
+			var pages [][]models.User
+			for len(page) > 0 {
+				assert.Equal(t, expectedTotal, total)
+				pages = append(pages, page)
+				page, total, err = storage.SeekFilteredUsers(query, models.UserSeek{Boundary: page[len(page)-1]})
+				require.NoError(t, err)
+			}
+			var walked []models.User
+			for _, page := range pages {
+				walked = append(walked, page...)
+			}
+			assert.Equal(t, nicknames(all), nicknames(walked))
+
+			// Walking backward from each page returns the page before it
+			for i := 1; i < len(pages); i++ {
+				page, _, err := storage.SeekFilteredUsers(query, models.UserSeek{Boundary: pages[i][0], Backward: true})
+				require.NoError(t, err)
+				assert.Equal(t, nicknames(pages[i-1]), nicknames(page))
+			}
+			page, _, err = storage.SeekFilteredUsers(query, models.UserSeek{Boundary: pages[0][0], Backward: true})
+			require.NoError(t, err)
+			assert.Empty(t, page)
+		})
+	}
+}
+
+func testSeekWhileChanging(t *testing.T, storage repository.Users) {
+	created := mustCreate(t, storage, newUser("bob"), newUser("dave"), newUser("frank"), newUser("harry"))
+	query := models.UserQuery{Sort: []models.SortKey{{Field: "nickname"}}, Limit: 2}
+
+	first, _, err := storage.GetFilteredUsers(query)
+	require.NoError(t, err)
+	require.Equal(t, []string{"bob", "dave"}, nicknames(first))
+
+	// Users created before the boundary and the boundary itself disappearing do not shift the next page
+	mustCreate(t, storage, newUser("alice"), newUser("erin"))
+	require.NoError(t, storage.DeleteUser(created[1].ID, 0))
+
+	next, total, err := storage.SeekFilteredUsers(query, models.UserSeek{Boundary: first[1]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"erin", "frank"}, nicknames(next))
+	assert.Equal(t, 5, total)
+
+	previous, _, err := storage.SeekFilteredUsers(query, models.UserSeek{Boundary: next[0], Backward: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, nicknames(previous))
+}
+
+func testPagination(t *testing.T, storage repository.Users) {
+	var names []string
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("user%d", i)
+		mustCreate(t, storage, newUser(name))
+		names = append(names, name)
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		offset   int
+		expected []string
+	}{
+		{"First page", 3, 0, names[0:3]},
+		{"Middle page", 3, 3, names[3:6]},
+		{"Last partial page", 3, 6, names[6:7]},
+		{"Offset at total", 3, 7, nil},
+		{"Offset beyond total", 3, 100, nil},
+		{"Limit beyond total", 100, 0, names},
+		{"Zero limit", 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, total, err := storage.GetFilteredUsers(models.UserQuery{Limit: tt.limit, Offset: tt.offset})
+			require.NoError(t, err)
+			assert.Equal(t, len(names), total, "total must not depend on the page")
+			assert.Equal(t, len(tt.expected), len(result))
+			if len(tt.expected) > 0 {
+				assert.Equal(t, tt.expected, nicknames(result))
+			}
+		})
+	}
+}
+
+func testConcurrentAccess(t *testing.T, storage repository.Users) {
+	const workers = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+
+	// Distinct users created concurrently while readers page through the storage
+	workerFilter := mustParseFilter(t, "nickname=worker")
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := storage.CreateUser(newUser(fmt.Sprintf("worker%d", i))); err != nil {
+				errs <- err
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, _, err := storage.GetFilteredUsers(models.UserQuery{Filter: workerFilter, Limit: 5}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	// Exactly one of several concurrent creates with the same nickname may win
+	var winners sync.Map
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user, err := storage.CreateUser(models.User{Nickname: "contended", Email: fmt.Sprintf("contended%d@example.com", i)})
+			switch {
+			case err == nil:
+				winners.Store(user.ID, struct{}{})
+			case !assert.ErrorIs(t, err, apperrors.ErrNicknameTaken):
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent operation failed: %v", err)
+	}
+
+	var winnerCount int
+	winners.Range(func(_, _ any) bool {
+		winnerCount++
+		return true
+	})
+	assert.Equal(t, 1, winnerCount)
+
+	_, total, err := storage.GetFilteredUsers(models.UserQuery{Filter: workerFilter, Limit: 100})
+	require.NoError(t, err)
+	assert.Equal(t, workers, total)
+}
@@ -0,0 +1,26 @@
+package sqlite_test
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/sosshik/users-service/internal/repository/repositorytest"
+	"github.com/sosshik/users-service/internal/repository/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+func TestUsersConformance(t *testing.T) {
+	dir := t.TempDir()
+	databases := 0
+
+	repositorytest.RunUsersConformance(t, func() repository.Users {
+		// Every run gets a fresh database file
+		databases++
+		storage, err := sqlite.NewSQLite(filepath.Join(dir, fmt.Sprintf("users-%d.db", databases)))
+		if err != nil {
+			t.Fatalf("Failed to open sqlite: %v", err)
+		}
+		t.Cleanup(func() { storage.Close() })
+		return storage
+	})
+}
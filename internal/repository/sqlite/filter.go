@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/filter"
+	"math"
+	"strings"
+	"time"
+)
+
+// filterColumns maps the filterable fields to their columns
+var filterColumns = map[string]string{
+	"nickname":       "nickname",
+	"email":          "email",
+	"first_name":     "first_name",
+	"last_name":      "last_name",
+	"country":        "country",
+	"role":           "role",
+	"email_verified": "email_verified",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}
+
+// comparisons maps the operators that compare values directly to SQL
+var comparisons = map[filter.Operator]string{
+	filter.Eq:  "=",
+	filter.Neq: "<>",
+	filter.Gt:  ">",
+	filter.Lt:  "<",
+}
+
+// whereFilter translates a parsed filter into a condition with ? placeholders, appending its values to args
+func whereFilter(expr filter.Expr, args []any) (string, []any, error) {
+	switch expr := expr.(type) {
+	case filter.And:
+		return joinFilters(expr, ` AND `, args)
+	case filter.Or:
+		return joinFilters(expr, ` OR `, args)
+	case *filter.Condition:
+		return whereCondition(expr, args)
+	}
+	return "", nil, fmt.Errorf("unsupported filter expression %T", expr)
+}
+
+// joinFilters translates every term and joins them with op
+func joinFilters(terms []filter.Expr, op string, args []any) (string, []any, error) {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part, termArgs, err := whereFilter(term, args)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+		args = termArgs
+	}
+	return `(` + strings.Join(parts, op) + `)`, args, nil
+}
+
+// filterUnixNano converts a filter timestamp to the Unix nanoseconds timestamps are stored as,
+// clamping dates outside the representable range of roughly 1678 to 2262
+func filterUnixNano(t time.Time) int64 {
+	switch {
+	case t.Before(time.Unix(0, math.MinInt64)):
+		return math.MinInt64
+	case t.After(time.Unix(0, math.MaxInt64)):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// whereCondition translates a single condition; text is compared with Go's case folding
+// to behave exactly like the in-memory storage
+func whereCondition(cond *filter.Condition, args []any) (string, []any, error) {
+	column, ok := filterColumns[cond.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported filter field %q", cond.Field)
+	}
+
+	switch cond.Kind {
+	case filter.Bool:
+		return column + ` ` + comparisons[cond.Op] + ` ?`, append(args, cond.Bool), nil
+	case filter.Time:
+		return column + ` ` + comparisons[cond.Op] + ` ?`, append(args, filterUnixNano(cond.Time)), nil
+	}
+
+	lowered := `unicode_lower(` + column + `)`
+	switch cond.Op {
+	case filter.Eq, filter.Neq:
+		return lowered + ` ` + comparisons[cond.Op] + ` ?`, append(args, strings.ToLower(cond.Text[0])), nil
+	case filter.Contains:
+		return `instr(` + lowered + `, ?) > 0`, append(args, strings.ToLower(cond.Text[0])), nil
+	case filter.Prefix:
+		// The first occurrence is at the start exactly when the column starts with the value
+		return `instr(` + lowered + `, ?) = 1`, append(args, strings.ToLower(cond.Text[0])), nil
+	case filter.In:
+		placeholders := make([]string, 0, len(cond.Text))
+		for _, text := range cond.Text {
+			placeholders = append(placeholders, `?`)
+			args = append(args, strings.ToLower(text))
+		}
+		return lowered + ` IN (` + strings.Join(placeholders, `, `) + `)`, args, nil
+	}
+	return "", nil, fmt.Errorf("unsupported filter operator %q for %s", cond.Op, cond.Field)
+}
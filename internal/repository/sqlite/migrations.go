@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations holds the schema changes in the order they must be applied.
+// Applied migrations are recorded in schema_migrations, so entries must never be edited or reordered.
+var migrations = []string{
+	`CREATE TABLE users (
+		id         TEXT PRIMARY KEY,
+		first_name TEXT NOT NULL DEFAULT '',
+		last_name  TEXT NOT NULL DEFAULT '',
+		nickname   TEXT NOT NULL,
+		password   TEXT NOT NULL DEFAULT '',
+		email      TEXT NOT NULL,
+		country    TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+	`CREATE UNIQUE INDEX users_nickname_idx ON users (nickname)`,
+	`CREATE UNIQUE INDEX users_email_idx ON users (email)`,
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`,
+	`ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN email_verified_at INTEGER`,
+	`ALTER TABLE users ADD COLUMN deleted_at INTEGER`,
+	`CREATE INDEX users_deleted_at_idx ON users (deleted_at)`,
+	`ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`CREATE INDEX users_created_at_id_idx ON users (created_at, id)`,
+}
+
+// migrate applies all pending migrations inside a single transaction
+func migrate(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL DEFAULT (unixepoch())
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
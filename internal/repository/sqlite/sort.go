@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/models"
+	"strings"
+)
+
+// sortColumns maps the sortable fields to the expressions they are ordered by; text is ordered ignoring case
+var sortColumns = map[string]string{
+	"nickname":       "unicode_lower(nickname)",
+	"email":          "unicode_lower(email)",
+	"first_name":     "unicode_lower(first_name)",
+	"last_name":      "unicode_lower(last_name)",
+	"country":        "unicode_lower(country)",
+	"role":           "unicode_lower(role)",
+	"email_verified": "email_verified",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}
+
+// orderBy translates sort keys into an ORDER BY clause that ends with the ID; without keys rows keep their insertion order
+func orderBy(keys []models.SortKey) (string, error) {
+	if len(keys) == 0 {
+		return ` ORDER BY rowid`, nil
+	}
+	return keyOrder(keys, false)
+}
+
+// keyOrder orders by the sort keys and then the ID; backward reverses every direction
+func keyOrder(keys []models.SortKey, backward bool) (string, error) {
+	terms := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		column, ok := sortColumns[key.Field]
+		if !ok {
+			return "", fmt.Errorf("unsupported sort field %q", key.Field)
+		}
+		if key.Desc != backward {
+			column += ` DESC`
+		}
+		terms = append(terms, column)
+	}
+	if backward {
+		terms = append(terms, `id DESC`)
+	} else {
+		terms = append(terms, `id`)
+	}
+
+	return ` ORDER BY ` + strings.Join(terms, `, `), nil
+}
+
+// whereSeek builds the condition selecting the rows after the boundary in sort order, or before it when going
+// backward: those greater in the first key, or equal in it and greater in the second key, and so on up to the ID
+func whereSeek(keys []models.SortKey, seek models.UserSeek, args []any) (string, []any, error) {
+	var alternatives []string
+	var equal []string
+	var equalArgs []any
+
+	for i := 0; i <= len(keys); i++ {
+		// The ID comes last and is always ascending
+		column, value, desc := `id`, any(seek.Boundary.ID), false
+		if i < len(keys) {
+			var ok bool
+			if column, ok = sortColumns[keys[i].Field]; !ok {
+				return "", nil, fmt.Errorf("unsupported sort field %q", keys[i].Field)
+			}
+			value, desc = sortValue(&seek.Boundary, keys[i].Field), keys[i].Desc
+		}
+
+		op := ` > ?`
+		if desc != seek.Backward {
+			op = ` < ?`
+		}
+		alternatives = append(alternatives, `(`+strings.Join(append(equal, column+op), ` AND `)+`)`)
+		args = append(append(args, equalArgs...), value)
+
+		equal = append(equal, column+` = ?`)
+		equalArgs = append(equalArgs, value)
+	}
+
+	return `(` + strings.Join(alternatives, ` OR `) + `)`, args, nil
+}
+
+// sortValue returns the value of a field of user the way its sort expression sees it
+func sortValue(user *models.User, field string) any {
+	switch field {
+	case "nickname":
+		return strings.ToLower(user.Nickname)
+	case "email":
+		return strings.ToLower(user.Email)
+	case "first_name":
+		return strings.ToLower(user.FirstName)
+	case "last_name":
+		return strings.ToLower(user.LastName)
+	case "country":
+		return strings.ToLower(user.Country)
+	case "role":
+		return strings.ToLower(user.Role)
+	case "email_verified":
+		return user.EmailVerified
+	case "created_at":
+		return user.CreatedAt.UnixNano()
+	case "updated_at":
+		return user.UpdatedAt.UnixNano()
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"slices"
+	"strings"
+	"time"
+)
+
+// userColumns lists the users table columns in the order expected by scanUser
+const userColumns = `id, first_name, last_name, nickname, password, email, country, role, email_verified,
+	email_verified_at, created_at, updated_at, deleted_at, version`
+
+func init() {
+	// SQLite's built-in lower() only folds ASCII, so filtering uses Go's case folding
+	// to behave exactly like the in-memory storage
+	sqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			value, _ := args[0].(string)
+			return strings.ToLower(value), nil
+		})
+}
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// NewSQLite opens the database file at path, creating it and its schema if needed
+func NewSQLite(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer at a time; one shared connection avoids SQLITE_BUSY
+	// errors and keeps transactions from interleaving
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{`PRAGMA journal_mode = WAL`, `PRAGMA synchronous = NORMAL`, `PRAGMA busy_timeout = 5000`} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to configure sqlite: %w", err)
+		}
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to migrate sqlite schema: %w", err)
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+// CreateUser inserts a new user into the users table
+func (s *SQLiteStorage) CreateUser(user models.User) (models.User, error) {
+	// Check if a user with the given nickname or email already exists
+	exists, err := nicknameOrEmailExists(s.db, user.Nickname, user.Email, uuid.Nil)
+	if err != nil || exists {
+		return models.User{}, err
+	}
+
+	// Assign a new UUID, set timestamps and start versioning
+	now := time.Now()
+	user.ID = uuid.New()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+
+	row := s.db.QueryRow(`INSERT INTO users (`+userColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+userColumns,
+		user.ID, user.FirstName, user.LastName, user.Nickname, user.Password, user.Email, user.Country, user.Role,
+		user.EmailVerified, unixNanoOrNil(user.EmailVerifiedAt), user.CreatedAt.UnixNano(), user.UpdatedAt.UnixNano(),
+		unixNanoOrNil(user.DeletedAt), user.Version)
+
+	user, err = scanUser(row)
+	if err != nil {
+		return models.User{}, mapError(err)
+	}
+
+	return user, nil
+}
+
+// NicknameOrEmailExists checks if a user with the given nickname or email already exists
+func (s *SQLiteStorage) NicknameOrEmailExists(nickname, email string) (bool, error) {
+	return nicknameOrEmailExists(s.db, nickname, email, uuid.Nil)
+}
+
+// nicknameOrEmailExists is a helper function that checks existence of a user by nickname or email
+func nicknameOrEmailExists(q querier, nickname, email string, excludeID uuid.UUID) (bool, error) {
+	var nicknameExists, emailExists bool
+	err := q.QueryRow(`SELECT
+		EXISTS (SELECT 1 FROM users WHERE nickname = ?1 AND id <> ?3),
+		EXISTS (SELECT 1 FROM users WHERE email = ?2 AND id <> ?3)`,
+		nickname, email, excludeID).Scan(&nicknameExists, &emailExists)
+	if err != nil {
+		return false, err
+	}
+
+	if nicknameExists {
+		return true, apperrors.ErrNicknameTaken
+	}
+
+	if emailExists {
+		return true, apperrors.ErrEmailTaken
+	}
+
+	return false, nil
+}
+
+// GetUser retrieves a user by their ID, ignoring soft-deleted users
+func (s *SQLiteStorage) GetUser(id uuid.UUID) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserIncludingDeleted retrieves a user by their ID even if they are soft-deleted
+func (s *SQLiteStorage) GetUserIncludingDeleted(id uuid.UUID) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserByLogin retrieves a user whose nickname or email equals login, preferring a nickname match
+func (s *SQLiteStorage) GetUserByLogin(login string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users
+		WHERE (nickname = ?1 OR email = ?1) AND deleted_at IS NULL
+		ORDER BY nickname = ?1 DESC
+		LIMIT 1`, login))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// GetUserByEmail retrieves a user by their exact email
+func (s *SQLiteStorage) GetUserByEmail(email string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ? AND deleted_at IS NULL`, email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// UpdateUser replaces an existing user's profile; the first and last name, nickname, email and country
+// are written exactly as given, so empty values clear them
+func (s *SQLiteStorage) UpdateUser(user models.User, expectedVersion int64) (models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	// Check if user exists, is not deleted and has not changed since the caller read it
+	if err := checkVersion(tx, user.ID, expectedVersion); err != nil {
+		return models.User{}, err
+	}
+
+	// Validate that new nickname/email does not belong to another user
+	if exists, err := nicknameOrEmailExists(tx, user.Nickname, user.Email, user.ID); err != nil || exists {
+		return models.User{}, err
+	}
+
+	row := tx.QueryRow(`UPDATE users SET
+			first_name = ?2,
+			last_name  = ?3,
+			nickname   = ?4,
+			email      = ?5,
+			country    = ?6,
+			updated_at = ?7,
+			version    = version + 1,
+			-- A new email has to be verified again
+			email_verified    = CASE WHEN ?5 = email THEN email_verified ELSE 0 END,
+			email_verified_at = CASE WHEN ?5 = email THEN email_verified_at ELSE NULL END
+		WHERE id = ?1
+		RETURNING `+userColumns,
+		user.ID, user.FirstName, user.LastName, user.Nickname, user.Email, user.Country, time.Now().UnixNano())
+
+	updated, err := scanUser(row)
+	if err != nil {
+		return models.User{}, mapError(err)
+	}
+
+	return updated, tx.Commit()
+}
+
+// SetUserRole assigns a new role to the user
+func (s *SQLiteStorage) SetUserRole(id uuid.UUID, role string) (models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`UPDATE users SET role = ?2, updated_at = ?3, version = version + 1
+		WHERE id = ?1 AND deleted_at IS NULL
+		RETURNING `+userColumns, id, role, time.Now().UnixNano()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// MarkEmailVerified records that the user controls their current email
+func (s *SQLiteStorage) MarkEmailVerified(id uuid.UUID) (models.User, error) {
+	now := time.Now().UnixNano()
+	user, err := scanUser(s.db.QueryRow(`UPDATE users SET email_verified = 1, email_verified_at = ?2, updated_at = ?2,
+			version = version + 1
+		WHERE id = ?1 AND deleted_at IS NULL
+		RETURNING `+userColumns, id, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+
+	return user, err
+}
+
+// UpdateUserPassword replaces the user's password hash
+func (s *SQLiteStorage) UpdateUserPassword(id uuid.UUID, hash string) error {
+	res, err := s.db.Exec(`UPDATE users SET password = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND deleted_at IS NULL`, hash, time.Now().UnixNano(), id)
+	return requireAffected(res, err)
+}
+
+// DeleteUser soft-deletes a user by their ID; the user keeps their nickname and email until purged
+func (s *SQLiteStorage) DeleteUser(id uuid.UUID, expectedVersion int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, id, expectedVersion); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET deleted_at = ?2, updated_at = ?2, version = version + 1 WHERE id = ?1`,
+		id, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkVersion fails with ErrUserNotFound unless the user exists and is not deleted, and with
+// ErrVersionMismatch unless expectedVersion is 0 or the stored version
+func checkVersion(q querier, id uuid.UUID, expectedVersion int64) error {
+	var version int64
+	err := q.QueryRow(`SELECT version FROM users WHERE id = ? AND deleted_at IS NULL`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if expectedVersion != 0 && version != expectedVersion {
+		return apperrors.ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// RestoreUser undoes a soft delete
+func (s *SQLiteStorage) RestoreUser(id uuid.UUID) (models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	// Tell a missing user apart from one that is not deleted
+	var deletedAt sql.NullInt64
+	err = tx.QueryRow(`SELECT deleted_at FROM users WHERE id = ?`, id).Scan(&deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, apperrors.ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if !deletedAt.Valid {
+		return models.User{}, apperrors.ErrUserNotDeleted
+	}
+
+	user, err := scanUser(tx.QueryRow(`UPDATE users SET deleted_at = NULL, updated_at = ?2, version = version + 1
+		WHERE id = ?1
+		RETURNING `+userColumns, id, time.Now().UnixNano()))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+// HardDeleteUser permanently removes a user, deleted or not, and frees their nickname and email
+func (s *SQLiteStorage) HardDeleteUser(id uuid.UUID) error {
+	res, err := s.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	return requireAffected(res, err)
+}
+
+// PurgeDeletedUsers permanently removes the users soft-deleted before deletedBefore and returns how many were removed
+func (s *SQLiteStorage) PurgeDeletedUsers(deletedBefore time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM users WHERE deleted_at < ?`, deletedBefore.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// requireAffected reports ErrUserNotFound when a statement changed no users
+func requireAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperrors.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetFilteredUsers retrieves users based on a filter, sort order and pagination parameters
+func (s *SQLiteStorage) GetFilteredUsers(query models.UserQuery) ([]models.User, int, error) {
+	conditions, args, err := queryConditions(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, err := orderBy(query.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return s.selectPage(conditions, args, "", nil, order, query.Limit, query.Offset)
+}
+
+// SeekFilteredUsers retrieves the users following or preceding a boundary user in sort order
+func (s *SQLiteStorage) SeekFilteredUsers(query models.UserQuery, seek models.UserSeek) ([]models.User, int, error) {
+	conditions, args, err := queryConditions(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only the rows beyond the boundary are read, so deep pages cost no more than the first
+	seekCondition, seekArgs, err := whereSeek(query.Sort, seek, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, err := keyOrder(query.Sort, seek.Backward)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users, total, err := s.selectPage(conditions, args, seekCondition, seekArgs, order, query.Limit, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Going backward the nearest rows come first
+	if seek.Backward {
+		slices.Reverse(users)
+	}
+
+	return users, total, nil
+}
+
+// queryConditions translates the filter of query and the visibility of deleted users into conditions
+func queryConditions(query models.UserQuery) ([]string, []any, error) {
+	var conditions []string
+	var args []any
+
+	// Soft-deleted users are hidden unless requested
+	if !query.IncludeDeleted {
+		conditions = append(conditions, `deleted_at IS NULL`)
+	}
+
+	// Translate the filter into a parameterized condition
+	if query.Filter != nil {
+		condition, filterArgs, err := whereFilter(query.Filter, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, condition)
+		args = filterArgs
+	}
+
+	return conditions, args, nil
+}
+
+// selectPage counts the users matching conditions and selects a page of those also matching the seek condition, if any
+func (s *SQLiteStorage) selectPage(conditions []string, args []any, seekCondition string, seekArgs []any,
+	order string, limit, offset int) ([]models.User, int, error) {
+	pageConditions, pageArgs := conditions, args
+	if seekCondition != "" {
+		pageConditions = append(slices.Clip(conditions), seekCondition)
+		pageArgs = append(slices.Clip(args), seekArgs...)
+	}
+
+	// Count and page within one transaction so the total matches the returned page
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users`+whereClause(conditions), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := tx.Query(`SELECT `+userColumns+` FROM users`+whereClause(pageConditions)+order+` LIMIT ? OFFSET ?`,
+		append(pageArgs, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var result []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return result, total, tx.Commit()
+}
+
+// whereClause joins conditions into a WHERE clause; no conditions select every row
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+// Ping verifies that the database can still be reached
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the underlying database connections
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// scanUser reads a single users row selected with userColumns
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var createdAt, updatedAt int64
+	var verifiedAt, deletedAt sql.NullInt64
+	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Nickname, &user.Password,
+		&user.Email, &user.Country, &user.Role, &user.EmailVerified, &verifiedAt, &createdAt, &updatedAt, &deletedAt,
+		&user.Version)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user.CreatedAt = time.Unix(0, createdAt)
+	user.UpdatedAt = time.Unix(0, updatedAt)
+	if verifiedAt.Valid {
+		t := time.Unix(0, verifiedAt.Int64)
+		user.EmailVerifiedAt = &t
+	}
+	if deletedAt.Valid {
+		t := time.Unix(0, deletedAt.Int64)
+		user.DeletedAt = &t
+	}
+
+	return user, nil
+}
+
+// unixNanoOrNil converts an optional time to the stored representation, keeping nil as NULL
+func unixNanoOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+// mapError translates unique index violations into domain errors
+func mapError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		switch {
+		case strings.Contains(sqliteErr.Error(), "users.nickname"):
+			return apperrors.ErrNicknameTaken
+		case strings.Contains(sqliteErr.Error(), "users.email"):
+			return apperrors.ErrEmailTaken
+		}
+	}
+	return err
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/password"
+	"github.com/sosshik/users-service/internal/repository"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"time"
+)
+
+// tokenTypeBearer is the OAuth 2.0 token type of issued access tokens
+const tokenTypeBearer = "Bearer"
+
+type AuthService struct {
+	users      repository.Users
+	sessions   repository.RefreshTokens
+	oneTime    repository.OneTimeTokens
+	attempts   repository.LoginAttempts
+	tokens     *auth.TokenManager
+	policy     *password.Policy
+	hasher     password.Hasher
+	notifier   notify.Notifier
+	audit      audit.Logger
+	throttle   config.LoginThrottleConfig
+	refreshTTL time.Duration
+	resetTTL   time.Duration
+	resetURL   string
+	// requireVerifiedEmail refuses logins of users who have not verified their email
+	requireVerifiedEmail bool
+	// dummyHash is compared against when the login is unknown, so response times
+	// do not reveal which nicknames and emails are registered
+	dummyHash string
+}
+
+// NewAuthService creates a new instance of AuthService with the given repositories, token manager,
+// password policy, password hasher, notifier and audit logger
+func NewAuthService(users repository.Users, sessions repository.RefreshTokens, oneTime repository.OneTimeTokens,
+	attempts repository.LoginAttempts, tokens *auth.TokenManager, policy *password.Policy, hasher password.Hasher,
+	notifier notify.Notifier, auditor audit.Logger, cfg config.Config) *AuthService {
+	dummyHash, _ := hasher.Hash("dummy-password")
+	return &AuthService{
+		users:                users,
+		sessions:             sessions,
+		oneTime:              oneTime,
+		attempts:             attempts,
+		tokens:               tokens,
+		policy:               policy,
+		hasher:               hasher,
+		notifier:             notifier,
+		audit:                auditor,
+		throttle:             cfg.LoginThrottle,
+		refreshTTL:           cfg.Auth.RefreshTokenTTL,
+		resetTTL:             cfg.Auth.PasswordResetTTL,
+		resetURL:             cfg.Auth.PasswordResetURL,
+		requireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+		dummyHash:            dummyHash,
+	}
+}
+
+// Login verifies the user's credentials and starts a new session. Repeated failures for the same
+// account or from the same client address delay further attempts and eventually lock them out.
+func (a *AuthService) Login(req dtos.LoginRequest, clientIP string) (dtos.LoginResponse, error) {
+	// Look the user up by nickname or email
+	user, err := a.users.GetUserByLogin(req.Login)
+	found := err == nil
+	if err != nil && !errors.Is(err, apperrors.ErrUserNotFound) {
+		return dtos.LoginResponse{}, err
+	}
+
+	// Count the attempt up front, refusing without checking the password while delayed or locked out
+	keys := a.loginThrottleKeys(user.ID, req.Login, clientIP)
+	counted, err := a.beginLoginAttempt(keys)
+	if err != nil {
+		return dtos.LoginResponse{}, err
+	}
+
+	// Verify the password against the stored hash; unknown logins cost the same time
+	if !found {
+		_, _ = a.hasher.Verify(a.dummyHash, req.Password)
+		a.recordLoginFailure(keys, counted, clientIP)
+		return dtos.LoginResponse{}, apperrors.ErrInvalidCredentials
+	}
+	if !a.verifyPassword(user, req.Password) {
+		a.recordLoginFailure(keys, counted, clientIP)
+		return dtos.LoginResponse{}, apperrors.ErrInvalidCredentials
+	}
+
+	// The right password takes the attempt back and clears the account's failures, but not the earlier ones
+	// of the client address
+	a.refundLoginAttempt(counted)
+	if err := a.attempts.ResetLoginAttempts(accountThrottleKey(user.ID)); err != nil {
+		log.Errorf("[Login] Unable to reset failed logins of user %s: %s", user.ID, err)
+	}
+
+	// The plain password is only known now, so this is the one chance to upgrade an outdated hash
+	if a.hasher.NeedsRehash(user.Password) {
+		a.rehashPassword(user, req.Password)
+	}
+
+	// Checked only after the password, so the answer does not reveal anything about other accounts
+	if a.requireVerifiedEmail && !user.EmailVerified {
+		return dtos.LoginResponse{}, apperrors.ErrEmailNotVerified
+	}
+
+	// Every login starts a new token family
+	return a.issueTokens(user, uuid.New())
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh token can be used once;
+// presenting a used token again means it leaked, so the whole session is revoked.
+func (a *AuthService) Refresh(req dtos.RefreshTokenRequest) (dtos.LoginResponse, error) {
+	// Mark the token as used, getting back its previous state
+	token, err := a.sessions.ConsumeRefreshToken(auth.HashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		return dtos.LoginResponse{}, err
+	}
+
+	// Reject tokens that can no longer be exchanged
+	switch {
+	case !token.RevokedAt.IsZero():
+		return dtos.LoginResponse{}, fmt.Errorf("%w: session was revoked", apperrors.ErrInvalidRefreshToken)
+	case !token.UsedAt.IsZero():
+		log.Warnf("[Refresh] Refresh token reuse detected for user %s, revoking session %s", token.UserID, token.FamilyID)
+		if err := a.sessions.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+			return dtos.LoginResponse{}, err
+		}
+		return dtos.LoginResponse{}, fmt.Errorf("%w: token was already used", apperrors.ErrInvalidRefreshToken)
+	case time.Now().After(token.ExpiresAt):
+		return dtos.LoginResponse{}, fmt.Errorf("%w: token expired", apperrors.ErrInvalidRefreshToken)
+	}
+
+	// Sessions of deleted users end with them; the new access token carries the current role
+	user, err := a.users.GetUser(token.UserID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrUserNotFound) {
+			return dtos.LoginResponse{}, fmt.Errorf("%w: user no longer exists", apperrors.ErrInvalidRefreshToken)
+		}
+		return dtos.LoginResponse{}, err
+	}
+
+	// Rotate within the same family
+	return a.issueTokens(user, token.FamilyID)
+}
+
+// Logout ends the session the refresh token belongs to; unknown tokens are ignored
+func (a *AuthService) Logout(req dtos.RefreshTokenRequest) error {
+	token, err := a.sessions.ConsumeRefreshToken(auth.HashOpaqueToken(req.RefreshToken))
+	if errors.Is(err, apperrors.ErrInvalidRefreshToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.sessions.RevokeRefreshTokenFamily(token.FamilyID)
+}
+
+// LogoutAll ends every session of the user. Access tokens that were already issued
+// stay valid until they expire.
+func (a *AuthService) LogoutAll(userIDStr string) error {
+	// Parse user ID from string
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	return a.sessions.RevokeUserRefreshTokens(userID)
+}
+
+// ChangePassword replaces the user's password after verifying the current one, then ends
+// every session so a stolen refresh token stops working
+func (a *AuthService) ChangePassword(idStr string, req dtos.ChangePasswordRequest) error {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	user, err := a.users.GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	// Verify the current password
+	if !a.verifyPassword(user, req.CurrentPassword) {
+		return apperrors.ErrIncorrectPassword
+	}
+
+	// Enforce the password policy on the new password
+	if req.NewPassword == req.CurrentPassword {
+		return fmt.Errorf("%w: new password must differ from the current one", apperrors.ErrWeakPassword)
+	}
+	if err := a.policy.Check(req.NewPassword, user.Nickname, user.Email); err != nil {
+		return err
+	}
+
+	hash, err := a.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := a.users.UpdateUserPassword(id, hash); err != nil {
+		return err
+	}
+
+	return a.sessions.RevokeUserRefreshTokens(id)
+}
+
+// UnlockUser lifts the lockout of the user's account and forgets its failed logins
+func (a *AuthService) UnlockUser(idStr, actorID string) error {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	if _, err := a.users.GetUser(id); err != nil {
+		return err
+	}
+
+	if err := a.attempts.ResetLoginAttempts(accountThrottleKey(id)); err != nil {
+		return err
+	}
+
+	a.audit.Record(audit.Event{
+		Type:    audit.EventLoginUnlocked,
+		Subject: accountThrottleKey(id),
+		Actor:   actorID,
+		Detail:  "failed logins cleared by an administrator",
+	})
+	return nil
+}
+
+// RequestPasswordReset sends a single-use reset link to the owner of the email. Unknown emails
+// are not an error, so callers cannot learn which emails are registered.
+func (a *AuthService) RequestPasswordReset(req dtos.PasswordResetRequest) error {
+	user, err := a.users.GetUserByEmail(req.Email)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		log.Infof("[RequestPasswordReset] Ignoring reset request for an unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := issueOneTimeToken(a.oneTime, user.ID, models.TokenPurposePasswordReset, a.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := tokenLink(a.resetURL, token)
+	return a.notifier.Notify(context.Background(), notify.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and works once.\n\n%s\n\n"+
+			"If you did not ask for a password reset, you can ignore this message.", user.Nickname, a.resetTTL, link),
+	})
+}
+
+// ConfirmPasswordReset sets a new password using a reset token, then ends every session of the user
+func (a *AuthService) ConfirmPasswordReset(req dtos.PasswordResetConfirmRequest) error {
+	hash := auth.HashOpaqueToken(req.Token)
+
+	// Check the new password before using the token up, so a rejected password can be retried
+	token, err := a.oneTime.GetOneTimeToken(models.TokenPurposePasswordReset, hash)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.users.GetUser(token.UserID)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		return fmt.Errorf("%w: user no longer exists", apperrors.ErrInvalidToken)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := a.policy.Check(req.NewPassword, user.Nickname, user.Email); err != nil {
+		return err
+	}
+
+	newHash, err := a.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	// Consuming is atomic, so of two concurrent confirmations only one gets past this point
+	if _, err := a.oneTime.ConsumeOneTimeToken(models.TokenPurposePasswordReset, hash); err != nil {
+		return err
+	}
+
+	if err := a.users.UpdateUserPassword(user.ID, newHash); err != nil {
+		return err
+	}
+
+	// Other pending links and every session end with the old password
+	if err := a.oneTime.DeleteUserOneTimeTokens(user.ID, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	return a.sessions.RevokeUserRefreshTokens(user.ID)
+}
+
+// issueTokens creates an access token and a refresh token belonging to the given family
+func (a *AuthService) issueTokens(user models.User, familyID uuid.UUID) (dtos.LoginResponse, error) {
+	accessToken, _, err := a.tokens.Issue(user.ID, user.Role)
+	if err != nil {
+		return dtos.LoginResponse{}, err
+	}
+
+	refreshToken, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return dtos.LoginResponse{}, err
+	}
+
+	now := time.Now()
+	err = a.sessions.CreateRefreshToken(models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.refreshTTL),
+	})
+	if err != nil {
+		return dtos.LoginResponse{}, err
+	}
+
+	return dtos.LoginResponse{
+		AccessToken:  accessToken,
+		TokenType:    tokenTypeBearer,
+		ExpiresIn:    int(a.tokens.TTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// verifyPassword reports whether password matches the user's stored hash. A hash that cannot be
+// read is logged and treated as a mismatch.
+func (a *AuthService) verifyPassword(user models.User, password string) bool {
+	ok, err := a.hasher.Verify(user.Password, password)
+	if err != nil {
+		log.Errorf("[verifyPassword] Unable to verify password hash of user %s: %s", user.ID, err)
+		return false
+	}
+	return ok
+}
+
+// rehashPassword replaces the user's hash with one using the current algorithm and settings.
+// Failing is harmless as the old hash keeps working, so it is only logged.
+func (a *AuthService) rehashPassword(user models.User, password string) {
+	hash, err := a.hasher.Hash(password)
+	if err != nil {
+		log.Warnf("[rehashPassword] Unable to rehash password of user %s: %s", user.ID, err)
+		return
+	}
+
+	if err := a.users.UpdateUserPassword(user.ID, hash); err != nil {
+		log.Warnf("[rehashPassword] Unable to store rehashed password of user %s: %s", user.ID, err)
+		return
+	}
+	log.Infof("[rehashPassword] Upgraded password hash of user %s", user.ID)
+}
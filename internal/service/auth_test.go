@@ -0,0 +1,607 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/password"
+	"github.com/sosshik/users-service/internal/repository/inmemory"
+	mocks "github.com/sosshik/users-service/internal/repository/mock"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAuthService creates an AuthService backed by mocks and a fast bcrypt cost
+func newTestAuthService(t *testing.T) (*AuthService, *mocks.MockUserRepository, *mocks.MockRefreshTokenRepository, *auth.TokenManager, config.Config) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	tokens, err := auth.NewTokenManager(cfg.Auth)
+	require.NoError(t, err)
+
+	usersRepo := new(mocks.MockUserRepository)
+	sessionsRepo := new(mocks.MockRefreshTokenRepository)
+	oneTimeRepo := new(mocks.MockOneTimeTokenRepository)
+	authService := NewAuthService(usersRepo, sessionsRepo, oneTimeRepo, inmemory.NewLoginAttemptStorage(), tokens,
+		newTestPolicy(t, cfg), newTestHasher(t, cfg), &recordingNotifier{}, &recordingAuditor{}, cfg)
+	return authService, usersRepo, sessionsRepo, tokens, cfg
+}
+
+// newTestResetService creates an AuthService for the password reset flow, returning the mocks it uses
+func newTestResetService(t *testing.T) (*AuthService, *mocks.MockUserRepository, *mocks.MockRefreshTokenRepository,
+	*mocks.MockOneTimeTokenRepository, *recordingNotifier) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	cfg.Auth.PasswordResetURL = "https://example.com/reset?token={token}"
+	tokens, err := auth.NewTokenManager(cfg.Auth)
+	require.NoError(t, err)
+
+	usersRepo := new(mocks.MockUserRepository)
+	sessionsRepo := new(mocks.MockRefreshTokenRepository)
+	oneTimeRepo := new(mocks.MockOneTimeTokenRepository)
+	notifier := &recordingNotifier{}
+	authService := NewAuthService(usersRepo, sessionsRepo, oneTimeRepo, inmemory.NewLoginAttemptStorage(), tokens,
+		newTestPolicy(t, cfg), newTestHasher(t, cfg), notifier, &recordingAuditor{}, cfg)
+	return authService, usersRepo, sessionsRepo, oneTimeRepo, notifier
+}
+
+// testClientIP is the client address logins in tests come from
+const testClientIP = "192.0.2.1"
+
+// recordingAuditor keeps the audit events it is asked to record
+type recordingAuditor struct {
+	events []audit.Event
+}
+
+func (a *recordingAuditor) Record(event audit.Event) {
+	a.events = append(a.events, event)
+}
+
+// recordingNotifier keeps the messages it is asked to deliver
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// newTestPolicy creates the password policy described by cfg
+func newTestPolicy(t *testing.T, cfg config.Config) *password.Policy {
+	t.Helper()
+
+	policy, err := password.NewPolicy(cfg.Password)
+	require.NoError(t, err)
+	return policy
+}
+
+// newTestHasher creates the password hasher described by cfg
+func newTestHasher(t *testing.T, cfg config.Config) password.Hasher {
+	t.Helper()
+
+	hasher, err := password.NewHasher(cfg.Password)
+	require.NoError(t, err)
+	return hasher
+}
+
+func TestLogin(t *testing.T) {
+	authService, mockRepo, sessionsRepo, tokens, cfg := newTestAuthService(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: string(hash), Role: models.RoleAdmin}
+
+	testCases := []struct {
+		name        string
+		req         dtos.LoginRequest
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name: "Success",
+			req:  dtos.LoginRequest{Login: "alice", Password: "password123"},
+			setupMock: func() {
+				mockRepo.On("GetUserByLogin", "alice").Return(user, nil).Once()
+				sessionsRepo.On("CreateRefreshToken", mock.MatchedBy(func(token models.RefreshToken) bool {
+					return token.UserID == user.ID && token.FamilyID != uuid.Nil &&
+						token.ExpiresAt.After(time.Now().Add(cfg.Auth.RefreshTokenTTL-time.Minute))
+				})).Return(nil).Once()
+			},
+		},
+		{
+			name:        "Wrong password",
+			req:         dtos.LoginRequest{Login: "alice@example.com", Password: "password124"},
+			expectedErr: apperrors.ErrInvalidCredentials,
+			setupMock: func() {
+				mockRepo.On("GetUserByLogin", "alice@example.com").Return(user, nil).Once()
+			},
+		},
+		{
+			name:        "Unknown user",
+			req:         dtos.LoginRequest{Login: "bob", Password: "password123"},
+			expectedErr: apperrors.ErrInvalidCredentials,
+			setupMock: func() {
+				mockRepo.On("GetUserByLogin", "bob").Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+		{
+			name:        "Repository error",
+			req:         dtos.LoginRequest{Login: "carol", Password: "password123"},
+			expectedErr: errors.New("repository error"),
+			setupMock: func() {
+				mockRepo.On("GetUserByLogin", "carol").Return(models.User{}, errors.New("repository error")).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			resp, err := authService.Login(tc.req, testClientIP)
+
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, tc.expectedErr.Error())
+				assert.Empty(t, resp.AccessToken)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "Bearer", resp.TokenType)
+				assert.Equal(t, int(cfg.Auth.AccessTokenTTL.Seconds()), resp.ExpiresIn)
+
+				claims, err := tokens.Parse(resp.AccessToken)
+				require.NoError(t, err)
+				assert.Equal(t, user.ID, claims.UserID)
+				assert.Equal(t, models.RoleAdmin, claims.Role)
+				assert.NotEmpty(t, resp.RefreshToken)
+			}
+
+			mockRepo.AssertExpectations(t)
+			sessionsRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoginRehashesOutdatedHash(t *testing.T) {
+	authService, mockRepo, sessionsRepo, _, cfg := newTestAuthService(t)
+	hasher := newTestHasher(t, cfg)
+
+	// An argon2id hash while bcrypt is preferred, and a bcrypt hash below the configured cost
+	legacyCfg := cfg.Password
+	legacyCfg.Algorithm = config.HashArgon2id
+	legacyCfg.Argon2Memory = 64
+	legacyCfg.Argon2Iterations = 1
+	legacyCfg.Argon2Parallelism = 1
+	argon2Hash, err := newTestHasher(t, config.Config{Password: legacyCfg}).Hash("password123")
+	require.NoError(t, err)
+
+	cfg.Password.BcryptCost = bcrypt.MinCost + 1
+	strongerService, strongerRepo, strongerSessions, _, _ := newTestAuthService(t)
+	strongerService.hasher = newTestHasher(t, cfg)
+	weakHash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		service   *AuthService
+		repo      *mocks.MockUserRepository
+		sessions  *mocks.MockRefreshTokenRepository
+		hash      string
+		updateErr error
+		verify    func(hash string) bool
+	}{
+		{
+			name:     "Legacy algorithm",
+			service:  authService,
+			repo:     mockRepo,
+			sessions: sessionsRepo,
+			hash:     argon2Hash,
+			verify: func(hash string) bool {
+				ok, _ := hasher.Verify(hash, "password123")
+				return ok && strings.HasPrefix(hash, "$2a$")
+			},
+		},
+		{
+			name:     "Cost below configured",
+			service:  strongerService,
+			repo:     strongerRepo,
+			sessions: strongerSessions,
+			hash:     string(weakHash),
+			verify: func(hash string) bool {
+				cost, err := bcrypt.Cost([]byte(hash))
+				return err == nil && cost == bcrypt.MinCost+1 &&
+					bcrypt.CompareHashAndPassword([]byte(hash), []byte("password123")) == nil
+			},
+		},
+		{
+			name:      "Failed upgrade does not fail the login",
+			service:   authService,
+			repo:      mockRepo,
+			sessions:  sessionsRepo,
+			hash:      argon2Hash,
+			updateErr: errors.New("repository error"),
+			verify:    func(string) bool { return true },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: tc.hash}
+			tc.repo.On("GetUserByLogin", "alice").Return(user, nil).Once()
+			tc.repo.On("UpdateUserPassword", user.ID, mock.MatchedBy(tc.verify)).Return(tc.updateErr).Once()
+			tc.sessions.On("CreateRefreshToken", mock.Anything).Return(nil).Once()
+
+			resp, err := tc.service.Login(dtos.LoginRequest{Login: "alice", Password: "password123"}, testClientIP)
+			require.NoError(t, err)
+			assert.NotEmpty(t, resp.AccessToken)
+
+			tc.repo.AssertExpectations(t)
+			tc.sessions.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	authService, usersRepo, sessionsRepo, _, _ := newTestAuthService(t)
+
+	userID, familyID := uuid.New(), uuid.New()
+	active := models.RefreshToken{UserID: userID, FamilyID: familyID, ExpiresAt: time.Now().Add(time.Hour)}
+	hash := auth.HashOpaqueToken("refresh-token")
+
+	testCases := []struct {
+		name        string
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name: "Success rotates within the family",
+			setupMock: func() {
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(active, nil).Once()
+				usersRepo.On("GetUser", userID).Return(models.User{ID: userID}, nil).Once()
+				sessionsRepo.On("CreateRefreshToken", mock.MatchedBy(func(token models.RefreshToken) bool {
+					return token.UserID == userID && token.FamilyID == familyID && token.TokenHash != hash
+				})).Return(nil).Once()
+			},
+		},
+		{
+			name:        "Unknown token",
+			expectedErr: apperrors.ErrInvalidRefreshToken,
+			setupMock: func() {
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(models.RefreshToken{}, apperrors.ErrInvalidRefreshToken).Once()
+			},
+		},
+		{
+			name:        "Reused token revokes the family",
+			expectedErr: fmt.Errorf("%w: token was already used", apperrors.ErrInvalidRefreshToken),
+			setupMock: func() {
+				used := active
+				used.UsedAt = time.Now()
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(used, nil).Once()
+				sessionsRepo.On("RevokeRefreshTokenFamily", familyID).Return(nil).Once()
+			},
+		},
+		{
+			name:        "Revoked token",
+			expectedErr: fmt.Errorf("%w: session was revoked", apperrors.ErrInvalidRefreshToken),
+			setupMock: func() {
+				revoked := active
+				revoked.RevokedAt = time.Now()
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(revoked, nil).Once()
+			},
+		},
+		{
+			name:        "Expired token",
+			expectedErr: fmt.Errorf("%w: token expired", apperrors.ErrInvalidRefreshToken),
+			setupMock: func() {
+				expired := active
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(expired, nil).Once()
+			},
+		},
+		{
+			name:        "Deleted user",
+			expectedErr: fmt.Errorf("%w: user no longer exists", apperrors.ErrInvalidRefreshToken),
+			setupMock: func() {
+				sessionsRepo.On("ConsumeRefreshToken", hash).Return(active, nil).Once()
+				usersRepo.On("GetUser", userID).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			resp, err := authService.Refresh(dtos.RefreshTokenRequest{RefreshToken: "refresh-token"})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, apperrors.ErrInvalidRefreshToken)
+				assert.EqualError(t, err, tc.expectedErr.Error())
+			} else {
+				require.NoError(t, err)
+				assert.NotEmpty(t, resp.AccessToken)
+				assert.NotEmpty(t, resp.RefreshToken)
+			}
+
+			usersRepo.AssertExpectations(t)
+			sessionsRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	authService, _, sessionsRepo, _, _ := newTestAuthService(t)
+
+	familyID := uuid.New()
+	sessionsRepo.On("ConsumeRefreshToken", auth.HashOpaqueToken("known")).Return(models.RefreshToken{FamilyID: familyID}, nil).Once()
+	sessionsRepo.On("RevokeRefreshTokenFamily", familyID).Return(nil).Once()
+	assert.NoError(t, authService.Logout(dtos.RefreshTokenRequest{RefreshToken: "known"}))
+
+	// Logging out with an unknown token is not an error
+	sessionsRepo.On("ConsumeRefreshToken", auth.HashOpaqueToken("unknown")).Return(models.RefreshToken{}, apperrors.ErrInvalidRefreshToken).Once()
+	assert.NoError(t, authService.Logout(dtos.RefreshTokenRequest{RefreshToken: "unknown"}))
+
+	sessionsRepo.AssertExpectations(t)
+}
+
+func TestLogoutAll(t *testing.T) {
+	authService, _, sessionsRepo, _, _ := newTestAuthService(t)
+
+	userID := uuid.New()
+	sessionsRepo.On("RevokeUserRefreshTokens", userID).Return(nil).Once()
+	assert.NoError(t, authService.LogoutAll(userID.String()))
+
+	err := authService.LogoutAll("invalid-uuid")
+	assert.ErrorIs(t, err, apperrors.ErrInvalidID)
+
+	sessionsRepo.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	authService, usersRepo, sessionsRepo, _, _ := newTestAuthService(t)
+
+	id := uuid.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: id, Nickname: "alice", Email: "alice@example.com", Password: string(hash)}
+
+	testCases := []struct {
+		name        string
+		idStr       string
+		req         dtos.ChangePasswordRequest
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name:  "Success revokes sessions",
+			idStr: id.String(),
+			req:   dtos.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "brand-new-secret"},
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(user, nil).Once()
+				usersRepo.On("UpdateUserPassword", id, mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("brand-new-secret")) == nil
+				})).Return(nil).Once()
+				sessionsRepo.On("RevokeUserRefreshTokens", id).Return(nil).Once()
+			},
+		},
+		{
+			name:        "Incorrect current password",
+			idStr:       id.String(),
+			req:         dtos.ChangePasswordRequest{CurrentPassword: "guess", NewPassword: "brand-new-secret"},
+			expectedErr: apperrors.ErrIncorrectPassword,
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(user, nil).Once()
+			},
+		},
+		{
+			name:        "Same password",
+			idStr:       id.String(),
+			req:         dtos.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "old-password"},
+			expectedErr: apperrors.ErrWeakPassword,
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(user, nil).Once()
+			},
+		},
+		{
+			name:        "New password breaks the policy",
+			idStr:       id.String(),
+			req:         dtos.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "alice-1234"},
+			expectedErr: apperrors.ErrWeakPassword,
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(user, nil).Once()
+			},
+		},
+		{
+			name:        "Unknown user",
+			idStr:       id.String(),
+			req:         dtos.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "brand-new-secret"},
+			expectedErr: apperrors.ErrUserNotFound,
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+		{
+			name:        "Invalid ID",
+			idStr:       "invalid-uuid",
+			req:         dtos.ChangePasswordRequest{CurrentPassword: "old-password", NewPassword: "brand-new-secret"},
+			expectedErr: apperrors.ErrInvalidID,
+			setupMock:   func() {},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			err := authService.ChangePassword(tc.idStr, tc.req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			usersRepo.AssertExpectations(t)
+			sessionsRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	authService, usersRepo, _, oneTimeRepo, notifier := newTestResetService(t)
+
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com"}
+	usersRepo.On("GetUserByEmail", "alice@example.com").Return(user, nil).Once()
+	oneTimeRepo.On("DeleteUserOneTimeTokens", user.ID, models.TokenPurposePasswordReset).Return(nil).Once()
+
+	var stored models.OneTimeToken
+	oneTimeRepo.On("CreateOneTimeToken", mock.MatchedBy(func(token models.OneTimeToken) bool {
+		return token.UserID == user.ID && token.Purpose == models.TokenPurposePasswordReset &&
+			token.ExpiresAt.After(time.Now().Add(59*time.Minute))
+	})).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(models.OneTimeToken)
+	}).Return(nil).Once()
+
+	require.NoError(t, authService.RequestPasswordReset(dtos.PasswordResetRequest{Email: "alice@example.com"}))
+
+	// The link carries the token whose hash was stored
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, "alice@example.com", notifier.messages[0].To)
+	_, link, found := strings.Cut(notifier.messages[0].Body, "https://example.com/reset?token=")
+	require.True(t, found)
+	token, err := url.QueryUnescape(strings.Fields(link)[0])
+	require.NoError(t, err)
+	assert.Equal(t, stored.TokenHash, auth.HashOpaqueToken(token))
+
+	// Unknown emails succeed without sending anything
+	usersRepo.On("GetUserByEmail", "bob@example.com").Return(models.User{}, apperrors.ErrUserNotFound).Once()
+	require.NoError(t, authService.RequestPasswordReset(dtos.PasswordResetRequest{Email: "bob@example.com"}))
+	assert.Len(t, notifier.messages, 1)
+
+	usersRepo.AssertExpectations(t)
+	oneTimeRepo.AssertExpectations(t)
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: "old-hash"}
+	hash := auth.HashOpaqueToken("reset-token")
+	token := models.OneTimeToken{UserID: user.ID, Purpose: models.TokenPurposePasswordReset, TokenHash: hash}
+
+	testCases := []struct {
+		name        string
+		newPassword string
+		expectedErr error
+		setupMock   func(usersRepo *mocks.MockUserRepository, sessionsRepo *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository)
+	}{
+		{
+			name:        "Success",
+			newPassword: "new-password",
+			setupMock: func(usersRepo *mocks.MockUserRepository, sessionsRepo *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository) {
+				oneTimeRepo.On("GetOneTimeToken", models.TokenPurposePasswordReset, hash).Return(token, nil).Once()
+				usersRepo.On("GetUser", user.ID).Return(user, nil).Once()
+				oneTimeRepo.On("ConsumeOneTimeToken", models.TokenPurposePasswordReset, hash).Return(token, nil).Once()
+				usersRepo.On("UpdateUserPassword", user.ID, mock.MatchedBy(func(hash string) bool {
+					return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password")) == nil
+				})).Return(nil).Once()
+				oneTimeRepo.On("DeleteUserOneTimeTokens", user.ID, models.TokenPurposePasswordReset).Return(nil).Once()
+				sessionsRepo.On("RevokeUserRefreshTokens", user.ID).Return(nil).Once()
+			},
+		},
+		{
+			name:        "Invalid token",
+			newPassword: "new-password",
+			expectedErr: apperrors.ErrInvalidToken,
+			setupMock: func(_ *mocks.MockUserRepository, _ *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository) {
+				oneTimeRepo.On("GetOneTimeToken", models.TokenPurposePasswordReset, hash).Return(models.OneTimeToken{}, apperrors.ErrInvalidToken).Once()
+			},
+		},
+		{
+			name:        "Weak password keeps the token",
+			newPassword: "alice123",
+			expectedErr: apperrors.ErrWeakPassword,
+			setupMock: func(usersRepo *mocks.MockUserRepository, _ *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository) {
+				oneTimeRepo.On("GetOneTimeToken", models.TokenPurposePasswordReset, hash).Return(token, nil).Once()
+				usersRepo.On("GetUser", user.ID).Return(user, nil).Once()
+			},
+		},
+		{
+			name:        "Token used concurrently",
+			newPassword: "new-password",
+			expectedErr: apperrors.ErrInvalidToken,
+			setupMock: func(usersRepo *mocks.MockUserRepository, _ *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository) {
+				oneTimeRepo.On("GetOneTimeToken", models.TokenPurposePasswordReset, hash).Return(token, nil).Once()
+				usersRepo.On("GetUser", user.ID).Return(user, nil).Once()
+				oneTimeRepo.On("ConsumeOneTimeToken", models.TokenPurposePasswordReset, hash).Return(models.OneTimeToken{}, apperrors.ErrInvalidToken).Once()
+			},
+		},
+		{
+			name:        "Deleted user",
+			newPassword: "new-password",
+			expectedErr: apperrors.ErrInvalidToken,
+			setupMock: func(usersRepo *mocks.MockUserRepository, _ *mocks.MockRefreshTokenRepository, oneTimeRepo *mocks.MockOneTimeTokenRepository) {
+				oneTimeRepo.On("GetOneTimeToken", models.TokenPurposePasswordReset, hash).Return(token, nil).Once()
+				usersRepo.On("GetUser", user.ID).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			authService, usersRepo, sessionsRepo, oneTimeRepo, _ := newTestResetService(t)
+			tc.setupMock(usersRepo, sessionsRepo, oneTimeRepo)
+
+			err := authService.ConfirmPasswordReset(dtos.PasswordResetConfirmRequest{Token: "reset-token", NewPassword: tc.newPassword})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			usersRepo.AssertExpectations(t)
+			sessionsRepo.AssertExpectations(t)
+			oneTimeRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	authService, usersRepo, sessionsRepo, _, _ := newTestAuthService(t)
+	authService.requireVerifiedEmail = true
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Password: string(hash), Role: models.RoleUser}
+
+	// The password is checked first, so a wrong one still reads as invalid credentials
+	usersRepo.On("GetUserByLogin", "alice").Return(user, nil).Twice()
+	_, err = authService.Login(dtos.LoginRequest{Login: "alice", Password: "password124"}, testClientIP)
+	assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	_, err = authService.Login(dtos.LoginRequest{Login: "alice", Password: "password123"}, testClientIP)
+	assert.ErrorIs(t, err, apperrors.ErrEmailNotVerified)
+
+	user.EmailVerified = true
+	usersRepo.On("GetUserByLogin", "alice").Return(user, nil).Once()
+	sessionsRepo.On("CreateRefreshToken", mock.Anything).Return(nil).Once()
+	_, err = authService.Login(dtos.LoginRequest{Login: "alice", Password: "password123"}, testClientIP)
+	assert.NoError(t, err)
+
+	usersRepo.AssertExpectations(t)
+	sessionsRepo.AssertExpectations(t)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/cursor"
+	"github.com/sosshik/users-service/internal/models"
+	"strconv"
+	"time"
+)
+
+// encodeCursor returns a signed cursor pointing next to user in the order of keys
+func (u *UsersService) encodeCursor(user models.User, keys []models.SortKey, query string, backward bool) (string, error) {
+	c := cursor.Cursor{Query: query, ID: user.ID, Backward: backward}
+	for _, key := range keys {
+		c.Values = append(c.Values, sortFieldValue(&user, key.Field))
+	}
+
+	return u.cursors.Encode(c)
+}
+
+// decodeCursor verifies a cursor and turns it back into the boundary it points next to;
+// it must have been issued for the same query
+func (u *UsersService) decodeCursor(token string, keys []models.SortKey, query string) (models.UserSeek, error) {
+	c, err := u.cursors.Decode(token)
+	if err != nil {
+		return models.UserSeek{}, fmt.Errorf("%w: %w", apperrors.ErrInvalidCursor, err)
+	}
+	if c.Query != query || len(c.Values) != len(keys) {
+		return models.UserSeek{}, fmt.Errorf("%w: cursor belongs to a different filter or sort", apperrors.ErrInvalidCursor)
+	}
+
+	seek := models.UserSeek{Boundary: models.User{ID: c.ID}, Backward: c.Backward}
+	for i, key := range keys {
+		if err := setSortField(&seek.Boundary, key.Field, c.Values[i]); err != nil {
+			return models.UserSeek{}, fmt.Errorf("%w: %w", apperrors.ErrInvalidCursor, err)
+		}
+	}
+
+	return seek, nil
+}
+
+// sortFieldValue formats a sortable field of user for a cursor
+func sortFieldValue(user *models.User, field string) string {
+	switch field {
+	case "nickname":
+		return user.Nickname
+	case "email":
+		return user.Email
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "country":
+		return user.Country
+	case "role":
+		return user.Role
+	case "email_verified":
+		return strconv.FormatBool(user.EmailVerified)
+	case "created_at":
+		return user.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return user.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+// setSortField parses a value formatted by sortFieldValue into a sortable field of user
+func setSortField(user *models.User, field, value string) error {
+	var err error
+	switch field {
+	case "nickname":
+		user.Nickname = value
+	case "email":
+		user.Email = value
+	case "first_name":
+		user.FirstName = value
+	case "last_name":
+		user.LastName = value
+	case "country":
+		user.Country = value
+	case "role":
+		user.Role = value
+	case "email_verified":
+		user.EmailVerified, err = strconv.ParseBool(value)
+	case "created_at":
+		user.CreatedAt, err = time.Parse(time.RFC3339Nano, value)
+	case "updated_at":
+		user.UpdatedAt, err = time.Parse(time.RFC3339Nano, value)
+	default:
+		err = fmt.Errorf("users cannot be sorted by %q", field)
+	}
+	return err
+}
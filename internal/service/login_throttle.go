@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"strings"
+	"time"
+)
+
+// throttleKey is a failed login counter together with the limits that apply to it
+type throttleKey struct {
+	key    string
+	limits config.ThrottleLimits
+}
+
+// accountThrottleKey is the failed login counter of an existing user, shared by their nickname and email
+func accountThrottleKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
+
+// loginThrottleKeys returns the counters a login attempt is checked against and counted under. Logins of
+// unknown users, passed as uuid.Nil, are counted under the login itself, so they are throttled exactly like real accounts.
+func (a *AuthService) loginThrottleKeys(userID uuid.UUID, login, clientIP string) []throttleKey {
+	account := "login:" + strings.ToLower(login)
+	if userID != uuid.Nil {
+		account = accountThrottleKey(userID)
+	}
+
+	keys := []throttleKey{{key: account, limits: a.throttle.Account}}
+	if clientIP != "" {
+		keys = append(keys, throttleKey{key: "ip:" + clientIP, limits: a.throttle.IP})
+	}
+	return keys
+}
+
+// beginLoginAttempt counts the attempt as a failure under every key before the password is checked, so a burst
+// of concurrent guesses cannot all pass the check before the first of them is counted. While any counter is
+// delayed or locked out the attempt is refused and counted nowhere. It returns the counters in the order of keys.
+func (a *AuthService) beginLoginAttempt(keys []throttleKey) ([]models.LoginAttempt, error) {
+	var counted []models.LoginAttempt
+	var retryAfter time.Duration
+	var err error
+	for _, k := range keys {
+		attempts, blockedUntil, beginErr := a.attempts.BeginLoginAttempt(k.key, a.throttle.LockoutDuration, func(attempts models.LoginAttempts) time.Time {
+			return a.blockedUntil(attempts, k.limits)
+		})
+		if beginErr != nil {
+			err = beginErr
+			break
+		}
+		if wait := time.Until(blockedUntil); wait > 0 {
+			retryAfter = max(retryAfter, wait)
+			continue
+		}
+		counted = append(counted, attempts)
+	}
+
+	if err == nil && retryAfter == 0 {
+		return counted, nil
+	}
+
+	// The attempt does not take place, so the counters that already counted it go back to how they were
+	a.refundLoginAttempt(counted)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &apperrors.ThrottledError{RetryAfter: retryAfter}
+}
+
+// refundLoginAttempt takes back an attempt from the counters that counted it, once it succeeded or did not take place
+func (a *AuthService) refundLoginAttempt(counted []models.LoginAttempt) {
+	for _, attempt := range counted {
+		if err := a.attempts.RefundLoginAttempt(attempt); err != nil {
+			log.Errorf("[refundLoginAttempt] Unable to refund login attempt for %s: %s", attempt.After.Key, err)
+		}
+	}
+}
+
+// blockedUntil returns when the next attempt is allowed: never delayed within the free attempts, then
+// after an exponentially growing delay, and after the lockout duration once the lockout threshold is reached
+func (a *AuthService) blockedUntil(attempts models.LoginAttempts, limits config.ThrottleLimits) time.Time {
+	if attempts.Failures >= limits.Lockout {
+		return attempts.LastFailureAt.Add(a.throttle.LockoutDuration)
+	}
+	if attempts.Failures < limits.FreeAttempts {
+		return time.Time{}
+	}
+
+	delay := a.throttle.BaseDelay
+	for i := limits.FreeAttempts; i < attempts.Failures && delay < a.throttle.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > a.throttle.MaxDelay {
+		delay = a.throttle.MaxDelay
+	}
+
+	return attempts.LastFailureAt.Add(delay)
+}
+
+// recordLoginFailure records an audit event for each counter the failed attempt locked out; the failure itself
+// was already counted by beginLoginAttempt
+func (a *AuthService) recordLoginFailure(keys []throttleKey, counted []models.LoginAttempt, clientIP string) {
+	for i, k := range keys {
+		if attempts := counted[i].After; attempts.Failures == k.limits.Lockout {
+			a.audit.Record(audit.Event{
+				Type:     audit.EventLoginLocked,
+				Subject:  k.key,
+				ClientIP: clientIP,
+				Detail:   fmt.Sprintf("locked out for %s after %d failed logins", a.throttle.LockoutDuration, attempts.Failures),
+				Time:     attempts.LastFailureAt,
+			})
+		}
+	}
+}
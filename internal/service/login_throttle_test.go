@@ -0,0 +1,291 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/password"
+	"github.com/sosshik/users-service/internal/repository/inmemory"
+	mocks "github.com/sosshik/users-service/internal/repository/mock"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestThrottledService creates an AuthService with small login limits and long delays, so throttled
+// attempts stay refused for the whole test
+func newTestThrottledService(t *testing.T) (*AuthService, *mocks.MockUserRepository, *mocks.MockRefreshTokenRepository, *recordingAuditor) {
+	t.Helper()
+
+	authService, usersRepo, sessionsRepo, _, _ := newTestAuthService(t)
+	auditor := &recordingAuditor{}
+	authService.audit = auditor
+	authService.attempts = inmemory.NewLoginAttemptStorage()
+	authService.throttle = config.LoginThrottleConfig{
+		Account:         config.ThrottleLimits{FreeAttempts: 2, Lockout: 4},
+		IP:              config.ThrottleLimits{FreeAttempts: 3, Lockout: 5},
+		BaseDelay:       time.Hour,
+		MaxDelay:        2 * time.Hour,
+		LockoutDuration: 24 * time.Hour,
+	}
+
+	return authService, usersRepo, sessionsRepo, auditor
+}
+
+func TestBlockedUntil(t *testing.T) {
+	authService, _, _, _ := newTestThrottledService(t)
+	limits := authService.throttle.Account
+	last := time.Now()
+
+	testCases := []struct {
+		name     string
+		failures int
+		expected time.Time
+	}{
+		{name: "No failures", failures: 0},
+		{name: "Within the free attempts", failures: 1},
+		{name: "First delay", failures: 2, expected: last.Add(time.Hour)},
+		{name: "Delay doubles up to the maximum", failures: 3, expected: last.Add(2 * time.Hour)},
+		{name: "Locked out", failures: 4, expected: last.Add(24 * time.Hour)},
+		{name: "Stays locked out", failures: 9, expected: last.Add(24 * time.Hour)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := models.LoginAttempts{Failures: tc.failures, LastFailureAt: last}
+			assert.Equal(t, tc.expected, authService.blockedUntil(attempts, limits))
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	authService, usersRepo, sessionsRepo, auditor := newTestThrottledService(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: string(hash)}
+	usersRepo.On("GetUserByLogin", mock.MatchedBy(func(login string) bool {
+		return login == "alice" || login == "alice@example.com"
+	})).Return(user, nil)
+	sessionsRepo.On("CreateRefreshToken", mock.Anything).Return(nil)
+
+	login := func(login, password, clientIP string) error {
+		_, err := authService.Login(dtos.LoginRequest{Login: login, Password: password}, clientIP)
+		return err
+	}
+
+	// The free attempts fail normally; nickname and email share the account's counter
+	assert.ErrorIs(t, login("alice", "wrong", "192.0.2.1"), apperrors.ErrInvalidCredentials)
+	assert.ErrorIs(t, login("alice@example.com", "wrong", "192.0.2.2"), apperrors.ErrInvalidCredentials)
+
+	// Afterwards even the right password is refused until the delay has passed
+	err = login("alice", "password123", "192.0.2.3")
+	var throttled *apperrors.ThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.InDelta(t, time.Hour.Seconds(), throttled.RetryAfter.Seconds(), 5)
+	assert.Empty(t, auditor.events)
+
+	// An administrator lifts the delay
+	actorID := uuid.New()
+	usersRepo.On("GetUser", user.ID).Return(user, nil).Once()
+	require.NoError(t, authService.UnlockUser(user.ID.String(), actorID.String()))
+	require.Len(t, auditor.events, 1)
+	assert.Equal(t, audit.EventLoginUnlocked, auditor.events[0].Type)
+	assert.Equal(t, actorID.String(), auditor.events[0].Actor)
+	require.NoError(t, login("alice", "password123", "192.0.2.3"))
+
+	// The client addresses of the failed attempts were counted too, but are still within their free attempts
+	require.NoError(t, login("alice", "password123", "192.0.2.1"))
+}
+
+func TestLoginLockout(t *testing.T) {
+	authService, usersRepo, sessionsRepo, auditor := newTestThrottledService(t)
+	// Delays pass almost immediately, so failures can pile up to the lockout
+	authService.throttle.BaseDelay = time.Millisecond
+	authService.throttle.MaxDelay = time.Millisecond
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: string(hash)}
+	usersRepo.On("GetUserByLogin", "alice").Return(user, nil)
+	usersRepo.On("GetUserByLogin", mock.Anything).Return(models.User{}, apperrors.ErrUserNotFound)
+	sessionsRepo.On("CreateRefreshToken", mock.Anything).Return(nil)
+
+	login := func(login, password, clientIP string) error {
+		time.Sleep(2 * time.Millisecond)
+		_, err := authService.Login(dtos.LoginRequest{Login: login, Password: password}, clientIP)
+		return err
+	}
+
+	// Failures from different addresses lock out the account
+	for i := 0; i < 4; i++ {
+		assert.ErrorIs(t, login("alice", "wrong", fmt.Sprintf("198.51.100.%d", i)), apperrors.ErrInvalidCredentials)
+	}
+	var throttled *apperrors.ThrottledError
+	require.ErrorAs(t, login("alice", "password123", "198.51.100.9"), &throttled)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), throttled.RetryAfter.Seconds(), 5)
+
+	require.Len(t, auditor.events, 1)
+	assert.Equal(t, audit.EventLoginLocked, auditor.events[0].Type)
+	assert.Equal(t, accountThrottleKey(user.ID), auditor.events[0].Subject)
+	assert.Equal(t, "198.51.100.3", auditor.events[0].ClientIP)
+
+	// Guessing many logins from one address locks out the address; unknown logins count like real ones
+	for i := 0; i < 5; i++ {
+		assert.ErrorIs(t, login(fmt.Sprintf("user%d", i), "wrong", "203.0.113.7"), apperrors.ErrInvalidCredentials)
+	}
+	require.Len(t, auditor.events, 2)
+	assert.Equal(t, audit.EventLoginLocked, auditor.events[1].Type)
+	assert.Equal(t, "ip:203.0.113.7", auditor.events[1].Subject)
+	assert.ErrorIs(t, login("bob", "password123", "203.0.113.7"), apperrors.ErrTooManyAttempts)
+
+	// Unlocking the account does not lift the lockout of the address
+	usersRepo.On("GetUser", user.ID).Return(user, nil).Once()
+	require.NoError(t, authService.UnlockUser(user.ID.String(), uuid.NewString()))
+	assert.ErrorIs(t, login("alice", "password123", "203.0.113.7"), apperrors.ErrTooManyAttempts)
+	require.NoError(t, login("alice", "password123", "198.51.100.9"))
+}
+
+// countingHasher counts password verifications, each of which takes a while so concurrent logins overlap
+type countingHasher struct {
+	password.Hasher
+	verified atomic.Int32
+}
+
+func (h *countingHasher) Verify(hash, plain string) (bool, error) {
+	h.verified.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return h.Hasher.Verify(hash, plain)
+}
+
+func TestLoginThrottleConcurrentGuesses(t *testing.T) {
+	authService, usersRepo, _, _ := newTestThrottledService(t)
+	hasher := &countingHasher{Hasher: authService.hasher}
+	authService.hasher = hasher
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: string(hash)}
+	usersRepo.On("GetUserByLogin", "alice").Return(user, nil)
+
+	// A burst of guesses from different addresses, more than the account's lockout threshold
+	const guesses = 20
+	errs := make(chan error, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := authService.Login(dtos.LoginRequest{Login: "alice", Password: "wrong"}, fmt.Sprintf("198.51.100.%d", i))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	throttled := 0
+	for err := range errs {
+		if errors.Is(err, apperrors.ErrTooManyAttempts) {
+			throttled++
+			continue
+		}
+		assert.ErrorIs(t, err, apperrors.ErrInvalidCredentials)
+	}
+
+	// Only the attempts the counter let through got to check the password, the rest were refused
+	verified := int(hasher.verified.Load())
+	assert.LessOrEqual(t, verified, authService.throttle.Account.Lockout)
+	assert.Equal(t, guesses-verified, throttled)
+}
+
+func TestLoginThrottleRefusedAttemptsLeaveCounters(t *testing.T) {
+	authService, usersRepo, sessionsRepo, _ := newTestThrottledService(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com", Password: string(hash)}
+	usersRepo.On("GetUserByLogin", "alice").Return(user, nil)
+	usersRepo.On("GetUserByLogin", mock.Anything).Return(models.User{}, apperrors.ErrUserNotFound)
+	sessionsRepo.On("CreateRefreshToken", mock.Anything).Return(nil)
+
+	login := func(login, password, clientIP string) error {
+		_, err := authService.Login(dtos.LoginRequest{Login: login, Password: password}, clientIP)
+		return err
+	}
+	counter := func(key string) models.LoginAttempts {
+		attempts, err := authService.attempts.GetLoginAttempts(key)
+		require.NoError(t, err)
+		return attempts
+	}
+
+	// One failure of the account, and an address that used up its free attempts
+	assert.ErrorIs(t, login("alice", "wrong", "192.0.2.1"), apperrors.ErrInvalidCredentials)
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, login(fmt.Sprintf("user%d", i), "wrong", "203.0.113.7"), apperrors.ErrInvalidCredentials)
+	}
+	account := counter(accountThrottleKey(user.ID))
+	blockedIP := counter("ip:203.0.113.7")
+	time.Sleep(time.Millisecond)
+
+	// Guesses from the blocked address neither count against the account nor restart its delay or expiry
+	for i := 0; i < 3; i++ {
+		var throttled *apperrors.ThrottledError
+		require.ErrorAs(t, login("alice", "wrong", "203.0.113.7"), &throttled)
+	}
+	assert.Equal(t, account, counter(accountThrottleKey(user.ID)))
+	assert.Equal(t, blockedIP, counter("ip:203.0.113.7"))
+
+	// A successful login does not extend the window of its address either
+	address := counter("ip:192.0.2.1")
+	require.NoError(t, login("alice", "password123", "192.0.2.1"))
+	assert.Equal(t, address, counter("ip:192.0.2.1"))
+}
+
+func TestUnlockUser(t *testing.T) {
+	authService, usersRepo, _, auditor := newTestThrottledService(t)
+	id := uuid.New()
+
+	testCases := []struct {
+		name        string
+		id          string
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name:        "Invalid ID",
+			id:          "not-a-uuid",
+			expectedErr: apperrors.ErrInvalidID,
+			setupMock:   func() {},
+		},
+		{
+			name:        "Unknown user",
+			id:          id.String(),
+			expectedErr: apperrors.ErrUserNotFound,
+			setupMock: func() {
+				usersRepo.On("GetUser", id).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			err := authService.UnlockUser(tc.id, uuid.NewString())
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Empty(t, auditor.events)
+
+			usersRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/repository"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// issueOneTimeToken replaces the user's tokens for purpose with a new one valid for ttl,
+// so only the most recent link stays usable, and returns the token to send to the user
+func issueOneTimeToken(repo repository.OneTimeTokens, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	if err := repo.DeleteUserOneTimeTokens(userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = repo.CreateOneTimeToken(models.OneTimeToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// tokenLink fills the token into a link template containing config.TokenPlaceholder
+func tokenLink(template, token string) string {
+	return strings.ReplaceAll(template, config.TokenPlaceholder, url.QueryEscape(token))
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"reflect"
+	"strings"
+)
+
+// jsonPatchOperation is a single operation of an RFC 6902 JSON Patch document
+type jsonPatchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from"`
+	// Value is kept raw so a missing value can be told apart from null
+	Value json.RawMessage `json:"value"`
+}
+
+// applyProfilePatch applies a patch document of the given media type to profile and returns the patched profile.
+// Members removed by the patch are cleared; members that are not part of the profile are rejected.
+func applyProfilePatch(profile dtos.UpdateUserRequest, patch []byte, mediaType string) (dtos.UpdateUserRequest, error) {
+	// Patches operate on the JSON representation of the profile
+	doc, err := profileDocument(profile)
+	if err != nil {
+		return dtos.UpdateUserRequest{}, err
+	}
+
+	switch mediaType {
+	case dtos.MergePatchMediaType:
+		var mergePatch any
+		if err := json.Unmarshal(patch, &mergePatch); err != nil {
+			return dtos.UpdateUserRequest{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+		}
+		patched, ok := applyMergePatch(doc, mergePatch).(map[string]any)
+		if !ok {
+			return dtos.UpdateUserRequest{}, fmt.Errorf("%w: merge patch must be a JSON object", apperrors.ErrInvalidPayload)
+		}
+		doc = patched
+	case dtos.JSONPatchMediaType:
+		var ops []jsonPatchOperation
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return dtos.UpdateUserRequest{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+		}
+		for i, op := range ops {
+			if err := applyJSONPatchOperation(doc, op); err != nil {
+				return dtos.UpdateUserRequest{}, fmt.Errorf("%w (operation %d)", err, i)
+			}
+		}
+	default:
+		return dtos.UpdateUserRequest{}, fmt.Errorf("%w: %q", apperrors.ErrUnsupportedMediaType, mediaType)
+	}
+
+	return decodeProfileDocument(doc)
+}
+
+// profileDocument converts profile into a generic JSON object
+func profileDocument(profile dtos.UpdateUserRequest) (map[string]any, error) {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	err = json.Unmarshal(data, &doc)
+
+	return doc, err
+}
+
+// decodeProfileDocument converts a patched JSON object back into a profile, refusing unknown or read-only members
+func decodeProfileDocument(doc map[string]any) (dtos.UpdateUserRequest, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return dtos.UpdateUserRequest{}, err
+	}
+
+	var profile dtos.UpdateUserRequest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&profile); err != nil {
+		return dtos.UpdateUserRequest{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+	}
+
+	return profile, nil
+}
+
+// applyMergePatch implements the MergePatch algorithm of RFC 7396: null removes a member,
+// objects are merged recursively and any other value replaces the target
+func applyMergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = make(map[string]any)
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = applyMergePatch(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// applyJSONPatchOperation applies one RFC 6902 operation to doc. The profile is a flat object,
+// so paths must point at one of its members.
+func applyJSONPatchOperation(doc map[string]any, op jsonPatchOperation) error {
+	name, err := pointerMember(op.Path)
+	if err != nil {
+		return err
+	}
+
+	// Decode the value of the operations that need one
+	var value any
+	if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+		if len(op.Value) == 0 {
+			return fmt.Errorf("%w: %s operation needs a value", apperrors.ErrInvalidPayload, op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fmt.Errorf("%w: %s", apperrors.ErrInvalidPayload, err)
+		}
+	}
+
+	current, exists := doc[name]
+	switch op.Op {
+	case "add":
+		doc[name] = value
+	case "remove", "replace":
+		if !exists {
+			return fmt.Errorf("%w: %s does not exist", apperrors.ErrPatchConflict, op.Path)
+		}
+		if op.Op == "remove" {
+			delete(doc, name)
+		} else {
+			doc[name] = value
+		}
+	case "move", "copy":
+		from, err := pointerMember(op.From)
+		if err != nil {
+			return err
+		}
+		moved, found := doc[from]
+		if !found {
+			return fmt.Errorf("%w: %s does not exist", apperrors.ErrPatchConflict, op.From)
+		}
+		if op.Op == "move" {
+			delete(doc, from)
+		}
+		doc[name] = moved
+	case "test":
+		if !exists || !reflect.DeepEqual(current, value) {
+			return fmt.Errorf("%w: test of %s failed", apperrors.ErrPatchConflict, op.Path)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q", apperrors.ErrInvalidPayload, op.Op)
+	}
+
+	return nil
+}
+
+// pointerMember resolves a JSON Pointer (RFC 6901) that must reference a top-level member
+func pointerMember(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Count(pointer, "/") != 1 {
+		return "", fmt.Errorf("%w: path %q must point at a profile field", apperrors.ErrInvalidPayload, pointer)
+	}
+
+	// ~1 has to be decoded before ~0, see RFC 6901 section 4
+	name := strings.ReplaceAll(pointer[1:], "~1", "/")
+	return strings.ReplaceAll(name, "~0", "~"), nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/pkg/dtos"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApplyProfilePatch(t *testing.T) {
+	profile := dtos.UpdateUserRequest{
+		FirstName: "Alice",
+		LastName:  "Liddell",
+		Nickname:  "alice",
+		Email:     "alice@example.com",
+		Country:   "England",
+	}
+
+	testCases := []struct {
+		name            string
+		patch           string
+		mediaType       string
+		expectedProfile dtos.UpdateUserRequest
+		expectedErr     error
+	}{
+		{
+			name:      "Merge patch replaces and clears fields",
+			patch:     `{"country": "Wonderland", "first_name": null}`,
+			mediaType: dtos.MergePatchMediaType,
+			expectedProfile: dtos.UpdateUserRequest{
+				LastName: "Liddell",
+				Nickname: "alice",
+				Email:    "alice@example.com",
+				Country:  "Wonderland",
+			},
+		},
+		{
+			name:            "Empty merge patch changes nothing",
+			patch:           `{}`,
+			mediaType:       dtos.MergePatchMediaType,
+			expectedProfile: profile,
+		},
+		{
+			name:        "Merge patch that is not an object",
+			patch:       `["country"]`,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "Merge patch with a read-only field",
+			patch:       `{"version": 7}`,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "Merge patch with a wrong type",
+			patch:       `{"country": {"name": "Wonderland"}}`,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "Malformed merge patch",
+			patch:       `{"country": `,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name: "JSON Patch operations apply in order",
+			patch: `[
+				{"op": "test", "path": "/country", "value": "England"},
+				{"op": "copy", "from": "/first_name", "path": "/last_name"},
+				{"op": "move", "from": "/country", "path": "/first_name"},
+				{"op": "replace", "path": "/nickname", "value": "alice2"}
+			]`,
+			mediaType: dtos.JSONPatchMediaType,
+			expectedProfile: dtos.UpdateUserRequest{
+				FirstName: "England",
+				LastName:  "Alice",
+				Nickname:  "alice2",
+				Email:     "alice@example.com",
+			},
+		},
+		{
+			name:        "JSON Patch removing a missing field",
+			patch:       `[{"op": "remove", "path": "/country"}, {"op": "remove", "path": "/country"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrPatchConflict,
+		},
+		{
+			name:        "JSON Patch adding an unknown field",
+			patch:       `[{"op": "add", "path": "/role", "value": "admin"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "JSON Patch with a nested path",
+			patch:       `[{"op": "add", "path": "/country/name", "value": "Wonderland"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "JSON Patch without a value",
+			patch:       `[{"op": "replace", "path": "/country"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "JSON Patch with an unknown operation",
+			patch:       `[{"op": "merge", "path": "/country", "value": "Wonderland"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+		},
+		{
+			name:        "Unsupported media type",
+			patch:       `country=Wonderland`,
+			mediaType:   "text/plain",
+			expectedErr: apperrors.ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patched, err := applyProfilePatch(profile, []byte(tc.patch), tc.mediaType)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedProfile, patched)
+		})
+	}
+}
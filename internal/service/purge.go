@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// RunPurge permanently removes the deleted users past their retention right away and then once per interval,
+// until ctx is done
+func RunPurge(ctx context.Context, users Users, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// A failed run is retried on the next tick
+		if _, err := users.PurgeDeletedUsers(); err != nil {
+			log.Errorf("[RunPurge] Unable to purge deleted users: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
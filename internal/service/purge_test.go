@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/sosshik/users-service/internal/config"
+	mocks "github.com/sosshik/users-service/internal/repository/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPurge(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	// The first run fails and is retried on the next tick
+	var runs atomic.Int32
+	countRun := func(mock.Arguments) { runs.Add(1) }
+	mockRepo.On("PurgeDeletedUsers", mock.Anything).Return(0, errors.New("database is locked")).Run(countRun).Once()
+	mockRepo.On("PurgeDeletedUsers", mock.Anything).Return(1, nil).Run(countRun)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		RunPurge(ctx, userService, time.Millisecond)
+	}()
+
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+
+	// Cancelling stops the job
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunPurge did not stop after cancellation")
+	}
+}
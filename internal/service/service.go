@@ -1,23 +1,52 @@
 package service
 
 import (
+	"github.com/sosshik/users-service/internal/audit"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/cursor"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/password"
 	"github.com/sosshik/users-service/internal/repository"
 	"github.com/sosshik/users-service/pkg/dtos"
 )
 
 type Users interface {
 	CreateUser(userReq dtos.CreateUserRequest) (dtos.CreateUserResponse, error)
-	UpdateUser(id string, userReq dtos.UpdateUserRequest) (dtos.UpdateUserResponse, error)
-	DeleteUser(idStr string) error
-	GetFilteredUsers(pageStr, pageSizeStr, filterStr string) (dtos.GetUserResponse, error)
+	GetUser(idStr string, includeDeleted bool) (dtos.GetUserDTO, error)
+	UpdateUser(id string, userReq dtos.UpdateUserRequest, expectedVersion int64) (dtos.UpdateUserResponse, error)
+	PatchUser(idStr string, patch []byte, mediaType string, expectedVersion int64) (dtos.UpdateUserResponse, error)
+	SetRole(idStr string, roleReq dtos.SetRoleRequest) (dtos.GetUserDTO, error)
+	BootstrapAdmin(cfg config.BootstrapAdminConfig) error
+	VerifyEmail(req dtos.VerifyEmailRequest) (dtos.GetUserDTO, error)
+	ResendVerificationEmail(idStr string) error
+	DeleteUser(idStr string, expectedVersion int64) error
+	RestoreUser(idStr string) (dtos.GetUserDTO, error)
+	HardDeleteUser(idStr string) error
+	PurgeDeletedUsers() (int, error)
+	GetFilteredUsers(req dtos.GetUsersRequest) (dtos.GetUserResponse, error)
+}
+
+type Auth interface {
+	Login(req dtos.LoginRequest, clientIP string) (dtos.LoginResponse, error)
+	Refresh(req dtos.RefreshTokenRequest) (dtos.LoginResponse, error)
+	Logout(req dtos.RefreshTokenRequest) error
+	LogoutAll(userIDStr string) error
+	UnlockUser(idStr, actorID string) error
+	ChangePassword(idStr string, req dtos.ChangePasswordRequest) error
+	RequestPasswordReset(req dtos.PasswordResetRequest) error
+	ConfirmPasswordReset(req dtos.PasswordResetConfirmRequest) error
 }
 
 type Service struct {
 	Users
+	Auth
 }
 
-func NewService(repo *repository.Repository) *Service {
+func NewService(repo *repository.Repository, tokens *auth.TokenManager, cursors *cursor.Signer, policy *password.Policy,
+	hasher password.Hasher, notifier notify.Notifier, auditor audit.Logger, cfg config.Config) *Service {
 	return &Service{
-		Users: NewUsersService(repo),
+		Users: NewUsersService(repo, repo, policy, hasher, notifier, cursors, cfg),
+		Auth:  NewAuthService(repo, repo, repo, repo, tokens, policy, hasher, notifier, auditor, cfg),
 	}
 }
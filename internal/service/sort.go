@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"slices"
+	"strings"
+)
+
+// defaultSort lists users in the order they were created
+var defaultSort = []models.SortKey{{Field: "created_at"}}
+
+// parseSort parses a comma separated list of fields, each prefixed with - to sort descending,
+// e.g. -created_at,nickname; a blank sort keeps the creation order
+func parseSort(sortStr string) ([]models.SortKey, error) {
+	if strings.TrimSpace(sortStr) == "" {
+		return nil, nil
+	}
+
+	var keys []models.SortKey
+	for _, part := range strings.Split(sortStr, ",") {
+		key := models.SortKey{Field: strings.TrimSpace(part)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = strings.TrimPrefix(key.Field, "-")
+			key.Desc = true
+		}
+
+		// Only allowlisted fields may be sorted by, and each of them only once
+		if key.Field == "" {
+			return nil, fmt.Errorf("%w: sort fields must not be empty", apperrors.ErrInvalidSort)
+		}
+		if !slices.Contains(models.SortFields, key.Field) {
+			return nil, fmt.Errorf("%w: users cannot be sorted by %q", apperrors.ErrInvalidSort, key.Field)
+		}
+		if slices.ContainsFunc(keys, func(k models.SortKey) bool { return k.Field == key.Field }) {
+			return nil, fmt.Errorf("%w: %s is sorted by more than once", apperrors.ErrInvalidSort, key.Field)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
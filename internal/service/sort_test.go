@@ -0,0 +1,62 @@
+package service
+
+import (
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name        string
+		sortStr     string
+		expected    []models.SortKey
+		expectedErr error
+	}{
+		{
+			name:     "Blank sort",
+			sortStr:  " ",
+			expected: nil,
+		},
+		{
+			name:     "Single ascending field",
+			sortStr:  "nickname",
+			expected: []models.SortKey{{Field: "nickname"}},
+		},
+		{
+			name:     "Newest first, then by nickname",
+			sortStr:  "-created_at, nickname",
+			expected: []models.SortKey{{Field: "created_at", Desc: true}, {Field: "nickname"}},
+		},
+		{
+			name:        "Field outside the allowlist",
+			sortStr:     "password",
+			expectedErr: apperrors.ErrInvalidSort,
+		},
+		{
+			name:        "Repeated field",
+			sortStr:     "nickname,-nickname",
+			expectedErr: apperrors.ErrInvalidSort,
+		},
+		{
+			name:        "Empty field",
+			sortStr:     "nickname,,email",
+			expectedErr: apperrors.ErrInvalidSort,
+		},
+		{
+			name:        "Lone minus",
+			sortStr:     "-",
+			expectedErr: apperrors.ErrInvalidSort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseSort(tt.sortStr)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, keys)
+		})
+	}
+}
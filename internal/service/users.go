@@ -1,23 +1,45 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jinzhu/copier"
+	log "github.com/sirupsen/logrus"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/auth"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/cursor"
+	"github.com/sosshik/users-service/internal/filter"
 	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/password"
 	"github.com/sosshik/users-service/internal/repository"
 	"github.com/sosshik/users-service/pkg/dtos"
-	"github.com/sosshik/users-service/pkg/utils"
-	"golang.org/x/crypto/bcrypt"
+	"math"
 	"strconv"
+	"time"
 )
 
+// maxPatchAttempts bounds how often a patch without If-Match is reapplied after concurrent changes
+const maxPatchAttempts = 3
+
 type UsersService struct {
-	repo repository.Users
+	repo     repository.Users
+	oneTime  repository.OneTimeTokens
+	policy   *password.Policy
+	hasher   password.Hasher
+	notifier notify.Notifier
+	cursors  *cursor.Signer
+	cfg      config.Config
 }
 
-// NewUsersService creates a new instance of UsersService with the given repository
-func NewUsersService(repo repository.Users) *UsersService {
-	return &UsersService{repo}
+// NewUsersService creates a new instance of UsersService with the given repositories, password policy,
+// password hasher, notifier, cursor signer and configuration
+func NewUsersService(repo repository.Users, oneTime repository.OneTimeTokens, policy *password.Policy,
+	hasher password.Hasher, notifier notify.Notifier, cursors *cursor.Signer, cfg config.Config) *UsersService {
+	return &UsersService{repo: repo, oneTime: oneTime, policy: policy, hasher: hasher, notifier: notifier, cursors: cursors, cfg: cfg}
 }
 
 // CreateUser processes the request to create a new user
@@ -31,12 +53,19 @@ func (u *UsersService) CreateUser(userReq dtos.CreateUserRequest) (dtos.CreateUs
 		return userResp, err
 	}
 
+	// Enforce the password policy before hashing
+	if err := u.policy.Check(user.Password, user.Nickname, user.Email); err != nil {
+		return userResp, err
+	}
+
 	// Hash the user's password
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	user.Password, err = u.hasher.Hash(user.Password)
 	if err != nil {
 		return userResp, err
 	}
-	user.Password = string(hash)
+
+	// Self-registered users never get elevated roles
+	user.Role = models.RoleUser
 
 	// Create the user in the repository
 	user, err = u.repo.CreateUser(user)
@@ -44,95 +73,472 @@ func (u *UsersService) CreateUser(userReq dtos.CreateUserRequest) (dtos.CreateUs
 		return userResp, err
 	}
 
+	// The account exists either way; a failed delivery can be retried by resending the link
+	if err := u.sendVerificationEmail(user); err != nil {
+		log.Errorf("[CreateUser] Unable to send verification email to user %s: %s", user.ID, err)
+	}
+
 	// Copy the created user data to response DTO
 	err = copier.Copy(&userResp, &user)
 
 	return userResp, err
 }
 
-// UpdateUser processes the request to update an existing user
-func (u *UsersService) UpdateUser(idStr string, userReq dtos.UpdateUserRequest) (dtos.UpdateUserResponse, error) {
+// GetUser retrieves a single user by ID; soft-deleted users are only returned when includeDeleted is set
+func (u *UsersService) GetUser(idStr string, includeDeleted bool) (dtos.GetUserDTO, error) {
 	// Parse user ID from string
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return dtos.UpdateUserResponse{}, err
+		return dtos.GetUserDTO{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
 	}
 
-	var userResp dtos.UpdateUserResponse
+	// Fetch the user from the repository
 	var user models.User
+	if includeDeleted {
+		user, err = u.repo.GetUserIncludingDeleted(id)
+	} else {
+		user, err = u.repo.GetUser(id)
+	}
+	if err != nil {
+		return dtos.GetUserDTO{}, err
+	}
 
-	// Copy data from request DTO to model
-	err = copier.Copy(&user, &userReq)
+	var userDTO dtos.GetUserDTO
+	// Copy user data from model to DTO
+	err = copier.Copy(&userDTO, &user)
+
+	return userDTO, err
+}
+
+// UpdateUser replaces the profile of an existing user with the request, clearing the fields it leaves empty.
+// Unless expectedVersion is 0, the update is refused with apperrors.ErrVersionMismatch if the user changed in the meantime.
+func (u *UsersService) UpdateUser(idStr string, userReq dtos.UpdateUserRequest, expectedVersion int64) (dtos.UpdateUserResponse, error) {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
 	if err != nil {
-		return userResp, err
+		return dtos.UpdateUserResponse{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
 	}
-	user.ID = id
 
-	// Update the user in the repository
-	user, err = u.repo.UpdateUser(user)
+	// Fetch the current user to tell whether the email changes
+	current, err := u.repo.GetUser(id)
 	if err != nil {
-		return userResp, err
+		return dtos.UpdateUserResponse{}, err
+	}
+
+	// Store the new profile
+	user, err := u.replaceProfile(current, userReq, expectedVersion)
+	if err != nil {
+		return dtos.UpdateUserResponse{}, err
 	}
 
+	var userResp dtos.UpdateUserResponse
 	// Copy the updated user data to response DTO
 	err = copier.Copy(&userResp, &user)
 
 	return userResp, err
 }
 
-// DeleteUser processes the request to delete a user by ID
-func (u *UsersService) DeleteUser(idStr string) error {
+// PatchUser applies a merge patch or JSON Patch document, as told by mediaType, to the profile of an existing user.
+// Unless expectedVersion is 0, the patch is refused with apperrors.ErrVersionMismatch if the user changed in the meantime;
+// otherwise a concurrent change makes the patch apply again to the newer user.
+func (u *UsersService) PatchUser(idStr string, patch []byte, mediaType string, expectedVersion int64) (dtos.UpdateUserResponse, error) {
 	// Parse user ID from string
 	id, err := uuid.Parse(idStr)
 	if err != nil {
+		return dtos.UpdateUserResponse{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		// Fetch the user the patch applies to
+		current, err := u.repo.GetUser(id)
+		if err != nil {
+			return dtos.UpdateUserResponse{}, err
+		}
+		if expectedVersion != 0 && current.Version != expectedVersion {
+			return dtos.UpdateUserResponse{}, apperrors.ErrVersionMismatch
+		}
+
+		// Patch the current profile
+		profile, err := applyProfilePatch(profileOf(current), patch, mediaType)
+		if err != nil {
+			return dtos.UpdateUserResponse{}, err
+		}
+
+		// Store the patched profile, as long as nobody changed the user since it was read
+		user, err := u.replaceProfile(current, profile, current.Version)
+		if errors.Is(err, apperrors.ErrVersionMismatch) && expectedVersion == 0 && attempt < maxPatchAttempts {
+			log.Infof("[PatchUser] User %s changed while patching, retrying", id)
+			continue
+		}
+		if err != nil {
+			return dtos.UpdateUserResponse{}, err
+		}
+
+		var userResp dtos.UpdateUserResponse
+		// Copy the updated user data to response DTO
+		err = copier.Copy(&userResp, &user)
+
+		return userResp, err
+	}
+}
+
+// replaceProfile validates profile and stores it as the new profile of current
+func (u *UsersService) replaceProfile(current models.User, profile dtos.UpdateUserRequest, expectedVersion int64) (models.User, error) {
+	// Validate the profile the user ends up with
+	if err := profile.Validate(); err != nil {
+		return models.User{}, fmt.Errorf("%w: %w", apperrors.ErrValidation, err)
+	}
+
+	var user models.User
+	// Copy data from request DTO to model
+	if err := copier.Copy(&user, &profile); err != nil {
+		return models.User{}, err
+	}
+	user.ID = current.ID
+
+	// Update the user in the repository
+	user, err := u.repo.UpdateUser(user, expectedVersion)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// A changed email is unverified again and needs a new link
+	if user.Email != current.Email && !user.EmailVerified {
+		if err := u.sendVerificationEmail(user); err != nil {
+			log.Errorf("[replaceProfile] Unable to send verification email to user %s: %s", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// profileOf returns the editable profile of user
+func profileOf(user models.User) dtos.UpdateUserRequest {
+	return dtos.UpdateUserRequest{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Email:     user.Email,
+		Country:   user.Country,
+	}
+}
+
+// SetRole assigns a role to the user with the given ID
+func (u *UsersService) SetRole(idStr string, roleReq dtos.SetRoleRequest) (dtos.GetUserDTO, error) {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return dtos.GetUserDTO{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	// Store the new role in the repository
+	user, err := u.repo.SetUserRole(id, roleReq.Role)
+	if err != nil {
+		return dtos.GetUserDTO{}, err
+	}
+
+	var userDTO dtos.GetUserDTO
+	// Copy user data from model to DTO
+	err = copier.Copy(&userDTO, &user)
+
+	return userDTO, err
+}
+
+// BootstrapAdmin makes sure the configured administrator exists. An existing account with that
+// nickname is only promoted when its password matches, so nobody can claim the role by registering first.
+func (u *UsersService) BootstrapAdmin(cfg config.BootstrapAdminConfig) error {
+	if cfg.Nickname == "" {
+		return nil
+	}
+
+	// Look the administrator up; a match on someone else's email does not count
+	user, err := u.repo.GetUserByLogin(cfg.Nickname)
+	if err == nil && user.Nickname != cfg.Nickname {
+		err = apperrors.ErrUserNotFound
+	}
+
+	switch {
+	case errors.Is(err, apperrors.ErrUserNotFound):
+		if err := u.policy.Check(cfg.Password, cfg.Nickname, cfg.Email); err != nil {
+			return err
+		}
+
+		hash, err := u.hasher.Hash(cfg.Password)
+		if err != nil {
+			return err
+		}
+
+		// The operator configured the email, so it is trusted
+		now := time.Now()
+		user, err = u.repo.CreateUser(models.User{
+			Nickname:        cfg.Nickname,
+			Email:           cfg.Email,
+			Password:        hash,
+			Role:            models.RoleAdmin,
+			EmailVerified:   true,
+			EmailVerifiedAt: &now,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to create bootstrap admin: %w", err)
+		}
+		log.Infof("[BootstrapAdmin] Created admin %s with id %s", user.Nickname, user.ID)
+	case err != nil:
 		return err
+	case user.Role == models.RoleAdmin:
+		return nil
+	default:
+		if ok, _ := u.hasher.Verify(user.Password, cfg.Password); !ok {
+			return fmt.Errorf("user %s already exists with a different password, refusing to make it admin", cfg.Nickname)
+		}
+		if _, err := u.repo.SetUserRole(user.ID, models.RoleAdmin); err != nil {
+			return err
+		}
+		log.Infof("[BootstrapAdmin] Promoted user %s with id %s to admin", user.Nickname, user.ID)
 	}
 
-	// Delete the user from the repository
-	return u.repo.DeleteUser(id)
+	return nil
 }
 
-// GetFilteredUsers retrieves users based on filter and pagination parameters
-func (u *UsersService) GetFilteredUsers(pageStr, pageSizeStr, filterStr string) (dtos.GetUserResponse, error) {
-	// Convert page number from string to integer
-	page, err := strconv.Atoi(pageStr)
+// VerifyEmail marks the email of the token's owner as verified
+func (u *UsersService) VerifyEmail(req dtos.VerifyEmailRequest) (dtos.GetUserDTO, error) {
+	token, err := u.oneTime.ConsumeOneTimeToken(models.TokenPurposeEmailVerification, auth.HashOpaqueToken(req.Token))
 	if err != nil {
-		return dtos.GetUserResponse{}, err
+		return dtos.GetUserDTO{}, err
+	}
+
+	user, err := u.repo.MarkEmailVerified(token.UserID)
+	if errors.Is(err, apperrors.ErrUserNotFound) {
+		return dtos.GetUserDTO{}, fmt.Errorf("%w: user no longer exists", apperrors.ErrInvalidToken)
+	}
+	if err != nil {
+		return dtos.GetUserDTO{}, err
+	}
+
+	var userDTO dtos.GetUserDTO
+	// Copy user data from model to DTO
+	err = copier.Copy(&userDTO, &user)
+
+	return userDTO, err
+}
+
+// ResendVerificationEmail sends a new verification link, invalidating the previous one;
+// users whose email is already verified are left alone
+func (u *UsersService) ResendVerificationEmail(idStr string) error {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	user, err := u.repo.GetUser(id)
+	if err != nil {
+		return err
 	}
-	if page < 1 {
-		page = 1
+
+	if user.EmailVerified {
+		log.Infof("[ResendVerificationEmail] Email of user %s is already verified", user.ID)
+		return nil
 	}
 
-	// Convert page size from string to integer
-	pageSize, err := strconv.Atoi(pageSizeStr)
+	return u.sendVerificationEmail(user)
+}
+
+// sendVerificationEmail delivers a link proving that the user controls their current email
+func (u *UsersService) sendVerificationEmail(user models.User) error {
+	token, err := issueOneTimeToken(u.oneTime, user.ID, models.TokenPurposeEmailVerification, u.cfg.Auth.EmailVerificationTTL)
 	if err != nil {
-		return dtos.GetUserResponse{}, err
+		return err
 	}
-	if pageSize < 10 {
-		pageSize = 10
+
+	link := tokenLink(u.cfg.Auth.EmailVerificationURL, token)
+	return u.notifier.Notify(context.Background(), notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to confirm this is your email. It expires in %s.\n\n%s\n\n"+
+			"If you did not sign up, you can ignore this message.", user.Nickname, u.cfg.Auth.EmailVerificationTTL, link),
+	})
+}
+
+// DeleteUser processes the request to delete a user by ID; the user can be restored until the retention period passes.
+// Unless expectedVersion is 0, the deletion is refused if the user changed in the meantime.
+func (u *UsersService) DeleteUser(idStr string, expectedVersion int64) error {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
 	}
 
-	// Process filter to get field and value
-	field, value := utils.ProcessFilter(filterStr)
+	// Soft-delete the user in the repository
+	return u.repo.DeleteUser(id, expectedVersion)
+}
+
+// RestoreUser undoes the deletion of the user with the given ID
+func (u *UsersService) RestoreUser(idStr string) (dtos.GetUserDTO, error) {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return dtos.GetUserDTO{}, fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
 
-	// Retrieve filtered users from the repository
-	users, totalFilteredUsers, err := u.repo.GetFilteredUsers(field, value, pageSize, pageSize*(page-1))
+	user, err := u.repo.RestoreUser(id)
+	if err != nil {
+		return dtos.GetUserDTO{}, err
+	}
+	log.Infof("[RestoreUser] Restored user %s", user.ID)
+
+	var userDTO dtos.GetUserDTO
+	// Copy user data from model to DTO
+	err = copier.Copy(&userDTO, &user)
+
+	return userDTO, err
+}
+
+// HardDeleteUser permanently removes the user with the given ID, whether deleted before or not
+func (u *UsersService) HardDeleteUser(idStr string) error {
+	// Parse user ID from string
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fmt.Errorf("%w: %s", apperrors.ErrInvalidID, err)
+	}
+
+	if err := u.repo.HardDeleteUser(id); err != nil {
+		return err
+	}
+	log.Infof("[HardDeleteUser] Permanently deleted user %s", id)
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes the users deleted longer ago than the retention period
+func (u *UsersService) PurgeDeletedUsers() (int, error) {
+	purged, err := u.repo.PurgeDeletedUsers(time.Now().Add(-u.cfg.Retention.DeletedUsers))
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		log.Infof("[PurgeDeletedUsers] Permanently deleted %d users past their retention", purged)
+	}
+
+	return purged, nil
+}
+
+// parsePageParam parses a positive page parameter, falling back to def when it is not given
+func parsePageParam(name, value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%w: %s must be a positive whole number, got %q", apperrors.ErrInvalidPagination, name, value)
+	}
+
+	return number, nil
+}
+
+// GetFilteredUsers retrieves users based on filter, sort and pagination parameters; pages are selected by number
+// or by a cursor from an earlier page. Soft-deleted users are only listed when requested
+func (u *UsersService) GetFilteredUsers(req dtos.GetUsersRequest) (dtos.GetUserResponse, error) {
+	// Page sizes default to the configured size and larger ones are capped
+	pageSize, err := parsePageParam("page_size", req.PageSize, u.cfg.Pagination.DefaultPageSize)
+	if err != nil {
+		return dtos.GetUserResponse{}, err
+	}
+	pageSize = min(pageSize, u.cfg.Pagination.MaxPageSize)
+
+	// Parse the filter; syntax errors tell the client where the filter went wrong
+	userFilter, err := filter.Parse(req.Filter)
+	if err != nil {
+		return dtos.GetUserResponse{}, fmt.Errorf("%w: %w", apperrors.ErrInvalidFilter, err)
+	}
+
+	// Parse the sort order against the allowlist of sortable fields; users are listed oldest first by default
+	sortKeys, err := parseSort(req.Sort)
 	if err != nil {
 		return dtos.GetUserResponse{}, err
 	}
+	if len(sortKeys) == 0 {
+		sortKeys = defaultSort
+	}
+
+	// One extra user tells whether there is another page in the direction of travel
+	query := models.UserQuery{Filter: userFilter, Sort: sortKeys, Limit: pageSize + 1, IncludeDeleted: req.IncludeDeleted}
+	fingerprint := cursor.Fingerprint(req.Filter, req.Sort, strconv.FormatBool(req.IncludeDeleted))
+
+	var users []models.User
+	var total, page int
+	var backward bool
+	if req.Cursor != "" {
+		if req.Page != "" {
+			return dtos.GetUserResponse{}, fmt.Errorf("%w: page cannot be combined with cursor", apperrors.ErrInvalidPagination)
+		}
+
+		seek, err := u.decodeCursor(req.Cursor, sortKeys, fingerprint)
+		if err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+		backward = seek.Backward
+
+		// Seek from the boundary user so concurrent changes do not shift the page
+		users, total, err = u.repo.SeekFilteredUsers(query, seek)
+		if err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+	} else {
+		// Pages are numbered from 1, which is also the default
+		page, err = parsePageParam("page", req.Page, 1)
+		if err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+		if page-1 > math.MaxInt/pageSize {
+			return dtos.GetUserResponse{}, fmt.Errorf("%w: page is too large", apperrors.ErrInvalidPagination)
+		}
 
-	var userDTOs []dtos.GetUserDTO
+		query.Offset = pageSize * (page - 1)
+		users, total, err = u.repo.GetFilteredUsers(query)
+		if err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+	}
+
+	// Drop the extra user, which is the first one when going backward
+	hasMore := len(users) > pageSize
+	if hasMore {
+		if backward {
+			users = users[1:]
+		} else {
+			users = users[:pageSize]
+		}
+	}
+
+	// Link the neighbouring pages; the first page has no previous one and the last page no next one
+	hasNext := len(users) > 0 && (hasMore || backward)
+	hasPrev := len(users) > 0 && ((hasMore && backward) || (!backward && (req.Cursor != "" || page > 1)))
+
+	resp := dtos.GetUserResponse{
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: (total + pageSize - 1) / pageSize,
+		HasNext:    hasNext,
+	}
 	// Copy user data from model to DTOs
-	err = copier.Copy(&userDTOs, users)
+	err = copier.Copy(&resp.Users, users)
 	if err != nil {
 		return dtos.GetUserResponse{}, err
 	}
 
+	if hasNext {
+		if resp.NextCursor, err = u.encodeCursor(users[len(users)-1], sortKeys, fingerprint, false); err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+	}
+	if hasPrev {
+		if resp.PrevCursor, err = u.encodeCursor(users[0], sortKeys, fingerprint, true); err != nil {
+			return dtos.GetUserResponse{}, err
+		}
+	}
+
 	// Return the paginated and filtered user data
-	return dtos.GetUserResponse{
-		Page:     page,
-		PageSize: pageSize,
-		Total:    totalFilteredUsers,
-		Users:    userDTOs,
-	}, nil
+	return resp, nil
 }
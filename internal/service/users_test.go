@@ -2,19 +2,47 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/sosshik/users-service/internal/apperrors"
+	"github.com/sosshik/users-service/internal/config"
+	"github.com/sosshik/users-service/internal/cursor"
+	"github.com/sosshik/users-service/internal/filter"
 	"github.com/sosshik/users-service/internal/models"
+	"github.com/sosshik/users-service/internal/notify"
+	"github.com/sosshik/users-service/internal/repository/inmemory"
 	mocks "github.com/sosshik/users-service/internal/repository/mock"
 	"github.com/sosshik/users-service/pkg/dtos"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
 
+// newTestUsersService creates a UsersService backed by mockRepo, in-memory one-time tokens and a recording notifier
+func newTestUsersService(t *testing.T, mockRepo *mocks.MockUserRepository, cfg config.Config) *UsersService {
+	t.Helper()
+
+	return NewUsersService(mockRepo, inmemory.NewOneTimeTokenStorage(), newTestPolicy(t, cfg), newTestHasher(t, cfg),
+		&recordingNotifier{}, newTestCursors(t, cfg), cfg)
+}
+
+// newTestCursors creates the cursor signer described by cfg
+func newTestCursors(t *testing.T, cfg config.Config) *cursor.Signer {
+	t.Helper()
+
+	cursors, err := cursor.NewSigner(cfg.Pagination.CursorSecret)
+	require.NoError(t, err)
+	return cursors
+}
+
 func TestCreateUser(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := NewUsersService(mockRepo)
+	userService := newTestUsersService(t, mockRepo, config.Default())
 
 	testCases := []struct {
 		name         string
@@ -36,12 +64,25 @@ func TestCreateUser(t *testing.T) {
 			},
 			expectedErr: nil,
 			setupMock: func() {
-				mockRepo.On("CreateUser", mock.Anything).Return(models.User{
+				mockRepo.On("CreateUser", mock.MatchedBy(func(user models.User) bool {
+					return user.Role == models.RoleUser
+				})).Return(models.User{
 					Nickname: "newuser",
 					Email:    "new@example.com",
 				}, nil).Once()
 			},
 		},
+		{
+			name: "Weak password",
+			userReq: dtos.CreateUserRequest{
+				Nickname: "newuser",
+				Email:    "new@example.com",
+				Password: "short",
+			},
+			expectedResp: dtos.CreateUserResponse{},
+			expectedErr:  fmt.Errorf("%w: password must be at least 8 characters long", apperrors.ErrWeakPassword),
+			setupMock:    func() {},
+		},
 		{
 			name: "Repository error",
 			userReq: dtos.CreateUserRequest{
@@ -70,37 +111,166 @@ func TestCreateUser(t *testing.T) {
 	}
 }
 
+func TestGetUser(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	id := uuid.New()
+
+	deletedAt := time.Now()
+
+	testCases := []struct {
+		name           string
+		idStr          string
+		includeDeleted bool
+		expectedResp   dtos.GetUserDTO
+		expectedErr    error
+		setupMock      func()
+	}{
+		{
+			name:  "Success",
+			idStr: id.String(),
+			expectedResp: dtos.GetUserDTO{
+				ID:       id,
+				Nickname: "existinguser",
+				Email:    "existing@example.com",
+			},
+			expectedErr: nil,
+			setupMock: func() {
+				mockRepo.On("GetUser", id).Return(models.User{
+					ID:       id,
+					Nickname: "existinguser",
+					Email:    "existing@example.com",
+					Password: "hash",
+				}, nil).Once()
+			},
+		},
+		{
+			name:         "Invalid UUID",
+			idStr:        "invalid-uuid",
+			expectedResp: dtos.GetUserDTO{},
+			expectedErr:  apperrors.ErrInvalidID,
+			setupMock:    func() {},
+		},
+		{
+			name:         "User not found",
+			idStr:        id.String(),
+			expectedResp: dtos.GetUserDTO{},
+			expectedErr:  apperrors.ErrUserNotFound,
+			setupMock: func() {
+				mockRepo.On("GetUser", id).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
+		{
+			name:           "Deleted user when included",
+			idStr:          id.String(),
+			includeDeleted: true,
+			expectedResp: dtos.GetUserDTO{
+				ID:        id,
+				Nickname:  "deleteduser",
+				DeletedAt: &deletedAt,
+			},
+			expectedErr: nil,
+			setupMock: func() {
+				mockRepo.On("GetUserIncludingDeleted", id).Return(models.User{
+					ID:        id,
+					Nickname:  "deleteduser",
+					DeletedAt: &deletedAt,
+				}, nil).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			userResp, err := userService.GetUser(tc.idStr, tc.includeDeleted)
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedResp, userResp)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
 func TestUpdateUser(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := NewUsersService(mockRepo)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	current := models.User{
+		ID:        uuid.New(),
+		FirstName: "Current",
+		LastName:  "User",
+		Nickname:  "currentuser",
+		Email:     "current@example.com",
+		Country:   "Country",
+		Version:   3,
+	}
 
 	testCases := []struct {
-		name         string
-		idStr        string
-		userReq      dtos.UpdateUserRequest
-		expectedResp dtos.UpdateUserResponse
-		expectedErr  error
-		setupMock    func()
+		name            string
+		idStr           string
+		userReq         dtos.UpdateUserRequest
+		expectedVersion int64
+		expectedResp    dtos.UpdateUserResponse
+		expectedErr     error
+		setupMock       func()
 	}{
 		{
 			name:  "Success",
-			idStr: uuid.New().String(),
+			idStr: current.ID.String(),
 			userReq: dtos.UpdateUserRequest{
 				Nickname: "updateduser",
-				Email:    "updated@example.com",
+				Email:    "current@example.com",
 			},
+			expectedVersion: 3,
 			expectedResp: dtos.UpdateUserResponse{
 				Nickname: "updateduser",
-				Email:    "updated@example.com",
+				Email:    "current@example.com",
+				Version:  4,
 			},
 			expectedErr: nil,
 			setupMock: func() {
-				mockRepo.On("UpdateUser", mock.Anything).Return(models.User{
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+				// Fields missing from the request are cleared
+				mockRepo.On("UpdateUser", models.User{
+					ID:       current.ID,
+					Nickname: "updateduser",
+					Email:    "current@example.com",
+				}, int64(3)).Return(models.User{
 					Nickname: "updateduser",
-					Email:    "updated@example.com",
+					Email:    "current@example.com",
+					Version:  4,
 				}, nil).Once()
 			},
 		},
+		{
+			name:  "Version mismatch",
+			idStr: current.ID.String(),
+			userReq: dtos.UpdateUserRequest{
+				Nickname: "currentuser",
+				Email:    "current@example.com",
+				Country:  "Wonderland",
+			},
+			expectedVersion: 2,
+			expectedResp:    dtos.UpdateUserResponse{},
+			expectedErr:     apperrors.ErrVersionMismatch,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+				mockRepo.On("UpdateUser", mock.Anything, int64(2)).Return(models.User{}, apperrors.ErrVersionMismatch).Once()
+			},
+		},
+		{
+			name:         "Cleared nickname",
+			idStr:        current.ID.String(),
+			userReq:      dtos.UpdateUserRequest{Email: "current@example.com"},
+			expectedResp: dtos.UpdateUserResponse{},
+			expectedErr:  errors.New("validation failed: nickname: cannot be blank."),
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
 		{
 			name:  "Invalid UUID",
 			idStr: "invalid-uuid",
@@ -109,12 +279,25 @@ func TestUpdateUser(t *testing.T) {
 				Email:    "updated@example.com",
 			},
 			expectedResp: dtos.UpdateUserResponse{},
-			expectedErr:  errors.New("invalid UUID length: 12"),
+			expectedErr:  errors.New("invalid user id: invalid UUID length: 12"),
 			setupMock:    func() {},
 		},
+		{
+			name:  "User not found",
+			idStr: current.ID.String(),
+			userReq: dtos.UpdateUserRequest{
+				Nickname: "updateduser",
+				Email:    "updated@example.com",
+			},
+			expectedResp: dtos.UpdateUserResponse{},
+			expectedErr:  apperrors.ErrUserNotFound,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(models.User{}, apperrors.ErrUserNotFound).Once()
+			},
+		},
 		{
 			name:  "Repository error",
-			idStr: uuid.New().String(),
+			idStr: current.ID.String(),
 			userReq: dtos.UpdateUserRequest{
 				Nickname: "updateduser",
 				Email:    "updated@example.com",
@@ -122,7 +305,8 @@ func TestUpdateUser(t *testing.T) {
 			expectedResp: dtos.UpdateUserResponse{},
 			expectedErr:  errors.New("repository error"),
 			setupMock: func() {
-				mockRepo.On("UpdateUser", mock.Anything).Return(models.User{}, errors.New("repository error")).Once()
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+				mockRepo.On("UpdateUser", mock.Anything, int64(0)).Return(models.User{}, errors.New("repository error")).Once()
 			},
 		},
 	}
@@ -131,7 +315,7 @@ func TestUpdateUser(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.setupMock()
 
-			userResp, err := userService.UpdateUser(tc.idStr, tc.userReq)
+			userResp, err := userService.UpdateUser(tc.idStr, tc.userReq, tc.expectedVersion)
 
 			if tc.expectedErr != nil {
 				if assert.Error(t, err) {
@@ -147,9 +331,141 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
+func TestPatchUser(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	current := models.User{
+		ID:        uuid.New(),
+		FirstName: "Current",
+		LastName:  "User",
+		Nickname:  "currentuser",
+		Email:     "current@example.com",
+		Country:   "Country",
+		Version:   3,
+	}
+	// withProfile returns current with the given profile fields changed
+	withProfile := func(change func(user *models.User)) models.User {
+		user := models.User{
+			ID:        current.ID,
+			FirstName: current.FirstName,
+			LastName:  current.LastName,
+			Nickname:  current.Nickname,
+			Email:     current.Email,
+			Country:   current.Country,
+		}
+		change(&user)
+		return user
+	}
+
+	testCases := []struct {
+		name            string
+		patch           string
+		mediaType       string
+		expectedVersion int64
+		expectedErr     error
+		setupMock       func()
+	}{
+		{
+			name:      "Merge patch changes and clears fields",
+			patch:     `{"country": "Wonderland", "last_name": null}`,
+			mediaType: dtos.MergePatchMediaType,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+				mockRepo.On("UpdateUser", withProfile(func(user *models.User) {
+					user.Country = "Wonderland"
+					user.LastName = ""
+				}), int64(3)).Return(current, nil).Once()
+			},
+		},
+		{
+			name:      "JSON Patch",
+			patch:     `[{"op": "test", "path": "/nickname", "value": "currentuser"}, {"op": "remove", "path": "/first_name"}]`,
+			mediaType: dtos.JSONPatchMediaType,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+				mockRepo.On("UpdateUser", withProfile(func(user *models.User) {
+					user.FirstName = ""
+				}), int64(3)).Return(current, nil).Once()
+			},
+		},
+		{
+			name:      "Concurrent change is retried without If-Match",
+			patch:     `{"country": "Wonderland"}`,
+			mediaType: dtos.MergePatchMediaType,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Twice()
+				mockRepo.On("UpdateUser", mock.Anything, int64(3)).Return(models.User{}, apperrors.ErrVersionMismatch).Once()
+				mockRepo.On("UpdateUser", mock.Anything, int64(3)).Return(current, nil).Once()
+			},
+		},
+		{
+			name:            "Stale If-Match",
+			patch:           `{"country": "Wonderland"}`,
+			mediaType:       dtos.MergePatchMediaType,
+			expectedVersion: 2,
+			expectedErr:     apperrors.ErrVersionMismatch,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
+		{
+			name:        "Clearing the email is refused",
+			patch:       `{"email": null}`,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrValidation,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
+		{
+			name:        "Read-only field",
+			patch:       `{"role": "admin"}`,
+			mediaType:   dtos.MergePatchMediaType,
+			expectedErr: apperrors.ErrInvalidPayload,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
+		{
+			name:        "Failed JSON Patch test",
+			patch:       `[{"op": "test", "path": "/nickname", "value": "someoneelse"}]`,
+			mediaType:   dtos.JSONPatchMediaType,
+			expectedErr: apperrors.ErrPatchConflict,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
+		{
+			name:        "Unsupported media type",
+			patch:       `country=Wonderland`,
+			mediaType:   "application/x-www-form-urlencoded",
+			expectedErr: apperrors.ErrUnsupportedMediaType,
+			setupMock: func() {
+				mockRepo.On("GetUser", current.ID).Return(current, nil).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			_, err := userService.PatchUser(current.ID.String(), []byte(tc.patch), tc.mediaType, tc.expectedVersion)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := NewUsersService(mockRepo)
+	userService := newTestUsersService(t, mockRepo, config.Default())
 
 	testCases := []struct {
 		name        string
@@ -162,7 +478,7 @@ func TestDeleteUser(t *testing.T) {
 			idStr:       uuid.New().String(),
 			expectedErr: nil,
 			setupMock: func() {
-				mockRepo.On("DeleteUser", mock.Anything).Return(nil).Once()
+				mockRepo.On("DeleteUser", mock.Anything, int64(0)).Return(nil).Once()
 			},
 		},
 		{
@@ -170,7 +486,7 @@ func TestDeleteUser(t *testing.T) {
 			idStr:       uuid.New().String(),
 			expectedErr: errors.New("repository error"),
 			setupMock: func() {
-				mockRepo.On("DeleteUser", mock.Anything).Return(errors.New("repository error")).Once()
+				mockRepo.On("DeleteUser", mock.Anything, int64(0)).Return(errors.New("repository error")).Once()
 			},
 		},
 	}
@@ -179,7 +495,7 @@ func TestDeleteUser(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.setupMock()
 
-			err := userService.DeleteUser(tc.idStr)
+			err := userService.DeleteUser(tc.idStr, 0)
 
 			assert.Equal(t, tc.expectedErr, err)
 			mockRepo.AssertExpectations(t)
@@ -187,9 +503,185 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestRestoreUser(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	id := uuid.New()
+
+	testCases := []struct {
+		name         string
+		idStr        string
+		expectedResp dtos.GetUserDTO
+		expectedErr  error
+		setupMock    func()
+	}{
+		{
+			name:         "Success",
+			idStr:        id.String(),
+			expectedResp: dtos.GetUserDTO{ID: id, Nickname: "restored"},
+			setupMock: func() {
+				mockRepo.On("RestoreUser", id).Return(models.User{ID: id, Nickname: "restored", Password: "hash"}, nil).Once()
+			},
+		},
+		{
+			name:        "Invalid UUID",
+			idStr:       "invalid-uuid",
+			expectedErr: apperrors.ErrInvalidID,
+			setupMock:   func() {},
+		},
+		{
+			name:        "User not deleted",
+			idStr:       id.String(),
+			expectedErr: apperrors.ErrUserNotDeleted,
+			setupMock: func() {
+				mockRepo.On("RestoreUser", id).Return(models.User{}, apperrors.ErrUserNotDeleted).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setupMock()
+
+			userResp, err := userService.RestoreUser(tc.idStr)
+
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedResp, userResp)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHardDeleteUser(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	id := uuid.New()
+	mockRepo.On("HardDeleteUser", id).Return(nil).Once()
+	assert.NoError(t, userService.HardDeleteUser(id.String()))
+
+	mockRepo.On("HardDeleteUser", id).Return(apperrors.ErrUserNotFound).Once()
+	assert.ErrorIs(t, userService.HardDeleteUser(id.String()), apperrors.ErrUserNotFound)
+
+	assert.ErrorIs(t, userService.HardDeleteUser("invalid-uuid"), apperrors.ErrInvalidID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	cfg := config.Default()
+	cfg.Retention.DeletedUsers = 24 * time.Hour
+	userService := newTestUsersService(t, mockRepo, cfg)
+
+	// Users deleted more than the retention period ago are purged
+	before := time.Now().Add(-cfg.Retention.DeletedUsers)
+	mockRepo.On("PurgeDeletedUsers", mock.MatchedBy(func(cutoff time.Time) bool {
+		return !cutoff.Before(before) && cutoff.Before(time.Now().Add(-cfg.Retention.DeletedUsers+time.Second))
+	})).Return(3, nil).Once()
+
+	purged, err := userService.PurgeDeletedUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetRole(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := newTestUsersService(t, mockRepo, config.Default())
+
+	id := uuid.New()
+	mockRepo.On("SetUserRole", id, "support").Return(models.User{ID: id, Nickname: "alice", Role: "support"}, nil).Once()
+
+	userResp, err := userService.SetRole(id.String(), dtos.SetRoleRequest{Role: "support"})
+	assert.NoError(t, err)
+	assert.Equal(t, dtos.GetUserDTO{ID: id, Nickname: "alice", Role: "support"}, userResp)
+
+	_, err = userService.SetRole("invalid-uuid", dtos.SetRoleRequest{Role: "support"})
+	assert.ErrorIs(t, err, apperrors.ErrInvalidID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	admin := config.BootstrapAdminConfig{Nickname: "root", Email: "root@example.com", Password: "s3cret-pass"}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(admin.Password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	existingID := uuid.New()
+
+	testCases := []struct {
+		name        string
+		expectedErr string
+		setupMock   func(mockRepo *mocks.MockUserRepository)
+	}{
+		{
+			name: "Creates missing admin",
+			setupMock: func(mockRepo *mocks.MockUserRepository) {
+				mockRepo.On("GetUserByLogin", "root").Return(models.User{}, apperrors.ErrUserNotFound).Once()
+				mockRepo.On("CreateUser", mock.MatchedBy(func(user models.User) bool {
+					return user.Nickname == "root" && user.Email == "root@example.com" && user.Role == models.RoleAdmin && user.EmailVerified &&
+						bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(admin.Password)) == nil
+				})).Return(models.User{ID: existingID, Nickname: "root"}, nil).Once()
+			},
+		},
+		{
+			name: "Ignores a user whose email equals the nickname",
+			setupMock: func(mockRepo *mocks.MockUserRepository) {
+				mockRepo.On("GetUserByLogin", "root").Return(models.User{Nickname: "mallory", Email: "root"}, nil).Once()
+				mockRepo.On("CreateUser", mock.Anything).Return(models.User{ID: existingID, Nickname: "root"}, nil).Once()
+			},
+		},
+		{
+			name: "Keeps existing admin",
+			setupMock: func(mockRepo *mocks.MockUserRepository) {
+				mockRepo.On("GetUserByLogin", "root").Return(models.User{Nickname: "root", Role: models.RoleAdmin}, nil).Once()
+			},
+		},
+		{
+			name: "Promotes existing user with matching password",
+			setupMock: func(mockRepo *mocks.MockUserRepository) {
+				mockRepo.On("GetUserByLogin", "root").Return(models.User{ID: existingID, Nickname: "root", Password: string(hash), Role: models.RoleUser}, nil).Once()
+				mockRepo.On("SetUserRole", existingID, models.RoleAdmin).Return(models.User{ID: existingID, Role: models.RoleAdmin}, nil).Once()
+			},
+		},
+		{
+			name:        "Refuses existing user with other password",
+			expectedErr: "user root already exists with a different password, refusing to make it admin",
+			setupMock: func(mockRepo *mocks.MockUserRepository) {
+				mockRepo.On("GetUserByLogin", "root").Return(models.User{ID: existingID, Nickname: "root", Password: "other-hash", Role: models.RoleUser}, nil).Once()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockUserRepository)
+			userService := newTestUsersService(t, mockRepo, cfg)
+			tc.setupMock(mockRepo)
+
+			err := userService.BootstrapAdmin(admin)
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+
+	// Nothing happens without a configured admin
+	assert.NoError(t, newTestUsersService(t, new(mocks.MockUserRepository), cfg).BootstrapAdmin(config.BootstrapAdminConfig{}))
+}
+
 func TestGetFilteredUsers(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	service := &UsersService{repo: mockRepo}
+	service := newTestUsersService(t, mockRepo, config.Default())
 
 	fixedCreatedAt := time.Date(2024, time.August, 14, 20, 32, 0, 0, time.Local)
 	fixedUpdatedAt := time.Date(2024, time.August, 14, 20, 32, 0, 0, time.Local)
@@ -247,10 +739,10 @@ func TestGetFilteredUsers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockRepo.On("GetFilteredUsers", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
+			mockRepo.On("GetFilteredUsers", mock.AnythingOfType("models.UserQuery")).
 				Return(tt.mockReturn, tt.mockTotalCount, tt.mockErr)
 
-			got, err := service.GetFilteredUsers(tt.pageStr, tt.pageSizeStr, tt.filterStr)
+			got, err := service.GetFilteredUsers(dtos.GetUsersRequest{Page: tt.pageStr, PageSize: tt.pageSizeStr, Filter: tt.filterStr})
 
 			assert.Equal(t, tt.expectedErr, err)
 
@@ -268,3 +760,316 @@ func TestGetFilteredUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFilteredUsersPagination(t *testing.T) {
+	cfg := config.Default()
+	cfg.Pagination.DefaultPageSize = 20
+	cfg.Pagination.MaxPageSize = 50
+
+	users := func(n int) []models.User {
+		result := make([]models.User, n)
+		for i := range result {
+			result[i] = models.User{ID: uuid.New(), Nickname: fmt.Sprintf("user%d", i)}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name           string
+		pageStr        string
+		pageSizeStr    string
+		expectedLimit  int
+		expectedOffset int
+		mockReturn     []models.User
+		mockTotalCount int
+		expected       dtos.GetUserResponse
+		expectedErr    string
+	}{
+		{
+			name:           "Defaults",
+			expectedLimit:  21,
+			mockReturn:     users(21),
+			mockTotalCount: 45,
+			expected:       dtos.GetUserResponse{Page: 1, PageSize: 20, Total: 45, TotalPages: 3, HasNext: true},
+		},
+		{
+			name:           "Last page",
+			pageStr:        "3",
+			expectedLimit:  21,
+			expectedOffset: 40,
+			mockReturn:     users(5),
+			mockTotalCount: 45,
+			expected:       dtos.GetUserResponse{Page: 3, PageSize: 20, Total: 45, TotalPages: 3},
+		},
+		{
+			name:           "Page size capped at the maximum",
+			pageStr:        "2",
+			pageSizeStr:    "1000000",
+			expectedLimit:  51,
+			expectedOffset: 50,
+			mockReturn:     users(0),
+			mockTotalCount: 50,
+			expected:       dtos.GetUserResponse{Page: 2, PageSize: 50, Total: 50, TotalPages: 1},
+		},
+		{
+			name:           "No users",
+			pageSizeStr:    "5",
+			expectedLimit:  6,
+			mockReturn:     users(0),
+			mockTotalCount: 0,
+			expected:       dtos.GetUserResponse{Page: 1, PageSize: 5, Total: 0, TotalPages: 0},
+		},
+		{
+			name:        "Page is not a number",
+			pageStr:     "first",
+			expectedErr: `invalid pagination parameters: page must be a positive whole number, got "first"`,
+		},
+		{
+			name:        "Page size is not a number",
+			pageSizeStr: "10.5",
+			expectedErr: `invalid pagination parameters: page_size must be a positive whole number, got "10.5"`,
+		},
+		{
+			name:        "Page zero",
+			pageStr:     "0",
+			expectedErr: `invalid pagination parameters: page must be a positive whole number, got "0"`,
+		},
+		{
+			name:        "Negative page size",
+			pageSizeStr: "-1",
+			expectedErr: `invalid pagination parameters: page_size must be a positive whole number, got "-1"`,
+		},
+		{
+			name:        "Offset beyond the range of integers",
+			pageStr:     "9223372036854775807",
+			expectedErr: "invalid pagination parameters: page is too large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockUserRepository)
+			service := newTestUsersService(t, mockRepo, cfg)
+			if tt.expectedErr == "" {
+				query := models.UserQuery{Sort: defaultSort, Limit: tt.expectedLimit, Offset: tt.expectedOffset}
+				mockRepo.On("GetFilteredUsers", query).Return(tt.mockReturn, tt.mockTotalCount, nil).Once()
+			}
+
+			got, err := service.GetFilteredUsers(dtos.GetUsersRequest{Page: tt.pageStr, PageSize: tt.pageSizeStr})
+			if tt.expectedErr != "" {
+				assert.ErrorIs(t, err, apperrors.ErrInvalidPagination)
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected.Page, got.Page)
+			assert.Equal(t, tt.expected.PageSize, got.PageSize)
+			assert.Equal(t, tt.expected.Total, got.Total)
+			assert.Equal(t, tt.expected.TotalPages, got.TotalPages)
+			assert.Equal(t, tt.expected.HasNext, got.HasNext)
+			assert.Equal(t, tt.expected.HasNext, got.NextCursor != "")
+			assert.LessOrEqual(t, len(got.Users), got.PageSize)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// linkToken extracts the token from the link in a notification sent for template
+func linkToken(t *testing.T, msg notify.Message, template string) string {
+	t.Helper()
+
+	prefix, _, _ := strings.Cut(template, config.TokenPlaceholder)
+	_, rest, found := strings.Cut(msg.Body, prefix)
+	require.True(t, found, "no link in %q", msg.Body)
+	token, err := url.QueryUnescape(strings.Fields(rest)[0])
+	require.NoError(t, err)
+	return token
+}
+
+func TestEmailVerification(t *testing.T) {
+	cfg := config.Default()
+	cfg.Password.BcryptCost = bcrypt.MinCost
+	mockRepo := new(mocks.MockUserRepository)
+	notifier := &recordingNotifier{}
+	userService := NewUsersService(mockRepo, inmemory.NewOneTimeTokenStorage(), newTestPolicy(t, cfg), newTestHasher(t, cfg), notifier, newTestCursors(t, cfg), cfg)
+
+	user := models.User{ID: uuid.New(), Nickname: "alice", Email: "alice@example.com"}
+
+	// Registration sends a verification link
+	mockRepo.On("CreateUser", mock.Anything).Return(user, nil).Once()
+	created, err := userService.CreateUser(dtos.CreateUserRequest{Nickname: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, created.EmailVerified)
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, "alice@example.com", notifier.messages[0].To)
+	firstToken := linkToken(t, notifier.messages[0], cfg.Auth.EmailVerificationURL)
+
+	// Changing the email sends a new link and invalidates the old one
+	changed := user
+	changed.Email = "alice2@example.com"
+	mockRepo.On("GetUser", user.ID).Return(user, nil).Once()
+	mockRepo.On("UpdateUser", mock.Anything, int64(0)).Return(changed, nil).Once()
+	_, err = userService.UpdateUser(user.ID.String(), dtos.UpdateUserRequest{Nickname: "alice", Email: "alice2@example.com"}, 0)
+	require.NoError(t, err)
+	require.Len(t, notifier.messages, 2)
+
+	// Keeping the unverified email does not send another link
+	mockRepo.On("GetUser", user.ID).Return(changed, nil).Once()
+	mockRepo.On("UpdateUser", mock.Anything, int64(0)).Return(changed, nil).Once()
+	_, err = userService.UpdateUser(user.ID.String(), dtos.UpdateUserRequest{Nickname: "alice", Email: "alice2@example.com", Country: "Wonderland"}, 0)
+	require.NoError(t, err)
+	require.Len(t, notifier.messages, 2)
+	assert.Equal(t, "alice2@example.com", notifier.messages[1].To)
+	secondToken := linkToken(t, notifier.messages[1], cfg.Auth.EmailVerificationURL)
+
+	_, err = userService.VerifyEmail(dtos.VerifyEmailRequest{Token: firstToken})
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+
+	now := time.Now()
+	verified := changed
+	verified.EmailVerified = true
+	verified.EmailVerifiedAt = &now
+	mockRepo.On("MarkEmailVerified", user.ID).Return(verified, nil).Once()
+	resp, err := userService.VerifyEmail(dtos.VerifyEmailRequest{Token: secondToken})
+	require.NoError(t, err)
+	assert.True(t, resp.EmailVerified)
+	assert.Equal(t, &now, resp.EmailVerifiedAt)
+
+	// Tokens work once
+	_, err = userService.VerifyEmail(dtos.VerifyEmailRequest{Token: secondToken})
+	assert.ErrorIs(t, err, apperrors.ErrInvalidToken)
+
+	// Resending skips verified emails
+	mockRepo.On("GetUser", user.ID).Return(verified, nil).Once()
+	require.NoError(t, userService.ResendVerificationEmail(user.ID.String()))
+	assert.Len(t, notifier.messages, 2)
+
+	mockRepo.On("GetUser", user.ID).Return(changed, nil).Once()
+	require.NoError(t, userService.ResendVerificationEmail(user.ID.String()))
+	assert.Len(t, notifier.messages, 3)
+
+	assert.ErrorIs(t, userService.ResendVerificationEmail("invalid-uuid"), apperrors.ErrInvalidID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetFilteredUsersFilter(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	service := newTestUsersService(t, mockRepo, config.Default())
+
+	// The filter reaches the repository parsed
+	query := models.UserQuery{
+		Filter: &filter.Condition{Field: "email_verified", Kind: filter.Bool, Op: filter.Eq, Bool: true},
+		Sort:   defaultSort,
+		Limit:  11,
+	}
+	mockRepo.On("GetFilteredUsers", query).Return([]models.User{}, 0, nil).Once()
+	_, err := service.GetFilteredUsers(dtos.GetUsersRequest{Page: "1", PageSize: "10", Filter: "email_verified=1"})
+	assert.NoError(t, err)
+
+	// Invalid filters are rejected with the position of the problem
+	for _, invalid := range []string{"email_verified=yes", "password=secret", "nickname eq a AND"} {
+		_, err = service.GetFilteredUsers(dtos.GetUsersRequest{Page: "1", PageSize: "10", Filter: invalid})
+		assert.ErrorIs(t, err, apperrors.ErrInvalidFilter, invalid)
+
+		var syntaxErr *filter.SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, invalid)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetFilteredUsersSort(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	service := newTestUsersService(t, mockRepo, config.Default())
+
+	// The sort order reaches the repository parsed
+	query := models.UserQuery{
+		Sort:  []models.SortKey{{Field: "created_at", Desc: true}, {Field: "nickname"}},
+		Limit: 11,
+	}
+	mockRepo.On("GetFilteredUsers", query).Return([]models.User{}, 0, nil).Once()
+	_, err := service.GetFilteredUsers(dtos.GetUsersRequest{Page: "1", PageSize: "10", Sort: "-created_at,nickname"})
+	assert.NoError(t, err)
+
+	_, err = service.GetFilteredUsers(dtos.GetUsersRequest{Page: "1", PageSize: "10", Sort: "password"})
+	assert.ErrorIs(t, err, apperrors.ErrInvalidSort)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetFilteredUsersCursors(t *testing.T) {
+	cfg := config.Default()
+	storage := inmemory.NewInMemory()
+	userService := NewUsersService(storage, inmemory.NewOneTimeTokenStorage(), newTestPolicy(t, cfg), newTestHasher(t, cfg),
+		&recordingNotifier{}, newTestCursors(t, cfg), cfg)
+
+	for _, name := range []string{"erin", "bob", "dave", "alice", "carol"} {
+		_, err := storage.CreateUser(models.User{Nickname: name, Email: name + "@example.com"})
+		require.NoError(t, err)
+	}
+	nicknamesOf := func(resp dtos.GetUserResponse) []string {
+		var names []string
+		for _, user := range resp.Users {
+			names = append(names, user.Nickname)
+		}
+		return names
+	}
+	req := dtos.GetUsersRequest{PageSize: "2", Sort: "nickname"}
+
+	// The first page is requested by number and links only forward
+	first, err := userService.GetFilteredUsers(dtos.GetUsersRequest{Page: "1", PageSize: "2", Sort: "nickname"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, nicknamesOf(first))
+	assert.Equal(t, 1, first.Page)
+	assert.Empty(t, first.PrevCursor)
+	require.NotEmpty(t, first.NextCursor)
+
+	// Cursors walk forward to the last page, which links only backward
+	req.Cursor = first.NextCursor
+	second, err := userService.GetFilteredUsers(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol", "dave"}, nicknamesOf(second))
+	assert.Zero(t, second.Page)
+	assert.Equal(t, 5, second.Total)
+
+	req.Cursor = second.NextCursor
+	last, err := userService.GetFilteredUsers(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"erin"}, nicknamesOf(last))
+	assert.Empty(t, last.NextCursor)
+
+	// And back again to the first page
+	req.Cursor = last.PrevCursor
+	back, err := userService.GetFilteredUsers(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol", "dave"}, nicknamesOf(back))
+	assert.NotEmpty(t, back.NextCursor)
+
+	req.Cursor = back.PrevCursor
+	back, err = userService.GetFilteredUsers(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, nicknamesOf(back))
+	assert.Empty(t, back.PrevCursor)
+	assert.Equal(t, first.NextCursor, back.NextCursor)
+
+	// Cursors only work with the query they were issued for
+	tests := []struct {
+		name        string
+		req         dtos.GetUsersRequest
+		expectedErr error
+	}{
+		{"Other sort", dtos.GetUsersRequest{PageSize: "2", Sort: "-nickname", Cursor: first.NextCursor}, apperrors.ErrInvalidCursor},
+		{"Other filter", dtos.GetUsersRequest{PageSize: "2", Sort: "nickname", Filter: "nickname=a", Cursor: first.NextCursor}, apperrors.ErrInvalidCursor},
+		{"Tampered", dtos.GetUsersRequest{PageSize: "2", Sort: "nickname", Cursor: "x" + first.NextCursor}, apperrors.ErrInvalidCursor},
+		{"Page and cursor", dtos.GetUsersRequest{Page: "2", PageSize: "2", Sort: "nickname", Cursor: first.NextCursor}, apperrors.ErrInvalidPagination},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := userService.GetFilteredUsers(tt.req)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
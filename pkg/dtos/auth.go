@@ -0,0 +1,78 @@
+package dtos
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type LoginRequest struct {
+	// Login is the user's nickname or email
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+func (r *LoginRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Login, validation.Required),
+		validation.Field(&r.Password, validation.Required))
+}
+
+type LoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the access token lifetime in seconds
+	ExpiresIn int `json:"expires_in"`
+	// RefreshToken can be exchanged once for a new token pair
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshTokenRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.RefreshToken, validation.Required))
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.CurrentPassword, validation.Required),
+		validation.Field(&r.NewPassword, validation.Required))
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *PasswordResetRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Email, validation.Required, is.Email))
+}
+
+type PasswordResetConfirmRequest struct {
+	// Token is the reset token from the link sent to the user
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (r *PasswordResetConfirmRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Token, validation.Required),
+		validation.Field(&r.NewPassword, validation.Required))
+}
+
+type VerifyEmailRequest struct {
+	// Token is the verification token from the link sent to the user
+	Token string `json:"token"`
+}
+
+func (r *VerifyEmailRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Token, validation.Required))
+}
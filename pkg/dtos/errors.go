@@ -0,0 +1,10 @@
+package dtos
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	// Errors maps every invalid request field to why it was rejected; only set with code validation_failed
+	Errors FieldErrors `json:"errors,omitempty"`
+	// Position is the 1-based character position of a syntax error in a filter; only set with code invalid_filter
+	Position int `json:"position,omitempty"`
+}
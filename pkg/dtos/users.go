@@ -1,12 +1,16 @@
 package dtos
 
 import (
-	validation "github.com/go-ozzo/ozzo-validation"
-	"github.com/go-ozzo/ozzo-validation/is"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/google/uuid"
+	"regexp"
 	"time"
 )
 
+// rolePattern restricts role names to short lowercase identifiers, e.g. admin or support_agent
+var rolePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
 type CreateUserRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -17,7 +21,7 @@ type CreateUserRequest struct {
 }
 
 func (r *CreateUserRequest) Validate() error {
-	return validation.ValidateStruct(r,
+	return validateStruct(r,
 		validation.Field(&r.FirstName, validation.Required),
 		validation.Field(&r.LastName, validation.Required),
 		validation.Field(&r.Nickname, validation.Required),
@@ -33,10 +37,25 @@ type CreateUserResponse struct {
 	Nickname  string    `json:"nickname"`
 	Email     string    `json:"email"`
 	Country   string    `json:"country"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Role      string    `json:"role"`
+	// EmailVerified tells whether the user proved they control the email
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// Version changes with every update; it is also sent as the ETag header
+	Version int64 `json:"version"`
 }
 
+const (
+	// MergePatchMediaType marks RFC 7396 merge patches; plain application/json is treated the same
+	MergePatchMediaType = "application/merge-patch+json"
+	// JSONPatchMediaType marks RFC 6902 JSON Patch documents
+	JSONPatchMediaType = "application/json-patch+json"
+)
+
+// UpdateUserRequest is the full editable profile of a user; it replaces the stored profile as a whole,
+// so omitted fields are cleared
 type UpdateUserRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -45,6 +64,13 @@ type UpdateUserRequest struct {
 	Country   string `json:"country"`
 }
 
+// Validate checks the profile a user ends up with; only the nickname and email may not be cleared
+func (r *UpdateUserRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Nickname, validation.Required),
+		validation.Field(&r.Email, validation.Required, is.Email))
+}
+
 type UpdateUserResponse struct {
 	ID        uuid.UUID `json:"id"`
 	FirstName string    `json:"first_name"`
@@ -52,8 +78,14 @@ type UpdateUserResponse struct {
 	Nickname  string    `json:"nickname"`
 	Email     string    `json:"email"`
 	Country   string    `json:"country"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Role      string    `json:"role"`
+	// EmailVerified tells whether the user proved they control the email
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// Version changes with every update; it is also sent as the ETag header
+	Version int64 `json:"version"`
 }
 
 type GetUserDTO struct {
@@ -63,13 +95,50 @@ type GetUserDTO struct {
 	Nickname  string    `json:"nickname"`
 	Email     string    `json:"email"`
 	Country   string    `json:"country"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Role      string    `json:"role"`
+	// EmailVerified tells whether the user proved they control the email
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// Version changes with every update; it is also sent as the ETag header
+	Version int64 `json:"version"`
+	// DeletedAt is only present on deleted users, which admins can list with include_deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// GetUsersRequest holds the query parameters of the user list as they were received
+type GetUsersRequest struct {
+	Page     string
+	PageSize string
+	Filter   string
+	Sort     string
+	// Cursor continues from a next_cursor or prev_cursor of an earlier response instead of a page number
+	Cursor         string
+	IncludeDeleted bool
 }
 
 type GetUserResponse struct {
-	Page     int          `json:"page"`
-	PageSize int          `json:"page_size"`
-	Total    int          `json:"total"`
-	Users    []GetUserDTO `json:"users"`
+	// Page is only set when the page was requested by number
+	Page     int `json:"page,omitempty"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"`
+	// TotalPages is the number of pages of PageSize users the filter matches
+	TotalPages int `json:"total_pages"`
+	// HasNext tells whether users follow this page
+	HasNext bool         `json:"has_next"`
+	Users   []GetUserDTO `json:"users"`
+	// NextCursor fetches the following page; it is left out on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// PrevCursor fetches the preceding page; it is left out on the first page
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (r *SetRoleRequest) Validate() error {
+	return validateStruct(r,
+		validation.Field(&r.Role, validation.Required, validation.Match(rolePattern)))
 }
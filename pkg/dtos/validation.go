@@ -0,0 +1,61 @@
+package dtos
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"sort"
+	"strings"
+)
+
+// invalidCode is reported for fields rejected by rules that carry no ozzo-validation error code
+const invalidCode = "validation_invalid"
+
+// FieldError describes why a request field was rejected. Code is stable, e.g. validation_required or
+// validation_is_email, while Message is meant for people.
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// FieldErrors maps the JSON names of invalid request fields to the reason they were rejected
+type FieldErrors map[string]FieldError
+
+// Error lists the invalid fields in alphabetical order, the way ozzo-validation does
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+": "+e[name].Message)
+	}
+
+	return strings.Join(parts, "; ") + "."
+}
+
+// validateStruct validates a request like validation.ValidateStruct, but reports invalid fields as FieldErrors
+// so every request DTO describes its errors the same way
+func validateStruct(structPtr any, fields ...*validation.FieldRules) error {
+	err := validation.ValidateStruct(structPtr, fields...)
+
+	// Anything else is either nil or an internal error that is not the client's fault
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fieldErrors := make(FieldErrors, len(errs))
+	for name, fieldErr := range errs {
+		code := invalidCode
+		var ruleErr validation.Error
+		if errors.As(fieldErr, &ruleErr) {
+			code = ruleErr.Code()
+		}
+		fieldErrors[name] = FieldError{Code: code, Message: fieldErr.Error()}
+	}
+
+	return fieldErrors
+}
@@ -0,0 +1,77 @@
+package dtos
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateStruct(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            any
+		rules          func(req any) []*validation.FieldRules
+		expectedErrors FieldErrors
+	}{
+		{
+			name: "Valid request",
+			req:  &LoginRequest{Login: "alice", Password: "password123"},
+		},
+		{
+			name: "Codes of the failed rules",
+			req:  &CreateUserRequest{Nickname: "alice", Password: "password123", Email: "alice"},
+			expectedErrors: FieldErrors{
+				"first_name": {Code: "validation_required", Message: "cannot be blank"},
+				"last_name":  {Code: "validation_required", Message: "cannot be blank"},
+				"email":      {Code: "validation_is_email", Message: "must be a valid email address"},
+				"country":    {Code: "validation_required", Message: "cannot be blank"},
+			},
+		},
+		{
+			name:           "Pattern mismatch",
+			req:            &SetRoleRequest{Role: "Admin!"},
+			expectedErrors: FieldErrors{"role": {Code: "validation_match_invalid", Message: "must be in a valid format"}},
+		},
+		{
+			name: "Rule without a code",
+			req:  &LoginRequest{Login: "alice", Password: "password123"},
+			rules: func(req any) []*validation.FieldRules {
+				r := req.(*LoginRequest)
+				return []*validation.FieldRules{validation.Field(&r.Login, validation.By(func(any) error {
+					return errors.New("is taken")
+				}))}
+			},
+			expectedErrors: FieldErrors{"login": {Code: "validation_invalid", Message: "is taken"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.rules != nil {
+				err = validateStruct(tt.req, tt.rules(tt.req)...)
+			} else {
+				err = tt.req.(interface{ Validate() error }).Validate()
+			}
+			if tt.expectedErrors == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrors FieldErrors
+			require.ErrorAs(t, err, &fieldErrors)
+			assert.Equal(t, tt.expectedErrors, fieldErrors)
+		})
+	}
+}
+
+func TestFieldErrorsError(t *testing.T) {
+	err := FieldErrors{
+		"nickname": {Code: "validation_required", Message: "cannot be blank"},
+		"email":    {Code: "validation_is_email", Message: "must be a valid email address"},
+	}
+
+	assert.Equal(t, "email: must be a valid email address; nickname: cannot be blank.", err.Error())
+}